@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+)
+
+const procCPUInfoPath = "/proc/cpuinfo"
+
+func isARM() bool {
+	return runtime.GOARCH == "arm" || runtime.GOARCH == "arm64"
+}
+
+// getARMCPUModel reads the CPU model from /proc/cpuinfo. On ARM, gopsutil's
+// ModelName is often empty or a generic "ARMv8 Processor", while the SoC name
+// is listed under "Hardware". It returns "" when nothing useful is found.
+func getARMCPUModel() string {
+	f, err := os.Open(procCPUInfoPath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	return parseARMCPUModel(f)
+}
+
+// parseARMCPUModel prefers the "Hardware" line, then "model name", then the
+// legacy "Processor" line of 32-bit kernels.
+func parseARMCPUModel(r io.Reader) string {
+	fields := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if _, ok := fields[key]; !ok && value != "" {
+			fields[key] = value
+		}
+	}
+	for _, key := range []string{"Hardware", "model name", "Processor"} {
+		if value, ok := fields[key]; ok {
+			return value
+		}
+	}
+	return ""
+}
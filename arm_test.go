@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseARMCPUModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		cpuinfo string
+		want    string
+	}{
+		{
+			name: "arm64 with hardware",
+			cpuinfo: `processor	: 0
+BogoMIPS	: 108.00
+Features	: fp asimd evtstrm crc32 cpuid
+CPU implementer	: 0x41
+CPU architecture: 8
+CPU part	: 0xd08
+
+processor	: 1
+BogoMIPS	: 108.00
+
+Hardware	: BCM2835
+Revision	: c03111
+Model		: Raspberry Pi 4 Model B Rev 1.1
+`,
+			want: "BCM2835",
+		},
+		{
+			name: "arm64 server with model name",
+			cpuinfo: `processor	: 0
+model name	: Neoverse-N1
+BogoMIPS	: 50.00
+`,
+			want: "Neoverse-N1",
+		},
+		{
+			name: "32-bit kernel",
+			cpuinfo: `Processor	: ARMv7 Processor rev 4 (v7l)
+processor	: 0
+BogoMIPS	: 38.40
+Hardware	:
+`,
+			want: "ARMv7 Processor rev 4 (v7l)",
+		},
+		{
+			name:    "no model",
+			cpuinfo: "processor\t: 0\nBogoMIPS\t: 50.00\n",
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseARMCPUModel(strings.NewReader(tt.cpuinfo)); got != tt.want {
+				t.Errorf("parseARMCPUModel = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// CacheTopology describes the first-level CPU cache of cpu0.
+type CacheTopology struct {
+	LineBytes     int
+	Associativity int
+}
+
+// getCacheTopology reads the L1 cache line size and associativity from
+// <cpuRoot>/cpu0/cache/index0, where cpuRoot is normally
+// /sys/devices/system/cpu.
+func getCacheTopology(cpuRoot string, reg *TemplateRegistry) (CacheTopology, error) {
+	dir := filepath.Join(cpuRoot, "cpu0", "cache", "index0")
+	lineSize, err := readSysfsInt(filepath.Join(dir, "coherency_line_size"))
+	if err != nil {
+		return CacheTopology{}, &MetricError{Subsystem: "cache", Err: err}
+	}
+	ways, err := readSysfsInt(filepath.Join(dir, "ways_of_associativity"))
+	if err != nil {
+		return CacheTopology{}, &MetricError{Subsystem: "cache", Err: err}
+	}
+	reg.RegisterMetadata(getCacheMetadataTemplate())
+	return CacheTopology{LineBytes: lineSize, Associativity: ways}, nil
+}
+
+func cacheLatest(stats CacheTopology, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"cpu_cache_line_bytes":       {Timestamp: t, Value: formatNumber(float64(stats.LineBytes), 0)},
+		"cpu_l1_cache_associativity": {Timestamp: t, Value: formatNumber(float64(stats.Associativity), 0)},
+	}
+}
+
+func getCacheMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"cpu_cache_line_bytes": {
+			ID:       "cpu_cache_line_bytes",
+			Label:    "CPU Cache Line (bytes)",
+			Datatype: "integer",
+			Priority: priorityHardware + 1,
+			From:     "latest",
+		},
+		"cpu_l1_cache_associativity": {
+			ID:       "cpu_l1_cache_associativity",
+			Label:    "CPU L1 Cache Associativity",
+			Datatype: "integer",
+			Priority: priorityHardware + 1,
+			From:     "latest",
+		},
+	}
+}
+
+func readSysfsInt(path string) (int, error) {
+	s, err := readSysfsString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(s)
+}
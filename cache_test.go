@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestGetCacheTopology(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        CacheTopology
+		wantErr     bool
+		wantMissing bool
+	}{
+		{
+			name: "x86",
+			files: map[string]string{
+				"cpu0/cache/index0/coherency_line_size":   "64\n",
+				"cpu0/cache/index0/ways_of_associativity": "8\n",
+			},
+			want: CacheTopology{LineBytes: 64, Associativity: 8},
+		},
+		{
+			name: "apple silicon line size",
+			files: map[string]string{
+				"cpu0/cache/index0/coherency_line_size":   "128\n",
+				"cpu0/cache/index0/ways_of_associativity": "4\n",
+			},
+			want: CacheTopology{LineBytes: 128, Associativity: 4},
+		},
+		{
+			name:        "no cache directory",
+			files:       map[string]string{"cpu0/online": "1\n"},
+			wantErr:     true,
+			wantMissing: true,
+		},
+		{
+			name: "garbage",
+			files: map[string]string{
+				"cpu0/cache/index0/coherency_line_size":   "sixty-four\n",
+				"cpu0/cache/index0/ways_of_associativity": "8\n",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewTemplateRegistry()
+			got, err := getCacheTopology(writeTree(t, tt.files), reg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCacheTopology error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if sysfsMissing(err) != tt.wantMissing {
+				t.Errorf("sysfsMissing(%v) = %v, want %v", err, !tt.wantMissing, tt.wantMissing)
+			}
+			if got != tt.want {
+				t.Errorf("getCacheTopology = %+v, want %+v", got, tt.want)
+			}
+			_, registered := reg.MetadataTemplates()["cpu_cache_line_bytes"]
+			if registered == tt.wantErr {
+				t.Errorf("template registered = %v, want %v", registered, !tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultCgroupPath = "/sys/fs/cgroup"
+
+// CgroupStats holds the resource limits of the plugin's own cgroup.
+type CgroupStats struct {
+	// CPUQuotaCores is the CPU bandwidth limit in cores, 0 when unlimited.
+	CPUQuotaCores float64
+}
+
+// getCgroupStats reads the CPU quota from cgroup v2 (cpu.max) or, failing
+// that, cgroup v1 (cpu/cpu.cfs_quota_us and cpu/cpu.cfs_period_us) under root.
+func getCgroupStats(ctx context.Context, root string, reg *TemplateRegistry) (CgroupStats, error) {
+	stats, err := readCgroupStats(root)
+	if err != nil {
+		return CgroupStats{}, &MetricError{Subsystem: "cgroup", Err: err}
+	}
+	if stats.CPUQuotaCores > 0 {
+		reg.RegisterMetadata(getCgroupMetadataTemplate())
+	}
+	return stats, nil
+}
+
+func readCgroupStats(root string) (CgroupStats, error) {
+	if max, err := readSysfsString(filepath.Join(root, "cpu.max")); err == nil {
+		quota, err := parseCPUMax(max)
+		if err != nil {
+			return CgroupStats{}, err
+		}
+		return CgroupStats{CPUQuotaCores: quota}, nil
+	}
+
+	quota, err := readSysfsInt(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return CgroupStats{}, err
+	}
+	period, err := readSysfsInt(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return CgroupStats{}, err
+	}
+	if quota <= 0 || period <= 0 {
+		return CgroupStats{}, nil
+	}
+	return CgroupStats{CPUQuotaCores: float64(quota) / float64(period)}, nil
+}
+
+// parseCPUMax parses the cgroup v2 "$MAX $PERIOD" format, where $MAX may be
+// "max" for no limit.
+func parseCPUMax(s string) (float64, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("unexpected cpu.max content %q", s)
+	}
+	if fields[0] == "max" {
+		return 0, nil
+	}
+	quota, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected cpu.max content %q: %v", s, err)
+	}
+	period, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || period <= 0 {
+		return 0, fmt.Errorf("unexpected cpu.max content %q", s)
+	}
+	return quota / period, nil
+}
+
+func cgroupLatest(stats CgroupStats, t time.Time) map[string]stringEntry {
+	latest := map[string]stringEntry{}
+	if stats.CPUQuotaCores > 0 {
+		latest["cgroup_cpu_quota_cores"] = stringEntry{Timestamp: t, Value: formatNumber(stats.CPUQuotaCores, 2)}
+	}
+	return latest
+}
+
+func getCgroupMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"cgroup_cpu_quota_cores": {
+			ID:       "cgroup_cpu_quota_cores",
+			Label:    "Cgroup CPU Quota (cores)",
+			Datatype: "number",
+			Priority: prioritySelf,
+			From:     "latest",
+		},
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// MetricCollector is a source of host node metrics. Collectors registered on
+// the Plugin run in order at the start of every collection. Entries without a
+// timestamp get the collection time.
+type MetricCollector interface {
+	// Name identifies the collector in degraded_collectors.
+	Name() string
+	// Collect returns the Latest entries and their metadata templates.
+	Collect(ctx context.Context) (map[string]stringEntry, []metadataTemplate, error)
+}
+
+// RegisterCollector adds c to the collectors run for the host node. Call it
+// before Run.
+func (p *Plugin) RegisterCollector(c MetricCollector) {
+	p.collecting <- struct{}{}
+	defer func() { <-p.collecting }()
+	p.collectors = append(p.collectors, c)
+}
+
+// runCollectors runs the registered collectors in order, adding their
+// entries to latest and their templates to reg. The CPU and memory
+// collectors are required: when one fails, runCollectors returns its name
+// and error. Other collectors only degrade health when they fail. It
+// returns ctx's error, with no name, when ctx is done.
+func (p *Plugin) runCollectors(ctx context.Context, latest map[string]stringEntry, reg *TemplateRegistry, health *collectorHealth, t time.Time) (failed string, err error) {
+	for _, c := range p.collectors {
+		entries, templates, err := c.Collect(ctx)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		if err != nil {
+			if c == MetricCollector(p.cpu) || c == MetricCollector(p.mem) {
+				return c.Name(), err
+			}
+			health.degrade(c.Name(), err)
+			continue
+		}
+		for k, v := range entries {
+			if v.Timestamp.IsZero() {
+				v.Timestamp = t
+			}
+			latest[k] = v
+		}
+		for _, tmpl := range templates {
+			reg.RegisterMetadata(map[string]metadataTemplate{tmpl.ID: tmpl})
+		}
+	}
+	return "", nil
+}
+
+// CPUCollector reports the CPU model, count, microarchitecture and clock.
+// Stats holds the result of the last successful Collect.
+type CPUCollector struct {
+	Stats CPUStats
+}
+
+func (c *CPUCollector) Name() string { return "cpu" }
+
+func (c *CPUCollector) Collect(ctx context.Context) (map[string]stringEntry, []metadataTemplate, error) {
+	reg := NewTemplateRegistry()
+	stats, err := getCPUStats(ctx, reg)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.Stats = stats
+
+	latest := map[string]stringEntry{
+		"cpu_model":       {Value: stats.CPUModel},
+		"processor_count": {Value: formatNumber(float64(stats.ProcessorCount), 0)},
+	}
+	if stats.Microarch != "" {
+		latest["cpu_microarch"] = stringEntry{Value: stats.Microarch}
+	}
+	if stats.Mhz > 0 {
+		for k, v := range cpuFreqLatest("cpu_mhz", stats.Mhz, time.Time{}) {
+			latest[k] = v
+		}
+	}
+	return latest, metadataList(reg.MetadataTemplates()), nil
+}
+
+// MemCollector reports the total memory. Stats holds the result of the last
+// successful Collect.
+type MemCollector struct {
+	Stats MemStats
+}
+
+func (c *MemCollector) Name() string { return "mem" }
+
+func (c *MemCollector) Collect(ctx context.Context) (map[string]stringEntry, []metadataTemplate, error) {
+	reg := NewTemplateRegistry()
+	stats, err := getMemStats(ctx, reg)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.Stats = stats
+
+	latest := map[string]stringEntry{
+		"platform_memory": {Value: formatNumber(float64(stats.MemTotalBytes), 0)},
+	}
+	return latest, metadataList(reg.MetadataTemplates()), nil
+}
+
+// metadataList returns templates sorted by ID.
+func metadataList(templates map[string]metadataTemplate) []metadataTemplate {
+	list := make([]metadataTemplate, 0, len(templates))
+	for _, t := range templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// staticCollector returns the same entries and templates on every Collect.
+type staticCollector struct {
+	name      string
+	latest    map[string]stringEntry
+	templates []metadataTemplate
+}
+
+func (c staticCollector) Name() string { return c.name }
+
+func (c staticCollector) Collect(ctx context.Context) (map[string]stringEntry, []metadataTemplate, error) {
+	return c.latest, c.templates, nil
+}
+
+func TestRegisteredCollectorInNode(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Minute)
+	tests := []struct {
+		name          string
+		collector     staticCollector
+		want          map[string]stringEntry
+		wantTemplates []string
+	}{
+		{
+			name: "collection time",
+			collector: staticCollector{
+				name:      "rack",
+				latest:    map[string]stringEntry{"rack_position": {Value: "R7U12"}},
+				templates: []metadataTemplate{{ID: "rack_position", Label: "Rack Position", From: "latest"}},
+			},
+			want:          map[string]stringEntry{"rack_position": {Timestamp: now, Value: "R7U12"}},
+			wantTemplates: []string{"rack_position"},
+		},
+		{
+			name: "own timestamp",
+			collector: staticCollector{
+				name:   "asset",
+				latest: map[string]stringEntry{"asset_tag": {Timestamp: earlier, Value: "A-1042"}},
+			},
+			want: map[string]stringEntry{"asset_tag": {Timestamp: earlier, Value: "A-1042"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.CollectMode = collectBackground
+			p := NewPlugin("host", cfg)
+			p.now = func() time.Time { return now }
+			p.RegisterCollector(tt.collector)
+			if err := p.collect(context.Background()); err != nil {
+				t.Fatalf("collect: %v", err)
+			}
+
+			rpt, err := p.makeReport(context.Background())
+			if err != nil {
+				t.Fatalf("makeReport: %v", err)
+			}
+			n := rpt.Host.Nodes[p.getTopologyHost(cfg.DomainSuffix)]
+			for k, want := range tt.want {
+				if got := n.Latest[k]; got != want {
+					t.Errorf("%s = %+v, want %+v", k, got, want)
+				}
+			}
+			for _, id := range tt.wantTemplates {
+				if _, ok := rpt.Host.MetadataTemplates[id]; !ok {
+					t.Errorf("no %s template", id)
+				}
+			}
+			if _, ok := n.Latest["processor_count"]; !ok {
+				t.Error("the built-in collectors' entries are missing")
+			}
+		})
+	}
+}
+
+func TestRunCollectors(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	rack := staticCollector{
+		name:      "rack",
+		latest:    map[string]stringEntry{"rack_position": {Value: "R7U12"}},
+		templates: []metadataTemplate{{ID: "rack_position", Label: "Rack Position", From: "latest"}},
+	}
+	tests := []struct {
+		name         string
+		collectors   []MetricCollector
+		cancelled    bool
+		wantErr      bool
+		wantLatest   []string
+		wantDegraded []string
+	}{
+		{
+			name:       "entries of every collector",
+			collectors: []MetricCollector{rack, staticCollector{name: "asset", latest: map[string]stringEntry{"asset_tag": {Value: "A-1042"}}}},
+			wantLatest: []string{"rack_position", "asset_tag"},
+		},
+		{
+			name:         "optional collector fails",
+			collectors:   []MetricCollector{failingCollector{name: "broken", err: errors.New("device busy")}, rack},
+			wantLatest:   []string{"rack_position"},
+			wantDegraded: []string{"broken"},
+		},
+		{
+			name:       "cancelled",
+			collectors: []MetricCollector{rack},
+			cancelled:  true,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPlugin("host", loadConfig())
+			p.collectors = tt.collectors
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+
+			latest := map[string]stringEntry{}
+			reg := NewTemplateRegistry()
+			var health collectorHealth
+			failed, err := p.runCollectors(ctx, latest, reg, &health, now)
+			if (err != nil) != tt.wantErr || failed != "" {
+				t.Fatalf("runCollectors = %q, %v, wantErr %v", failed, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(latest) != len(tt.wantLatest) {
+				t.Errorf("latest = %v, want keys %v", latest, tt.wantLatest)
+			}
+			for _, k := range tt.wantLatest {
+				if e, ok := latest[k]; !ok || !e.Timestamp.Equal(now) {
+					t.Errorf("%s = %+v, %v, want an entry at the collection time", k, e, ok)
+				}
+			}
+			if _, ok := reg.MetadataTemplates()["rack_position"]; !ok {
+				t.Error("no rack_position template")
+			}
+			if !reflect.DeepEqual(health.degraded, tt.wantDegraded) {
+				t.Errorf("degraded = %v, want %v", health.degraded, tt.wantDegraded)
+			}
+		})
+	}
+}
+
+func TestRunServesRegisteredCollectors(t *testing.T) {
+	cfg := loadConfig()
+	cfg.CollectMode = collectBackground
+	cfg.RefreshInterval = time.Hour
+	p := NewPlugin("host", cfg)
+	p.RegisterCollector(staticCollector{
+		name:      "rack",
+		latest:    map[string]stringEntry{"rack_position": {Value: "R7U12"}},
+		templates: []metadataTemplate{{ID: "rack_position", Label: "Rack Position", From: "latest"}},
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- p.Run(listener) }()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rpt report
+	err = json.NewDecoder(resp.Body).Decode(&rpt)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rpt.Host.Nodes[p.getTopologyHost(cfg.DomainSuffix)].Latest["rack_position"].Value; got != "R7U12" {
+		t.Errorf("rack_position = %q, want R7U12", got)
+	}
+
+	listener.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Run returned nil after the listener closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after the listener closed")
+	}
+}
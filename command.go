@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"time"
+)
+
+var errCommandTimeout = errors.New("command timed out")
+
+// runCommand runs name with args and returns its standard output. If it
+// doesn't finish within timeout, the command and any children it started are
+// killed and errCommandTimeout is returned without waiting for their output.
+// They are killed likewise when ctx is done, returning ctx.Err().
+func runCommand(ctx context.Context, timeout time.Duration, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		out []byte
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := ioutil.ReadAll(stdout)
+		if waitErr := cmd.Wait(); err == nil {
+			err = waitErr
+		}
+		done <- result{out, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-done:
+		return res.out, res.err
+	case <-timer.C:
+		killProcessGroup(cmd)
+		return nil, errCommandTimeout
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		return nil, ctx.Err()
+	}
+}
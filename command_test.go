@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRunCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		timeout time.Duration
+		script  string
+		want    string
+		wantErr error
+	}{
+		{"output", context.Background(), 5 * time.Second, "echo hello", "hello\n", nil},
+		{"timeout", context.Background(), 50 * time.Millisecond, "sleep 10", "", errCommandTimeout},
+		{"cancelled", cancelled, 5 * time.Second, "sleep 10", "", context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			out, err := runCommand(tt.ctx, tt.timeout, "sh", "-c", tt.script)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("out = %q, want %q", out, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("took %s", elapsed)
+			}
+		})
+	}
+}
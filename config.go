@@ -0,0 +1,453 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the plugin's runtime configuration. It is resolved at startup
+// from CPUINFO_* environment variables, some of which can be overridden by
+// command line flags and by the -config file.
+type Config struct {
+	// ConfigFile is an optional JSON file overriding some settings, which
+	// is re-read on SIGHUP.
+	ConfigFile string
+	// TemplatesFile is an optional JSON file overriding the labels,
+	// priorities and other fields of the built-in templates by ID.
+	TemplatesFile string
+	// SocketPath is where the plugin listens for Scope.
+	SocketPath string
+	// SocketGroup, when set, owns the socket and may use it.
+	SocketGroup string
+	// ListenTCP, when set, is a TCP address such as ":8080" to listen on
+	// instead of SocketPath.
+	ListenTCP string
+	// TLSCert, TLSKey and TLSCA enable mutual TLS on ListenTCP: the server
+	// certificate and key, and the CA client certificates must be signed by.
+	TLSCert string
+	TLSKey  string
+	TLSCA   string
+	// RefreshInterval is how often host metrics are collected.
+	RefreshInterval time.Duration
+	// RefreshJitter spreads collections out by randomly varying each
+	// interval by up to this fraction of RefreshInterval, in [0, 1).
+	RefreshJitter float64
+	// CollectMode is collectBackground to serve reports from the host node
+	// the refresher collects every RefreshInterval, or collectSync to collect
+	// it on every report.
+	CollectMode string
+	// ReportRateLimit caps the /report requests served per second, others
+	// get 429 Too Many Requests; 0 disables the limit.
+	ReportRateLimit float64
+	// StateFile, when set, persists the rate collectors' samples across
+	// restarts.
+	StateFile string
+	// StdoutInterval, when positive, prints the report to stdout as one JSON
+	// line at this interval.
+	StdoutInterval time.Duration
+	// StdoutDeltas prints only the changed Latest entries after the first
+	// stdout line, marked with report_delta.
+	StdoutDeltas bool
+	// Pretty indents the JSON served on /report, /control and the debug
+	// endpoints.
+	Pretty bool
+	// InfluxURL, when set, is an InfluxDB server receiving the host stats
+	// in line protocol every refresh interval, into database InfluxDB.
+	InfluxURL string
+	InfluxDB  string
+	// DebugEndpoints enables the /debug/* HTTP endpoints.
+	DebugEndpoints bool
+	// LogLevel is one of debug, info, warn or error.
+	LogLevel string
+	// CPUSysfsPath is the sysfs CPU directory, normally
+	// /sys/devices/system/cpu.
+	CPUSysfsPath string
+	// CPUFlagsTruncate is the maximum length of the cpu_flags set, the full
+	// list is in the cpuinfo table. Zero disables truncation.
+	CPUFlagsTruncate int
+	// CoreTypeSource selects where performance and efficiency cores are
+	// read from: coreTypeAuto, coreTypePMU or coreTypeCapacity.
+	CoreTypeSource string
+	// ProcPath is the procfs mount, normally /proc.
+	ProcPath string
+	// EDACPath is the EDAC memory controller directory, normally
+	// /sys/devices/system/edac/mc.
+	EDACPath string
+	// NUMANodePath is the sysfs NUMA node directory, normally
+	// /sys/devices/system/node.
+	NUMANodePath string
+	// IPv4RoutePath and IPv6RoutePath are the routing tables, normally
+	// /proc/net/route and /proc/net/ipv6_route.
+	IPv4RoutePath string
+	IPv6RoutePath string
+	// CgroupPath is the cgroup filesystem mount, normally /sys/fs/cgroup.
+	CgroupPath string
+	// ControlToken, when set, must be sent as "Authorization: Bearer
+	// <token>" on /control requests.
+	ControlToken string
+	// NoController drops the controller interface: no controls are
+	// reported and /control answers 404.
+	NoController bool
+	// GPUStats enables the NVIDIA GPU collector, which runs nvidia-smi.
+	GPUStats bool
+	// PSUStats enables the mains power supply collector.
+	PSUStats bool
+	// CStateStats enables the C-state residency collector.
+	CStateStats bool
+	// MemBandwidth enables the memory bandwidth collector, which reads PCM's
+	// shared memory or else runs perf for half a second per collection.
+	MemBandwidth bool
+	// RemoteHosts lists other cpuinfo instances, as host:port, whose host
+	// nodes are merged into this plugin's report.
+	RemoteHosts []string
+	// TCPPort is the port of the RemoteHosts given without one.
+	TCPPort string
+	// TopNProcesses is how many of the processes using the most CPU are
+	// reported in the Process topology, 0 disables it.
+	TopNProcesses int
+	// TopCPUProcess reports the process using the most CPU on the host node.
+	TopCPUProcess bool
+	// TopMemProcess reports the process with the largest RSS on the host
+	// node.
+	TopMemProcess bool
+	// ProcessStates reports the number of running, blocked and zombie
+	// processes, which needs the status of every process.
+	ProcessStates bool
+	// RefreshFailuresRow reports the refresh failures per collector as the
+	// refresh_failures row.
+	RefreshFailuresRow bool
+	// ProcessMinInterval is the minimum time between two process
+	// enumerations, which are expensive on busy hosts.
+	ProcessMinInterval time.Duration
+	// EMAAlpha, in (0, 1], adds exponential moving averages of the CPU
+	// usage and rate metrics as <key>_ema; 0 disables them. Lower values
+	// smooth more.
+	EMAAlpha float64
+	// Counters adds CPU utilization, memory used and load to the host node's
+	// Counters for Scope's graphs.
+	Counters bool
+	// SelfStats enables the collector of the plugin's own cgroup usage.
+	SelfStats bool
+	// K8sLimitFiles are the cpu and memory files of a downward API volume
+	// with the pod limits, empty to disable.
+	K8sLimitFiles []string
+	// GPUTimeout bounds how long nvidia-smi may run.
+	GPUTimeout time.Duration
+	// HostID overrides the hostname as the Scope host node identity.
+	HostID string
+	// DomainSuffix is appended to the host ID in the host node ID. The
+	// special value "machine-id" stands for the machine UUID.
+	DomainSuffix string
+	// MergeBy is the key of the host topology's merge hint. The special
+	// value "none" leaves the hint out.
+	MergeBy string
+	// NetTable enables the per-interface network table.
+	NetTable bool
+	// NetIfaceAllow, when non-empty, restricts network stats to interfaces
+	// matching one of these glob patterns.
+	NetIfaceAllow []string
+	// NetIfaceDeny excludes interfaces matching one of these glob patterns.
+	NetIfaceDeny []string
+	// DiskIODeny excludes block devices matching one of these glob
+	// patterns from the disk I/O table.
+	DiskIODeny []string
+	// DiskIncludeVirtual reports the mounts of virtual filesystems such as
+	// tmpfs and proc along with the disk-backed ones.
+	DiskIncludeVirtual bool
+	// DiskTopology enables the Disk topology with one node per disk device.
+	DiskTopology bool
+	// ExtraLabels are arbitrary key=value pairs added to the host node.
+	ExtraLabels map[string]string
+	// PercentPrecision is the number of decimals of percent values, clamped
+	// to 0-3.
+	PercentPrecision int
+	// KeyPrefix is prepended to every Latest key and template ID, to avoid
+	// collisions with other plugins. Empty keeps the historical keys.
+	KeyPrefix string
+	// SocketRetryTimeout bounds how long to keep retrying to set up the
+	// plugin socket before giving up. Zero disables retries.
+	SocketRetryTimeout time.Duration
+}
+
+// We put the socket in a sub-directory to have more control on the permissions
+const defaultSocketPath = "/var/run/scope/plugins/cpuinfo/cpuinfo.sock"
+
+const machineIDSuffix = "machine-id"
+
+// Collection modes of Config.CollectMode.
+const (
+	collectBackground = "background"
+	collectSync       = "sync"
+)
+
+func loadConfig() Config {
+	return Config{
+		SocketPath:      envString("CPUINFO_SOCKET_PATH", defaultSocketPath),
+		SocketGroup:     os.Getenv("CPUINFO_SOCKET_GROUP"),
+		ListenTCP:       os.Getenv("CPUINFO_LISTEN_TCP"),
+		TLSCert:         os.Getenv("CPUINFO_TLS_CERT"),
+		TLSKey:          os.Getenv("CPUINFO_TLS_KEY"),
+		TLSCA:           os.Getenv("CPUINFO_TLS_CA"),
+		RefreshInterval: envDuration("CPUINFO_REFRESH_INTERVAL", 10*time.Second),
+		RefreshJitter:   envFloat("CPUINFO_REFRESH_JITTER", 0),
+		CollectMode:     envString("CPUINFO_COLLECT_MODE", collectBackground),
+		ReportRateLimit: envFloat("CPUINFO_REPORT_RATE_LIMIT_PER_SEC", 10),
+		StateFile:       os.Getenv("CPUINFO_STATE_FILE"),
+		TemplatesFile:   os.Getenv("CPUINFO_TEMPLATES_FILE"),
+		StdoutInterval:  envDuration("CPUINFO_STDOUT_INTERVAL", 0),
+		StdoutDeltas:    envBool("CPUINFO_STDOUT_DELTAS", false),
+		Pretty:          envBool("CPUINFO_PRETTY", false),
+		InfluxURL:       os.Getenv("CPUINFO_INFLUX_URL"),
+		InfluxDB:        envString("CPUINFO_INFLUX_DB", "cpuinfo"),
+		DebugEndpoints:  envBool("CPUINFO_DEBUG_ENDPOINTS", false),
+		LogLevel:        envString("CPUINFO_LOG_LEVEL", "info"),
+
+		CPUSysfsPath:       envString("CPUINFO_SYSFS_CPU_PATH", defaultCPUSysfsPath),
+		CPUFlagsTruncate:   envInt("CPUINFO_CPU_FLAGS_TRUNCATE", 0),
+		CoreTypeSource:     envString("CPUINFO_CORE_TYPE_SOURCE", coreTypeAuto),
+		ProcPath:           envString("CPUINFO_PROC_PATH", defaultProcPath),
+		EDACPath:           envString("CPUINFO_EDAC_PATH", defaultEDACPath),
+		NUMANodePath:       envString("CPUINFO_NUMA_NODE_PATH", defaultNUMANodePath),
+		IPv4RoutePath:      envString("CPUINFO_IPV4_ROUTE_PATH", defaultIPv4RoutePath),
+		IPv6RoutePath:      envString("CPUINFO_IPV6_ROUTE_PATH", defaultIPv6RoutePath),
+		CgroupPath:         envString("CPUINFO_CGROUP_PATH", defaultCgroupPath),
+		NoController:       envBool("CPUINFO_NO_CONTROLLER", false),
+		ControlToken:       os.Getenv("CPUINFO_CONTROL_TOKEN"),
+		GPUStats:           envBool("CPUINFO_GPU_STATS", false),
+		PSUStats:           envBool("CPUINFO_PSU_STATS", false),
+		CStateStats:        envBool("CPUINFO_CSTATE_STATS", false),
+		MemBandwidth:       envBool("CPUINFO_MEM_BANDWIDTH", false),
+		SelfStats:          envBool("CPUINFO_SELF_STATS", false),
+		Counters:           envBool("CPUINFO_COUNTERS", false),
+		EMAAlpha:           envFloat("CPUINFO_EMA_ALPHA", 0),
+		RemoteHosts:        envList("CPUINFO_REMOTE_HOSTS", nil),
+		TCPPort:            os.Getenv("CPUINFO_TCP_PORT"),
+		TopNProcesses:      envInt("CPUINFO_TOP_N_PROCESSES", 5),
+		TopCPUProcess:      envBool("CPUINFO_TOP_CPU_PROCESS", false),
+		TopMemProcess:      envBool("CPUINFO_TOP_MEM_PROCESS", false),
+		ProcessStates:      envBool("CPUINFO_PROCESS_STATES", false),
+		RefreshFailuresRow: envBool("CPUINFO_REFRESH_FAILURES_ROW", false),
+		ProcessMinInterval: envDuration("CPUINFO_PROCESS_MIN_INTERVAL", 30*time.Second),
+		K8sLimitFiles:      envList("CPUINFO_K8S_RESOURCE_LIMITS_FILE", nil),
+		GPUTimeout:         envDuration("CPUINFO_GPU_TIMEOUT", 2*time.Second),
+		HostID:             os.Getenv("SCOPE_HOST_ID"),
+		DomainSuffix:       os.Getenv("CPUINFO_DOMAIN_SUFFIX"),
+		MergeBy:            envString("CPUINFO_MERGE_BY", "hostname"),
+		NetTable:           envBool("CPUINFO_NET_TABLE", false),
+		NetIfaceAllow:      envList("CPUINFO_NET_IFACE_ALLOW", nil),
+		NetIfaceDeny:       envList("CPUINFO_NET_IFACE_DENY", []string{"lo", "veth*"}),
+		DiskIODeny:         envList("CPUINFO_DISKIO_DENY", []string{"loop*", "ram*"}),
+		DiskIncludeVirtual: envBool("CPUINFO_DISK_INCLUDE_VIRTUAL", false),
+		DiskTopology:       envBool("CPUINFO_DISK_TOPOLOGY", false),
+		ExtraLabels:        parseExtraLabels(os.Getenv("CPUINFO_EXTRA_LABELS")),
+		PercentPrecision:   envInt("CPUINFO_PERCENT_PRECISION", 1),
+		KeyPrefix:          os.Getenv("CPUINFO_KEY_PREFIX"),
+
+		SocketRetryTimeout: envDuration("CPUINFO_SOCKET_RETRY_TIMEOUT", 30*time.Second),
+	}
+}
+
+func envString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+func envBool(name string, def bool) bool {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		warnf("invalid %s=%q, using %v", name, v, def)
+		return def
+	}
+	return b
+}
+
+// envList parses a comma-separated list, ignoring empty items.
+func envList(name string, def []string) []string {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return def
+	}
+	return splitList(v)
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		warnf("invalid %s=%q, using %v", name, v, def)
+		return def
+	}
+	return i
+}
+
+func envFloat(name string, def float64) float64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		warnf("invalid %s=%q, using %v", name, v, def)
+		return def
+	}
+	return f
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		warnf("invalid %s=%q, using %s", name, v, def)
+		return def
+	}
+	return d
+}
+
+// validate checks the settings that cannot be defaulted.
+func (cfg Config) validate() error {
+	if cfg.RefreshInterval <= 0 {
+		return fmt.Errorf("refresh interval must be positive, got %s", cfg.RefreshInterval)
+	}
+	if cfg.RefreshJitter < 0 || cfg.RefreshJitter >= 1 {
+		return fmt.Errorf("refresh jitter must be in [0, 1), got %v", cfg.RefreshJitter)
+	}
+	if cfg.CollectMode != collectBackground && cfg.CollectMode != collectSync {
+		return fmt.Errorf("collect mode must be %q or %q, got %q", collectBackground, collectSync, cfg.CollectMode)
+	}
+	switch cfg.CoreTypeSource {
+	case coreTypeAuto, coreTypePMU, coreTypeCapacity:
+	default:
+		return fmt.Errorf("core type source must be %q, %q or %q, got %q", coreTypeAuto, coreTypePMU, coreTypeCapacity, cfg.CoreTypeSource)
+	}
+	if cfg.EMAAlpha < 0 || cfg.EMAAlpha > 1 {
+		return fmt.Errorf("EMA alpha must be in [0, 1], got %v", cfg.EMAAlpha)
+	}
+	if cfg.TopNProcesses < 0 {
+		return fmt.Errorf("top N processes must not be negative, got %d", cfg.TopNProcesses)
+	}
+	if cfg.ReportRateLimit < 0 {
+		return fmt.Errorf("report rate limit must not be negative, got %v", cfg.ReportRateLimit)
+	}
+	for _, file := range cfg.K8sLimitFiles {
+		if name := filepath.Base(file); name != "cpu" && name != "memory" {
+			return fmt.Errorf("Kubernetes limit files must be named cpu or memory, got %s", file)
+		}
+	}
+	if tlsSet := cfg.TLSCert != "" || cfg.TLSKey != "" || cfg.TLSCA != ""; tlsSet {
+		if cfg.TLSCert == "" || cfg.TLSKey == "" || cfg.TLSCA == "" {
+			return fmt.Errorf("CPUINFO_TLS_CERT, CPUINFO_TLS_KEY and CPUINFO_TLS_CA must be set together")
+		}
+		if cfg.ListenTCP == "" {
+			return fmt.Errorf("TLS requires CPUINFO_LISTEN_TCP")
+		}
+	}
+	if cfg.TCPPort != "" {
+		if port, err := strconv.Atoi(cfg.TCPPort); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("CPUINFO_TCP_PORT must be a port number, got %q", cfg.TCPPort)
+		}
+	}
+	for _, host := range cfg.RemoteHosts {
+		if cfg.TCPPort == "" && !remoteHasPort(host) {
+			return fmt.Errorf("remote host %q has no port and CPUINFO_TCP_PORT is unset", host)
+		}
+	}
+	if cfg.StdoutInterval < 0 {
+		return fmt.Errorf("stdout interval must not be negative, got %s", cfg.StdoutInterval)
+	}
+	if cfg.InfluxURL != "" && cfg.InfluxDB == "" {
+		return fmt.Errorf("-influx-db is required with -influx-url")
+	}
+	if _, err := loadTemplateOverrides(cfg.TemplatesFile); err != nil {
+		return err
+	}
+	if err := validateTablePrefix(cpuinfoTablePrefix()); err != nil {
+		return err
+	}
+	if _, err := parseLogLevel(cfg.LogLevel); err != nil {
+		return err
+	}
+	return nil
+}
+
+// enabledCollectors lists the collectors that run with cfg.
+func (cfg Config) enabledCollectors() []string {
+	collectors := []string{"cpu", "mem", "cpu_usage", "swap", "cache", "core_types", "cpu_online", "cpu_base_freq", "cpu_governor", "cpu_vulnerabilities", "smt", "mem_commit", "ecc", "numa", "vm_sysctl", "cgroup", "security", "systemd", "load", "net", "tcp", "ip_addresses", "routes", "diskio", "inodes", "mounts", "runtime"}
+	if cfg.NetTable {
+		collectors = append(collectors, "net_table")
+	}
+	if cfg.GPUStats {
+		collectors = append(collectors, "gpu")
+	}
+	if cfg.PSUStats {
+		collectors = append(collectors, "psu")
+	}
+	if cfg.CStateStats {
+		collectors = append(collectors, "cstate")
+	}
+	if cfg.MemBandwidth {
+		collectors = append(collectors, "mem_bandwidth")
+	}
+	if cfg.SelfStats {
+		collectors = append(collectors, "self")
+	}
+	if len(cfg.K8sLimitFiles) > 0 {
+		collectors = append(collectors, "k8s_limits")
+	}
+	if cfg.processesEnabled() {
+		collectors = append(collectors, "processes")
+	}
+	if cfg.DiskTopology {
+		collectors = append(collectors, "disk_topology")
+	}
+	return collectors
+}
+
+// processesEnabled is whether any metric needs the process list.
+func (cfg Config) processesEnabled() bool {
+	return cfg.TopNProcesses > 0 || cfg.TopCPUProcess || cfg.TopMemProcess || cfg.ProcessStates
+}
+
+// startupBanner describes the effective configuration on a single line,
+// with secrets redacted.
+func startupBanner(cfg Config) string {
+	token := "unset"
+	if cfg.ControlToken != "" {
+		token = "redacted"
+	}
+	return fmt.Sprintf("config: socket_path=%q refresh_interval=%s refresh_jitter=%v collect_mode=%s log_level=%s collectors=%s host_id=%q domain_suffix=%q config_file=%q state_file=%q control_token=%s",
+		cfg.SocketPath, cfg.RefreshInterval, cfg.RefreshJitter, cfg.CollectMode, cfg.LogLevel,
+		strings.Join(cfg.enabledCollectors(), ","), cfg.HostID, cfg.DomainSuffix,
+		cfg.ConfigFile, cfg.StateFile, token)
+}
+
+func logStartup(cfg Config) {
+	log.Print(startupBanner(cfg))
+}
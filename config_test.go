@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartupBanner(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    func(cfg *Config)
+		want      []string
+		forbidden []string
+	}{
+		{
+			name: "defaults",
+			config: func(cfg *Config) {
+				cfg.RefreshInterval = 15 * time.Second
+			},
+			want: []string{"refresh_interval=15s", "control_token=unset", "collectors=" + strings.Join(loadConfig().enabledCollectors(), ",")},
+		},
+		{
+			name: "token redacted",
+			config: func(cfg *Config) {
+				cfg.RefreshInterval = 2 * time.Second
+				cfg.ControlToken = "hunter2"
+			},
+			want:      []string{"refresh_interval=2s", "control_token=redacted"},
+			forbidden: []string{"hunter2"},
+		},
+		{
+			name: "net table enabled",
+			config: func(cfg *Config) {
+				cfg.NetTable = true
+			},
+			want: []string{"net_table"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			tt.config(&cfg)
+			banner := startupBanner(cfg)
+			for _, want := range tt.want {
+				if !strings.Contains(banner, want) {
+					t.Errorf("banner %q doesn't contain %q", banner, want)
+				}
+			}
+			for _, secret := range tt.forbidden {
+				if strings.Contains(banner, secret) {
+					t.Errorf("banner %q leaks %q", banner, secret)
+				}
+			}
+			if strings.Contains(banner, "\n") {
+				t.Error("banner spans several lines")
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	refreshControl = "cpuinfo-refresh"
+)
+
+type control struct {
+	ID    string `json:"id"`
+	Human string `json:"human"`
+	Icon  string `json:"icon"`
+	Rank  int    `json:"rank"`
+}
+
+type controlEntry struct {
+	Timestamp time.Time   `json:"timestamp"`
+	Value     controlData `json:"value"`
+}
+
+type controlData struct {
+	Dead bool `json:"dead"`
+}
+
+func getControls() map[string]control {
+	return map[string]control{
+		refreshControl: {
+			ID:    refreshControl,
+			Human: "Refresh CPU and memory info",
+			Icon:  "fa-refresh",
+			Rank:  1,
+		},
+	}
+}
+
+func latestControls(t time.Time) map[string]controlEntry {
+	return map[string]controlEntry{
+		refreshControl: {Timestamp: t, Value: controlData{Dead: false}},
+	}
+}
+
+// Control is called by scope when a control is activated. It is part of the
+// "controller" interface.
+func (p *Plugin) Control(w http.ResponseWriter, r *http.Request) {
+	cfg := p.config()
+	if !authorized(r, cfg.ControlToken) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	xreq := request{}
+	if err := json.NewDecoder(r.Body).Decode(&xreq); err != nil {
+		log.Printf("Bad request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if xreq.NodeID != p.getTopologyHost(cfg.DomainSuffix) {
+		err := fmt.Errorf("unknown node ID %q", xreq.NodeID)
+		log.Printf("Bad request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch xreq.Control {
+	case refreshControl:
+		c, err := p.metrics(r.Context())
+		if err != nil {
+			errorf("%v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p.publish(r.Context(), c)
+	default:
+		err := fmt.Errorf("unknown control %q", xreq.Control)
+		log.Printf("Bad request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rpt, err := p.makeReport(r.Context())
+	if err != nil {
+		errorf("%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	raw, err := marshalResponse(response{ShortcutReport: rpt}, cfg.Pretty)
+	if err != nil {
+		errorf("%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(raw)
+}
+
+// authorized checks the request's "Authorization: Bearer <token>" header
+// against token, rejecting a token without the Bearer scheme. Every request
+// is authorized when token is empty.
+func authorized(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	// The scheme is case-insensitive, as in every HTTP authentication scheme.
+	const scheme = "Bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) < len(scheme) || !strings.EqualFold(h[:len(scheme)], scheme) {
+		return false
+	}
+	got := h[len(scheme):]
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
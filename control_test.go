@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{name: "unset", token: "", header: "", want: http.StatusOK},
+		{name: "authorized", token: "s3cret", header: "Bearer s3cret", want: http.StatusOK},
+		{name: "wrong token", token: "s3cret", header: "Bearer guess", want: http.StatusUnauthorized},
+		{name: "missing header", token: "s3cret", header: "", want: http.StatusUnauthorized},
+		{name: "token prefix", token: "s3cret", header: "Bearer s3c", want: http.StatusUnauthorized},
+		{name: "raw token without the scheme", token: "s3cret", header: "s3cret", want: http.StatusUnauthorized},
+		{name: "other scheme", token: "s3cret", header: "Basic s3cret", want: http.StatusUnauthorized},
+		{name: "lowercase scheme", token: "s3cret", header: "bearer s3cret", want: http.StatusOK},
+		{name: "scheme only", token: "s3cret", header: "Bearer ", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.ControlToken = tt.token
+			p := NewPlugin("host", cfg)
+
+			body := `{"NodeID": "` + p.getTopologyHost(cfg.DomainSuffix) + `", "Control": "` + refreshControl + `"}`
+			req := httptest.NewRequest(http.MethodPost, "/control", strings.NewReader(body))
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q, want Bearer", rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestNoController(t *testing.T) {
+	tests := []struct {
+		name           string
+		noController   bool
+		wantInterfaces []string
+		wantControl    int
+	}{
+		{name: "default", wantInterfaces: []string{"reporter", "controller"}, wantControl: http.StatusOK},
+		{name: "disabled", noController: true, wantInterfaces: []string{"reporter"}, wantControl: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.CollectMode = collectBackground
+			cfg.NoController = tt.noController
+			p := NewPlugin("host", cfg)
+			p.last = goldenCollection(p, time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC))
+
+			rpt, err := p.makeReport(context.Background())
+			if err != nil {
+				t.Fatalf("makeReport: %v", err)
+			}
+			if got := rpt.Plugins[0].Interfaces; !reflect.DeepEqual(got, tt.wantInterfaces) {
+				t.Errorf("interfaces = %v, want %v", got, tt.wantInterfaces)
+			}
+			if hasControls := len(rpt.Host.Controls) > 0; hasControls == tt.noController {
+				t.Errorf("controls = %v with NoController %v", rpt.Host.Controls, tt.noController)
+			}
+
+			body := `{"NodeID": "` + p.getTopologyHost(cfg.DomainSuffix) + `", "Control": "` + refreshControl + `"}`
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/control", strings.NewReader(body)))
+			if rec.Code != tt.wantControl {
+				t.Errorf("/control status = %d, want %d", rec.Code, tt.wantControl)
+			}
+		})
+	}
+}
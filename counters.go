@@ -0,0 +1,30 @@
+package main
+
+// hostCounters returns the numeric values Scope can graph from the node's
+// Counters: CPU utilization, memory used and the 1 minute load, each only
+// when collected.
+func hostCounters(s hostSample) map[string]float64 {
+	counters := map[string]float64{}
+	if s.CPUUsage != nil {
+		counters["cpu_utilization"] = 100 - s.CPUUsage.IdlePercent
+	}
+	if s.Mem.MemTotalBytes > 0 {
+		counters["memory_used_pct"] = s.Mem.UsedPercent
+	}
+	if s.Load != nil {
+		counters["load_1"] = s.Load.Load1
+	}
+	return counters
+}
+
+// prefixCounters is prefixSets for Counters.
+func prefixCounters(counters map[string]float64, prefix string) map[string]float64 {
+	if prefix == "" {
+		return counters
+	}
+	prefixed := make(map[string]float64, len(counters))
+	for k, v := range counters {
+		prefixed[prefix+k] = v
+	}
+	return prefixed
+}
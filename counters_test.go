@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestHostCounters(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample hostSample
+		want   map[string]float64
+	}{
+		{name: "nothing collected", want: map[string]float64{}},
+		{
+			name: "all collected",
+			sample: hostSample{
+				CPUUsage: &CPUUsageStats{IdlePercent: 85},
+				Mem:      MemStats{MemTotalBytes: 8 << 30, UsedPercent: 42.5},
+				Load:     &LoadStats{Load1: 1.25},
+			},
+			want: map[string]float64{"cpu_utilization": 15, "memory_used_pct": 42.5, "load_1": 1.25},
+		},
+		{
+			name:   "memory only",
+			sample: hostSample{Mem: MemStats{MemTotalBytes: 8 << 30, UsedPercent: 10}},
+			want:   map[string]float64{"memory_used_pct": 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hostCounters(tt.sample); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hostCounters = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountersInJSON(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		cfg := loadConfig()
+		cfg.Counters = enabled
+		p := NewPlugin("host", cfg)
+		c, err := p.metrics(context.Background())
+		if err != nil {
+			t.Fatalf("metrics: %v", err)
+		}
+		raw, err := json.Marshal(c.node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			Counters map[string]float64 `json:"counters"`
+		}
+		if err := json.Unmarshal(raw, &got); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := got.Counters["memory_used_pct"]; ok != enabled {
+			t.Errorf("counters = %v with Counters %v", got.Counters, enabled)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// maxCPUFlags is the number of flags kept in the cpu_flags set.
+const maxCPUFlags = 30
+
+// cpuFlagsSets holds the first maxCPUFlags CPU flags as the cpu_flags set,
+// which is left out without flags.
+func cpuFlagsSets(flags []string) map[string][]string {
+	if len(flags) == 0 {
+		return map[string][]string{}
+	}
+	if len(flags) > maxCPUFlags {
+		flags = flags[:maxCPUFlags]
+	}
+	return map[string][]string{
+		"cpu_flags": flags,
+	}
+}
+
+// cpuFlagsLatest emits the full list of CPU flags as a CPU info table row.
+func cpuFlagsLatest(flags []string, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		cpuinfoTablePrefix() + "cpu_flags": {Timestamp: t, Value: strings.Join(flags, " ")},
+	}
+}
+
+// getCPUFlagsSetTemplate describes the cpu_flags set, which Scope truncates
+// to truncate characters.
+func getCPUFlagsSetTemplate(truncate int) map[string]setTemplate {
+	return map[string]setTemplate{
+		"cpu_flags": {
+			ID:       "cpu_flags",
+			Label:    "CPU Flags",
+			Truncate: truncate,
+			Priority: priorityHardware + 1.5,
+		},
+	}
+}
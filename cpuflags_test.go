@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCPUFlagsSets(t *testing.T) {
+	var many []string
+	for i := 0; i < maxCPUFlags+5; i++ {
+		many = append(many, fmt.Sprintf("f%d", i))
+	}
+	tests := []struct {
+		name  string
+		flags []string
+		want  []string
+	}{
+		{name: "none", flags: nil, want: nil},
+		{name: "few", flags: []string{"fpu", "sse2"}, want: []string{"fpu", "sse2"}},
+		{name: "capped", flags: many, want: many[:maxCPUFlags]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cpuFlagsSets(tt.flags)["cpu_flags"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cpu_flags = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetTemplatesReadFromSets(t *testing.T) {
+	reg := NewTemplateRegistry()
+	reg.RegisterSets(getCPUFlagsSetTemplate(40))
+	reg.RegisterSets(getInterfacesSetTemplate())
+	for id, tmpl := range reg.MetadataTemplates() {
+		if tmpl.From != "sets" {
+			t.Errorf("%s: From = %q, want %q", id, tmpl.From, "sets")
+		}
+	}
+	if got := reg.MetadataTemplates()["cpu_flags"].Truncate; got != 40 {
+		t.Errorf("cpu_flags truncate = %d, want 40", got)
+	}
+}
+
+func TestSetKeysDontCollideWithLatest(t *testing.T) {
+	p := NewPlugin("host", loadConfig())
+	c, err := p.metrics(context.Background())
+	if err != nil {
+		t.Fatalf("metrics: %v", err)
+	}
+	for key := range c.node.Sets {
+		if _, ok := c.node.Latest[key]; ok {
+			t.Errorf("%s is both a set and a Latest entry", key)
+		}
+		if from := c.templates.MetadataTemplates()[key].From; from != "sets" {
+			t.Errorf("%s template From = %q, want %q", key, from, "sets")
+		}
+	}
+}
+
+func TestCPUFlagsTruncate(t *testing.T) {
+	var flags []string
+	for i := 0; i < maxCPUFlags+10; i++ {
+		flags = append(flags, fmt.Sprintf("flag%d", i))
+	}
+	tests := []struct {
+		name     string
+		env      string
+		truncate int
+	}{
+		{name: "default", env: "", truncate: 0},
+		{name: "truncated", env: "20", truncate: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CPUINFO_CPU_FLAGS_TRUNCATE", tt.env)
+			cfg := loadConfig()
+			reg := NewTemplateRegistry()
+			reg.RegisterSets(getCPUFlagsSetTemplate(cfg.CPUFlagsTruncate))
+			if got := reg.MetadataTemplates()["cpu_flags"].Truncate; got != tt.truncate {
+				t.Errorf("cpu_flags truncate = %d, want %d", got, tt.truncate)
+			}
+
+			row := cpuFlagsLatest(flags, time.Time{})[cpuinfoTablePrefix()+"cpu_flags"].Value
+			if want := strings.Join(flags, " "); row != want {
+				t.Errorf("table row = %q, want the full list %q", row, want)
+			}
+		})
+	}
+}
+
+func TestCPUFlagsSerialization(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		want  string
+	}{
+		{name: "none", flags: nil, want: `{}`},
+		{name: "some", flags: []string{"fpu", "vme", "avx512f"}, want: `{"sets":{"cpu_flags":["fpu","vme","avx512f"]}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(node{Sets: cpuFlagsSets(tt.flags)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(raw) != tt.want {
+				t.Errorf("node = %s, want %s", raw, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// CPUOnlineStats counts the logical CPUs brought online or offline with CPU
+// hotplug.
+type CPUOnlineStats struct {
+	Online  int
+	Offline int
+}
+
+// getCPUOnlineStats reads <cpuRoot>/online and <cpuRoot>/offline, where
+// cpuRoot is normally /sys/devices/system/cpu.
+func getCPUOnlineStats(ctx context.Context, cpuRoot string, reg *TemplateRegistry) (CPUOnlineStats, error) {
+	online, err := readSysfsString(filepath.Join(cpuRoot, "online"))
+	if err != nil {
+		return CPUOnlineStats{}, &MetricError{Subsystem: "cpu_online", Err: err}
+	}
+	stats := CPUOnlineStats{}
+	if stats.Online, err = parseCPURange(online); err != nil {
+		return CPUOnlineStats{}, &MetricError{Subsystem: "cpu_online", Err: err}
+	}
+	// offline is empty, or missing on old kernels, when all CPUs are online.
+	if offline, err := readSysfsString(filepath.Join(cpuRoot, "offline")); err == nil {
+		if stats.Offline, err = parseCPURange(offline); err != nil {
+			return CPUOnlineStats{}, &MetricError{Subsystem: "cpu_online", Err: err}
+		}
+	}
+
+	if counted, err := cpu.CountsWithContext(ctx, true); err == nil && counted != stats.Online {
+		debugf("sysfs reports %d online CPUs but %d were counted", stats.Online, counted)
+	}
+
+	reg.RegisterMetadata(getCPUOnlineMetadataTemplate())
+	return stats, nil
+}
+
+// parseCPURange counts the CPUs in a sysfs CPU list such as "0-3,6-7".
+func parseCPURange(s string) (int, error) {
+	count := 0
+	for _, part := range strings.Split(strings.TrimSpace(s), ",") {
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return 0, fmt.Errorf("invalid CPU list %q: %v", s, err)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("invalid CPU list %q: %v", s, err)
+			}
+		}
+		if last < first {
+			return 0, fmt.Errorf("invalid CPU list %q", s)
+		}
+		count += last - first + 1
+	}
+	return count, nil
+}
+
+func cpuOnlineLatest(stats CPUOnlineStats, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"cpus_online":  {Timestamp: t, Value: formatNumber(float64(stats.Online), 0)},
+		"cpus_offline": {Timestamp: t, Value: formatNumber(float64(stats.Offline), 0)},
+	}
+}
+
+func getCPUOnlineMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"cpus_online": {
+			ID:       "cpus_online",
+			Label:    "CPUs Online",
+			Datatype: "integer",
+			Priority: priorityHardware + 0.5,
+			From:     "latest",
+		},
+		"cpus_offline": {
+			ID:       "cpus_offline",
+			Label:    "CPUs Offline",
+			Datatype: "integer",
+			Priority: priorityHardware + 0.5,
+			From:     "latest",
+		},
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseCPURange(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "0-3,6-7\n", want: 6},
+		{in: "0", want: 1},
+		{in: "", want: 0},
+		{in: "0-63", want: 64},
+		{in: "1,3,5", want: 3},
+		{in: "3-1", wantErr: true},
+		{in: "a-b", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseCPURange(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseCPURange(%q) = %d, %v, want %d, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestGetCPUOnlineStats(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        CPUOnlineStats
+		wantMissing bool
+	}{
+		{
+			name:  "hotplugged",
+			files: map[string]string{"online": "0-3,6-7\n", "offline": "4-5\n"},
+			want:  CPUOnlineStats{Online: 6, Offline: 2},
+		},
+		{
+			name:  "all online",
+			files: map[string]string{"online": "0-7\n", "offline": "\n"},
+			want:  CPUOnlineStats{Online: 8},
+		},
+		{
+			name:  "old kernel without offline",
+			files: map[string]string{"online": "0-1\n"},
+			want:  CPUOnlineStats{Online: 2},
+		},
+		{
+			name:        "no sysfs",
+			files:       map[string]string{},
+			wantMissing: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getCPUOnlineStats(context.Background(), writeTree(t, tt.files), nil)
+			if sysfsMissing(err) != tt.wantMissing || err != nil && !tt.wantMissing {
+				t.Fatalf("getCPUOnlineStats error = %v, want missing %v", err, tt.wantMissing)
+			}
+			if got != tt.want {
+				t.Errorf("getCPUOnlineStats = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
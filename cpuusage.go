@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// CPUUsageStats is the split of CPU time between two samples, in percent.
+// User, System and Idle add up to ~100 together with nice, iowait, irq and
+// steal time, which are not reported separately.
+type CPUUsageStats struct {
+	UserPercent   float64
+	SystemPercent float64
+	IdlePercent   float64
+}
+
+// cpuTimesSampler keeps the previous aggregate CPU times so that usage can be
+// computed between samples.
+type cpuTimesSampler struct {
+	prev    cpu.TimesStat
+	hasPrev bool
+}
+
+// getCPUUsageStats returns the usage since the previous call. ok is false on
+// the first call and when no CPU time elapsed between samples.
+func (s *cpuTimesSampler) getCPUUsageStats(ctx context.Context, reg *TemplateRegistry) (stats CPUUsageStats, ok bool, err error) {
+	times, err := cpu.TimesWithContext(ctx, false)
+	if err != nil {
+		log.Printf("err=%s", err.Error())
+		return CPUUsageStats{}, false, &MetricError{Subsystem: "cpu_usage", Err: err}
+	}
+	if len(times) == 0 {
+		return CPUUsageStats{}, false, &MetricError{Subsystem: "cpu_usage", Err: ErrNoCPUInfo}
+	}
+	stats, ok = s.update(times[0])
+	if ok {
+		reg.RegisterMetadata(getCPUUsageMetadataTemplate())
+	}
+	return stats, ok, nil
+}
+
+func (s *cpuTimesSampler) update(cur cpu.TimesStat) (CPUUsageStats, bool) {
+	prev, hasPrev := s.prev, s.hasPrev
+	s.prev, s.hasPrev = cur, true
+	if !hasPrev {
+		return CPUUsageStats{}, false
+	}
+
+	total := cur.Total() - prev.Total()
+	if total <= 0 {
+		return CPUUsageStats{}, false
+	}
+	return CPUUsageStats{
+		UserPercent:   100 * (cur.User - prev.User) / total,
+		SystemPercent: 100 * (cur.System - prev.System) / total,
+		IdlePercent:   100 * (cur.Idle - prev.Idle) / total,
+	}, true
+}
+
+func cpuUsageLatest(stats CPUUsageStats, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"cpu_user_percent":   {Timestamp: t, Value: formatPercent(stats.UserPercent)},
+		"cpu_system_percent": {Timestamp: t, Value: formatPercent(stats.SystemPercent)},
+		"cpu_idle_percent":   {Timestamp: t, Value: formatPercent(stats.IdlePercent)},
+	}
+}
+
+func getCPUUsageMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"cpu_user_percent": {
+			ID:       "cpu_user_percent",
+			Label:    "CPU User %",
+			Datatype: "number",
+			Priority: priorityUtilization,
+			From:     "latest",
+		},
+		"cpu_system_percent": {
+			ID:       "cpu_system_percent",
+			Label:    "CPU System %",
+			Datatype: "number",
+			Priority: priorityUtilization,
+			From:     "latest",
+		},
+		"cpu_idle_percent": {
+			ID:       "cpu_idle_percent",
+			Label:    "CPU Idle %",
+			Datatype: "number",
+			Priority: priorityUtilization,
+			From:     "latest",
+		},
+	}
+}
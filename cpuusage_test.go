@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+func TestCPUTimesSamplerUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  cpu.TimesStat
+		second cpu.TimesStat
+		want   CPUUsageStats
+		wantOK bool
+	}{
+		{
+			name:   "usage between snapshots",
+			first:  cpu.TimesStat{User: 100, System: 50, Idle: 800, Iowait: 50},
+			second: cpu.TimesStat{User: 130, System: 60, Idle: 850, Iowait: 60},
+			want:   CPUUsageStats{UserPercent: 30, SystemPercent: 10, IdlePercent: 50},
+			wantOK: true,
+		},
+		{
+			name:   "fully idle",
+			first:  cpu.TimesStat{User: 10, Idle: 100},
+			second: cpu.TimesStat{User: 10, Idle: 200},
+			want:   CPUUsageStats{IdlePercent: 100},
+			wantOK: true,
+		},
+		{
+			name:   "no time elapsed",
+			first:  cpu.TimesStat{User: 10, Idle: 100},
+			second: cpu.TimesStat{User: 10, Idle: 100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s cpuTimesSampler
+			if _, ok := s.update(tt.first); ok {
+				t.Fatal("first update reported usage without a previous sample")
+			}
+			got, ok := s.update(tt.second)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("update = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
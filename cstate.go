@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCStates caps the number of C-states reported.
+const maxCStates = 8
+
+// getCStateStats reads the idle states of cpu0 under cpuRoot, normally
+// /sys/devices/system/cpu, and returns the share of the idle time spent in
+// each, in percent, keyed by lower-cased state name.
+func getCStateStats(ctx context.Context, cpuRoot string, reg *TemplateRegistry) (map[string]float64, error) {
+	dirs, err := filepath.Glob(filepath.Join(cpuRoot, "cpu0", "cpuidle", "state[0-9]*"))
+	if err != nil {
+		return nil, &MetricError{Subsystem: "cstate", Err: err}
+	}
+	if len(dirs) == 0 {
+		return nil, &MetricError{Subsystem: "cstate", Err: os.ErrNotExist}
+	}
+	sort.Slice(dirs, func(i, j int) bool { return stateIndex(dirs[i]) < stateIndex(dirs[j]) })
+	if len(dirs) > maxCStates {
+		dirs = dirs[:maxCStates]
+	}
+
+	times := make(map[string]uint64, len(dirs))
+	var total uint64
+	for _, dir := range dirs {
+		name, err := readSysfsString(filepath.Join(dir, "name"))
+		if err != nil {
+			return nil, &MetricError{Subsystem: "cstate", Err: err}
+		}
+		s, err := readSysfsString(filepath.Join(dir, "time"))
+		if err != nil {
+			return nil, &MetricError{Subsystem: "cstate", Err: err}
+		}
+		us, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, &MetricError{Subsystem: "cstate", Err: err}
+		}
+		times[strings.ToLower(name)] += us
+		total += us
+	}
+
+	stats := make(map[string]float64, len(times))
+	for name, us := range times {
+		if total > 0 {
+			stats[name] = float64(us) / float64(total) * 100
+		} else {
+			stats[name] = 0
+		}
+	}
+	reg.RegisterMetadata(getCStateMetadataTemplate(stats))
+	return stats, nil
+}
+
+func stateIndex(dir string) int {
+	i, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "state"))
+	return i
+}
+
+func cstateKey(name string) string {
+	return "cpu_cstate_" + name + "_pct"
+}
+
+func cstateLatest(stats map[string]float64, t time.Time) map[string]stringEntry {
+	latest := make(map[string]stringEntry, len(stats))
+	for name, pct := range stats {
+		latest[cstateKey(name)] = stringEntry{Timestamp: t, Value: formatPercent(pct)}
+	}
+	return latest
+}
+
+func getCStateMetadataTemplate(stats map[string]float64) map[string]metadataTemplate {
+	templates := make(map[string]metadataTemplate, len(stats))
+	for name := range stats {
+		templates[cstateKey(name)] = metadataTemplate{
+			ID:       cstateKey(name),
+			Label:    "C-state " + strings.ToUpper(name) + " (% of idle)",
+			Datatype: "number",
+			Priority: priorityUtilization + 1.95,
+			From:     "latest",
+		}
+	}
+	return templates
+}
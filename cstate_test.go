@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// cstateTree returns the sysfs files of cpu0's idle states names, with
+// times[i] microseconds spent in names[i].
+func cstateTree(names []string, times []uint64) map[string]string {
+	files := map[string]string{}
+	for i, name := range names {
+		dir := fmt.Sprintf("cpu0/cpuidle/state%d/", i)
+		files[dir+"name"] = name + "\n"
+		files[dir+"time"] = fmt.Sprintf("%d\n", times[i])
+		files[dir+"usage"] = "10\n"
+	}
+	return files
+}
+
+func TestGetCStateStats(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        map[string]float64
+		wantMissing bool
+	}{
+		{
+			name:  "residency shares",
+			files: cstateTree([]string{"POLL", "C1", "C6"}, []uint64{0, 250, 750}),
+			want:  map[string]float64{"poll": 0, "c1": 25, "c6": 75},
+		},
+		{
+			name:  "never idle",
+			files: cstateTree([]string{"POLL", "C1"}, []uint64{0, 0}),
+			want:  map[string]float64{"poll": 0, "c1": 0},
+		},
+		{
+			name: "capped at the first eight states",
+			files: cstateTree(
+				[]string{"S0", "S1", "S2", "S3", "S4", "S5", "S6", "S7", "S8", "S9", "S10"},
+				[]uint64{100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100},
+			),
+			want: map[string]float64{
+				"s0": 12.5, "s1": 12.5, "s2": 12.5, "s3": 12.5,
+				"s4": 12.5, "s5": 12.5, "s6": 12.5, "s7": 12.5,
+			},
+		},
+		{
+			name:        "no cpuidle",
+			files:       map[string]string{"cpu0/online": "1\n"},
+			wantMissing: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewTemplateRegistry()
+			stats, err := getCStateStats(context.Background(), writeTree(t, tt.files), reg)
+			if tt.wantMissing {
+				if !sysfsMissing(err) {
+					t.Fatalf("getCStateStats error = %v, want a missing error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(stats, tt.want) {
+				t.Errorf("getCStateStats = %v, want %v", stats, tt.want)
+			}
+			for name := range tt.want {
+				if _, ok := reg.MetadataTemplates()[cstateKey(name)]; !ok {
+					t.Errorf("no template for %s", cstateKey(name))
+				}
+			}
+		})
+	}
+}
+
+func TestCStateLatest(t *testing.T) {
+	latest := cstateLatest(map[string]float64{"c1": 25, "c6": 75}, time.Time{})
+	want := map[string]string{"cpu_cstate_c1_pct": "25.0", "cpu_cstate_c6_pct": "75.0"}
+	if len(latest) != len(want) {
+		t.Errorf("latest = %v, want %v", latest, want)
+	}
+	for k, v := range want {
+		if got := latest[k].Value; got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
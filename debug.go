@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// debugProcFile returns a handler serving <ProcPath>/<name> as text/plain, to
+// help diagnose detection issues on exotic hardware. It answers 403 unless
+// debug endpoints are enabled.
+func (p *Plugin) debugProcFile(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := p.config()
+		if !cfg.DebugEndpoints {
+			http.Error(w, "debug endpoints are disabled", http.StatusForbidden)
+			return
+		}
+		raw, err := ioutil.ReadFile(filepath.Join(cfg.ProcPath, name))
+		if err != nil {
+			errorf("%v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(raw)
+	}
+}
+
+// Config serves the effective configuration as JSON, keyed by Config field
+// name, with durations in time.ParseDuration syntax and secrets redacted.
+func (p *Plugin) Config(w http.ResponseWriter, r *http.Request) {
+	p.lock.Lock()
+	cfg := p.cfg
+	p.lock.Unlock()
+
+	if !cfg.DebugEndpoints {
+		http.Error(w, "debug endpoints are disabled", http.StatusForbidden)
+		return
+	}
+	raw, err := marshalResponse(redactedConfig(cfg), cfg.Pretty)
+	if err != nil {
+		errorf("%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(raw)
+}
+
+// redactedConfig returns the fields of cfg with the control token replaced
+// by "redacted", like the startup banner, and the InfluxDB password removed.
+func redactedConfig(cfg Config) map[string]interface{} {
+	if cfg.ControlToken != "" {
+		cfg.ControlToken = "redacted"
+	}
+	if u, err := url.Parse(cfg.InfluxURL); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "redacted")
+			cfg.InfluxURL = u.String()
+		}
+	}
+
+	fields := map[string]interface{}{}
+	v := reflect.ValueOf(cfg)
+	for i := 0; i < v.NumField(); i++ {
+		value := v.Field(i).Interface()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		fields[v.Type().Field(i).Name] = value
+	}
+	return fields
+}
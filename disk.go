@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// DiskStats describes a single block device backing at least one mounted
+// partition.
+type DiskStats struct {
+	Name   string
+	Model  string
+	SizeGB int
+	Type   string // "HDD" or "SSD", empty when unknown
+	Health string // SMART overall health, empty when unavailable
+}
+
+// getDiskStats returns one entry per disk device with a mounted partition.
+func getDiskStats(ctx context.Context) ([]DiskStats, error) {
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		log.Printf("err=%s", err.Error())
+		return nil, &MetricError{Subsystem: "disk", Err: err}
+	}
+
+	seen := map[string]bool{}
+	var disks []DiskStats
+	for _, part := range partitions {
+		if !strings.HasPrefix(part.Device, "/dev/") {
+			continue
+		}
+		dev := parentDevice(filepath.Base(part.Device))
+		if seen[dev] {
+			continue
+		}
+		seen[dev] = true
+		disks = append(disks, readDiskStats(ctx, dev))
+	}
+	sort.Slice(disks, func(i, j int) bool { return disks[i].Name < disks[j].Name })
+	return disks, nil
+}
+
+// inodeCriticalPercent is the inode usage above which a mount is flagged.
+const inodeCriticalPercent = 90
+
+// virtualFstypes are glob patterns of filesystems without backing storage,
+// left out of the mount stats unless CPUINFO_DISK_INCLUDE_VIRTUAL is set.
+var virtualFstypes = []string{
+	"tmpfs", "devtmpfs", "proc", "sysfs", "cgroup*", "devpts", "mqueue",
+	"securityfs", "debugfs", "tracefs", "pstore", "bpf", "configfs",
+	"fusectl", "hugetlbfs", "autofs", "binfmt_misc", "nsfs", "rpc_pipefs",
+}
+
+// MountStats describes a mounted filesystem. HasInodes is false for
+// filesystems that don't report inodes.
+type MountStats struct {
+	Mountpoint        string
+	Fstype            string
+	HasInodes         bool
+	InodesUsedPercent float64
+}
+
+// getMountStats returns the type and inode usage of every mounted
+// filesystem, one entry per mountpoint.
+func getMountStats(ctx context.Context, includeVirtual bool, reg *TemplateRegistry) ([]MountStats, error) {
+	partitions, err := disk.PartitionsWithContext(ctx, true)
+	if err != nil {
+		log.Printf("err=%s", err.Error())
+		return nil, &MetricError{Subsystem: "disk", Err: err}
+	}
+
+	var mounts []MountStats
+	for _, part := range filterPartitions(partitions, includeVirtual) {
+		usage, err := disk.UsageWithContext(ctx, part.Mountpoint)
+		if err != nil {
+			debugf("disk usage of %s: %v", part.Mountpoint, err)
+		}
+		mounts = append(mounts, mountStats(part, usage))
+	}
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].Mountpoint < mounts[j].Mountpoint })
+	reg.RegisterMetadata(getMountMetadataTemplate(mounts))
+	return mounts, nil
+}
+
+// mountStats describes part with the inode usage from usage, which is nil
+// when the usage is unknown.
+func mountStats(part disk.PartitionStat, usage *disk.UsageStat) MountStats {
+	m := MountStats{Mountpoint: part.Mountpoint, Fstype: part.Fstype}
+	if usage != nil {
+		m.HasInodes, m.InodesUsedPercent = usage.InodesTotal > 0, usage.InodesUsedPercent
+	}
+	return m
+}
+
+// filterPartitions drops virtual filesystems unless includeVirtual is set,
+// and all but the first partition mounted on the same mountpoint.
+func filterPartitions(partitions []disk.PartitionStat, includeVirtual bool) []disk.PartitionStat {
+	seen := map[string]bool{}
+	var filtered []disk.PartitionStat
+	for _, part := range partitions {
+		if seen[part.Mountpoint] || !includeVirtual && matchAny(virtualFstypes, part.Fstype) {
+			continue
+		}
+		seen[part.Mountpoint] = true
+		filtered = append(filtered, part)
+	}
+	return filtered
+}
+
+// mountKey turns a mountpoint into a Latest key component: "/" is "root",
+// other mountpoints have their slashes and odd characters replaced by "_",
+// e.g. "/var/lib" is "var_lib".
+func mountKey(mountpoint string) string {
+	trimmed := strings.Trim(mountpoint, "/")
+	if trimmed == "" {
+		return "root"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, trimmed)
+}
+
+func mountLatest(mounts []MountStats, t time.Time) map[string]stringEntry {
+	latest := map[string]stringEntry{}
+	for _, m := range mounts {
+		key := "disk_" + mountKey(m.Mountpoint)
+		latest[key+"_fstype"] = stringEntry{Timestamp: t, Value: m.Fstype}
+		if !m.HasInodes {
+			continue
+		}
+		latest[key+"_inode_used_pct"] = stringEntry{Timestamp: t, Value: formatPercent(m.InodesUsedPercent)}
+		if m.InodesUsedPercent > inodeCriticalPercent {
+			latest[key+"_inode_critical"] = stringEntry{Timestamp: t, Value: "true"}
+		}
+	}
+	return latest
+}
+
+// InodeStats holds the inode usage of a filesystem.
+type InodeStats struct {
+	UsedPercent float64
+	Free        uint64
+}
+
+// getRootInodeStats returns the inode usage of the root filesystem. ok is
+// false when the filesystem doesn't report inodes, as some network
+// filesystems don't.
+func getRootInodeStats(ctx context.Context, reg *TemplateRegistry) (InodeStats, bool, error) {
+	usage, err := disk.UsageWithContext(ctx, "/")
+	if err != nil {
+		return InodeStats{}, false, &MetricError{Subsystem: "inodes", Err: err}
+	}
+	stats, ok := inodeStats(usage)
+	if ok {
+		reg.RegisterMetadata(getInodeMetadataTemplate())
+	}
+	return stats, ok, nil
+}
+
+func inodeStats(usage *disk.UsageStat) (InodeStats, bool) {
+	if usage.InodesTotal == 0 {
+		return InodeStats{}, false
+	}
+	return InodeStats{UsedPercent: usage.InodesUsedPercent, Free: usage.InodesFree}, true
+}
+
+func inodeLatest(stats InodeStats, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"inodes_used_percent": {Timestamp: t, Value: formatPercent(stats.UsedPercent)},
+		"inodes_free":         {Timestamp: t, Value: strconv.FormatUint(stats.Free, 10)},
+	}
+}
+
+func getInodeMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"inodes_used_percent": {
+			ID:       "inodes_used_percent",
+			Label:    "Root Inodes Used (%)",
+			Datatype: "number",
+			Priority: priorityUtilization + 1.8,
+			From:     "latest",
+		},
+		"inodes_free": {
+			ID:       "inodes_free",
+			Label:    "Root Inodes Free",
+			Datatype: "number",
+			Priority: priorityUtilization + 1.8,
+			From:     "latest",
+		},
+	}
+}
+
+func getMountMetadataTemplate(mounts []MountStats) map[string]metadataTemplate {
+	templates := map[string]metadataTemplate{}
+	for _, m := range mounts {
+		key := "disk_" + mountKey(m.Mountpoint)
+		templates[key+"_fstype"] = metadataTemplate{
+			ID:       key + "_fstype",
+			Label:    m.Mountpoint + " filesystem",
+			Datatype: "string",
+			Priority: prioritySoftware + 1.5,
+			From:     "latest",
+		}
+		if !m.HasInodes {
+			continue
+		}
+		templates[key+"_inode_used_pct"] = metadataTemplate{
+			ID:       key + "_inode_used_pct",
+			Label:    m.Mountpoint + " inodes used (%)",
+			Datatype: "number",
+			Priority: priorityUtilization + 1.81,
+			From:     "latest",
+		}
+		if m.InodesUsedPercent > inodeCriticalPercent {
+			templates[key+"_inode_critical"] = metadataTemplate{
+				ID:       key + "_inode_critical",
+				Label:    m.Mountpoint + " inodes critical",
+				Priority: priorityUtilization + 1.82,
+				From:     "latest",
+			}
+		}
+	}
+	return templates
+}
+
+func (p *Plugin) getTopologyDisk(dev string) string {
+	return fmt.Sprintf("%s;%s", p.HostID, dev)
+}
+
+// diskTopology builds the Disk topology, with an edge from every disk node
+// to the host node. Keys and template IDs carry the key prefix.
+func (p *Plugin) diskTopology(disks []DiskStats, hostNodeID string, t time.Time) *topology {
+	prefix := p.config().KeyPrefix
+	nodes := make(map[string]node, len(disks))
+	for _, d := range disks {
+		latest := map[string]stringEntry{
+			"disk_model":   {Timestamp: t, Value: d.Model},
+			"disk_size_gb": {Timestamp: t, Value: formatNumber(float64(d.SizeGB), 0)},
+		}
+		if d.Type != "" {
+			latest["disk_type"] = stringEntry{Timestamp: t, Value: d.Type}
+		}
+		if d.Health != "" {
+			latest["disk_health"] = stringEntry{Timestamp: t, Value: d.Health}
+		}
+		nodes[p.getTopologyDisk(d.Name)] = node{
+			Latest:    prefixLatest(latest, prefix),
+			Adjacency: []string{hostNodeID},
+		}
+	}
+	reg := NewTemplateRegistry()
+	reg.RegisterMetadata(getDiskMetadataTemplate())
+	return &topology{
+		Nodes:             nodes,
+		MetadataTemplates: reg.prefixed(prefix).MetadataTemplates(),
+	}
+}
+
+// diskHostEdges returns an edge from every node of the Disk topology to the
+// host node, for the Host topology's Adjacency.
+func diskHostEdges(disk *topology, hostID string) map[string][]string {
+	if len(disk.Nodes) == 0 {
+		return nil
+	}
+	edges := make(map[string][]string, len(disk.Nodes))
+	for id := range disk.Nodes {
+		edges[id] = []string{hostID}
+	}
+	return edges
+}
+
+func getDiskMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"disk_model": {
+			ID:       "disk_model",
+			Label:    "Disk Model",
+			Priority: 1,
+			From:     "latest",
+		},
+		"disk_size_gb": {
+			ID:       "disk_size_gb",
+			Label:    "Disk Size (GB)",
+			Datatype: "integer",
+			Priority: 2,
+			From:     "latest",
+		},
+		"disk_type": {
+			ID:       "disk_type",
+			Label:    "Disk Type",
+			Priority: 3,
+			From:     "latest",
+		},
+		"disk_health": {
+			ID:       "disk_health",
+			Label:    "Disk Health",
+			Priority: 4,
+			From:     "latest",
+		},
+	}
+}
@@ -0,0 +1,78 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sysBlockPath    = "/sys/block"
+	sysClassBlock   = "/sys/class/block"
+	smartctlTimeout = 5 * time.Second
+)
+
+// parentDevice maps a partition name (sda1, nvme0n1p2) to the disk holding it.
+func parentDevice(name string) string {
+	resolved, err := filepath.EvalSymlinks(filepath.Join(sysClassBlock, name))
+	if err != nil {
+		return name
+	}
+	if _, err := os.Stat(filepath.Join(resolved, "partition")); err == nil {
+		return filepath.Base(filepath.Dir(resolved))
+	}
+	return name
+}
+
+func readDiskStats(ctx context.Context, dev string) DiskStats {
+	stats := DiskStats{Name: dev}
+	base := filepath.Join(sysBlockPath, dev)
+
+	if model, err := readSysfsString(filepath.Join(base, "device", "model")); err == nil {
+		stats.Model = model
+	}
+	if size, err := readSysfsString(filepath.Join(base, "size")); err == nil {
+		// size is always expressed in 512-byte sectors
+		if sectors, err := strconv.ParseUint(size, 10, 64); err == nil {
+			stats.SizeGB = int(sectors * 512 / 1000 / 1000 / 1000)
+		}
+	}
+	if rotational, err := readSysfsString(filepath.Join(base, "queue", "rotational")); err == nil {
+		stats.Type = "SSD"
+		if rotational == "1" {
+			stats.Type = "HDD"
+		}
+	}
+	stats.Health = getSMARTHealth(ctx, dev)
+	return stats
+}
+
+// getSMARTHealth runs `smartctl -H` for dev, returning "" if smartctl is not
+// installed or the result could not be determined.
+func getSMARTHealth(ctx context.Context, dev string) string {
+	path, err := exec.LookPath("smartctl")
+	if err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, smartctlTimeout)
+	defer cancel()
+	// smartctl uses its exit status as a bit mask, so only the output matters.
+	out, _ := exec.CommandContext(ctx, path, "-H", "/dev/"+dev).Output()
+	for _, line := range strings.Split(string(out), "\n") {
+		// ATA: "SMART overall-health self-assessment test result: PASSED"
+		// SCSI: "SMART Health Status: OK"
+		if strings.Contains(line, "overall-health") || strings.Contains(line, "Health Status") {
+			if i := strings.LastIndex(line, ":"); i >= 0 {
+				return strings.TrimSpace(line[i+1:])
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "context"
+
+// Disk model, type and health come from sysfs and are only available on Linux.
+
+func parentDevice(name string) string {
+	return name
+}
+
+func readDiskStats(ctx context.Context, dev string) DiskStats {
+	return DiskStats{Name: dev}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+func TestDiskTopologyFromCollection(t *testing.T) {
+	tests := []struct {
+		name   string
+		disks  []DiskStats
+		nodes  map[string]map[string]string
+		noDisk bool
+	}{
+		{
+			name: "ssd and hdd",
+			disks: []DiskStats{
+				{Name: "nvme0n1", Model: "Samsung SSD 980", SizeGB: 1000, Type: "SSD", Health: "PASSED"},
+				{Name: "sda", Model: "WDC WD40EFRX", SizeGB: 4000, Type: "HDD"},
+			},
+			nodes: map[string]map[string]string{
+				"host;nvme0n1": {"disk_model": "Samsung SSD 980", "disk_size_gb": "1000", "disk_type": "SSD", "disk_health": "PASSED"},
+				"host;sda":     {"disk_model": "WDC WD40EFRX", "disk_size_gb": "4000", "disk_type": "HDD"},
+			},
+		},
+		{
+			name:  "no disks",
+			disks: []DiskStats{},
+			nodes: map[string]map[string]string{},
+		},
+		{
+			name:   "disabled",
+			noDisk: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.CollectMode = collectBackground
+			cfg.DiskTopology = !tt.noDisk
+			p := NewPlugin("host", cfg)
+			now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+			p.now = func() time.Time { return now }
+			p.last = &collection{node: node{Latest: map[string]stringEntry{}}, templates: NewTemplateRegistry(), disks: tt.disks}
+
+			// Reports are built from the cached disks, without running
+			// smartctl again.
+			for i := 0; i < 2; i++ {
+				rpt, err := p.makeReport(context.Background())
+				if err != nil {
+					t.Fatalf("makeReport: %v", err)
+				}
+				if tt.noDisk {
+					if rpt.Disk != nil {
+						t.Errorf("Disk topology = %+v, want none", rpt.Disk)
+					}
+					return
+				}
+				got := map[string]map[string]string{}
+				for id, n := range rpt.Disk.Nodes {
+					got[id] = map[string]string{}
+					for k, v := range n.Latest {
+						got[id][k] = v.Value
+					}
+					if !reflect.DeepEqual(n.Adjacency, []string{"host;<host>"}) {
+						t.Errorf("%s adjacency = %v", id, n.Adjacency)
+					}
+					if !reflect.DeepEqual(rpt.Host.Adjacency[id], []string{"host;<host>"}) {
+						t.Errorf("host adjacency of %s = %v", id, rpt.Host.Adjacency[id])
+					}
+				}
+				if !reflect.DeepEqual(got, tt.nodes) {
+					t.Errorf("disk nodes = %v, want %v", got, tt.nodes)
+				}
+			}
+		})
+	}
+}
+
+func TestDiskHostEdgesSerialized(t *testing.T) {
+	tests := []struct {
+		name  string
+		disks []DiskStats
+		want  map[string][]string
+	}{
+		{
+			name:  "two disks",
+			disks: []DiskStats{{Name: "nvme0n1", Type: "SSD"}, {Name: "sda", Type: "HDD"}},
+			want:  map[string][]string{"host;nvme0n1": {"host;<host>"}, "host;sda": {"host;<host>"}},
+		},
+		{name: "no disks", disks: []DiskStats{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.CollectMode = collectBackground
+			cfg.DiskTopology = true
+			p := NewPlugin("host", cfg)
+			p.last = &collection{node: node{Latest: map[string]stringEntry{}}, templates: NewTemplateRegistry(), disks: tt.disks}
+
+			rpt, err := p.makeReport(context.Background())
+			if err != nil {
+				t.Fatalf("makeReport: %v", err)
+			}
+			raw, err := json.Marshal(rpt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validateReport(raw); err != nil {
+				t.Errorf("invalid report: %v", err)
+			}
+			var got struct {
+				Host struct {
+					Adjacency map[string][]string `json:"adjacency"`
+				}
+			}
+			if err := json.Unmarshal(raw, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Host.Adjacency, tt.want) {
+				t.Errorf("Host adjacency = %v, want %v", got.Host.Adjacency, tt.want)
+			}
+		})
+	}
+}
+
+func TestMountInodeUsage(t *testing.T) {
+	tests := []struct {
+		name   string
+		part   disk.PartitionStat
+		usage  *disk.UsageStat
+		want   map[string]string
+		absent []string
+	}{
+		{
+			name:  "critical",
+			part:  disk.PartitionStat{Mountpoint: "/var/lib", Fstype: "ext4"},
+			usage: &disk.UsageStat{InodesTotal: 1000, InodesUsedPercent: 95},
+			want: map[string]string{
+				"disk_var_lib_fstype":         "ext4",
+				"disk_var_lib_inode_used_pct": "95.0",
+				"disk_var_lib_inode_critical": "true",
+			},
+		},
+		{
+			name:   "healthy root",
+			part:   disk.PartitionStat{Mountpoint: "/", Fstype: "xfs"},
+			usage:  &disk.UsageStat{InodesTotal: 1000, InodesUsedPercent: 12.5},
+			want:   map[string]string{"disk_root_inode_used_pct": "12.5"},
+			absent: []string{"disk_root_inode_critical"},
+		},
+		{
+			name:   "no inodes",
+			part:   disk.PartitionStat{Mountpoint: "/mnt/nfs", Fstype: "nfs4"},
+			usage:  &disk.UsageStat{},
+			want:   map[string]string{"disk_mnt_nfs_fstype": "nfs4"},
+			absent: []string{"disk_mnt_nfs_inode_used_pct"},
+		},
+		{
+			name:   "unknown usage",
+			part:   disk.PartitionStat{Mountpoint: "/boot", Fstype: "vfat"},
+			want:   map[string]string{"disk_boot_fstype": "vfat"},
+			absent: []string{"disk_boot_inode_used_pct"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mounts := []MountStats{mountStats(tt.part, tt.usage)}
+			latest := mountLatest(mounts, time.Time{})
+			templates := getMountMetadataTemplate(mounts)
+			for key, want := range tt.want {
+				if got := latest[key].Value; got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+				if _, ok := templates[key]; !ok {
+					t.Errorf("%s has no template", key)
+				}
+			}
+			for _, key := range tt.absent {
+				if _, ok := latest[key]; ok {
+					t.Errorf("unexpected %s", key)
+				}
+			}
+		})
+	}
+}
+
+func TestRootInodeStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		usage  *disk.UsageStat
+		wantOK bool
+		want   map[string]string
+	}{
+		{
+			name:   "ext4",
+			usage:  &disk.UsageStat{InodesTotal: 6553600, InodesUsed: 301466, InodesFree: 6252134, InodesUsedPercent: 4.6},
+			wantOK: true,
+			want:   map[string]string{"inodes_used_percent": "4.6", "inodes_free": "6252134"},
+		},
+		{
+			name:   "exhausted",
+			usage:  &disk.UsageStat{InodesTotal: 1000, InodesUsed: 1000, InodesUsedPercent: 100},
+			wantOK: true,
+			want:   map[string]string{"inodes_used_percent": "100.0", "inodes_free": "0"},
+		},
+		{
+			name:  "network filesystem without inodes",
+			usage: &disk.UsageStat{Total: 1 << 40, Used: 1 << 30},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, ok := inodeStats(tt.usage)
+			if ok != tt.wantOK {
+				t.Fatalf("inodeStats ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			latest := inodeLatest(stats, time.Time{})
+			if len(latest) != len(tt.want) {
+				t.Errorf("latest = %v, want %v", latest, tt.want)
+			}
+			for key, want := range tt.want {
+				if got := latest[key].Value; got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterPartitions(t *testing.T) {
+	partitions := []disk.PartitionStat{
+		{Mountpoint: "/", Fstype: "ext4"},
+		{Mountpoint: "/data", Fstype: "xfs"},
+		{Mountpoint: "/home", Fstype: "btrfs"},
+		{Mountpoint: "/dev/shm", Fstype: "tmpfs"},
+		{Mountpoint: "/proc", Fstype: "proc"},
+		{Mountpoint: "/sys/fs/cgroup", Fstype: "cgroup2"},
+		{Mountpoint: "/data", Fstype: "overlay"},
+	}
+	tests := []struct {
+		name           string
+		includeVirtual bool
+		want           []string
+	}{
+		{name: "real only", includeVirtual: false, want: []string{"/:ext4", "/data:xfs", "/home:btrfs"}},
+		{
+			name:           "with virtual",
+			includeVirtual: true,
+			want:           []string{"/:ext4", "/data:xfs", "/home:btrfs", "/dev/shm:tmpfs", "/proc:proc", "/sys/fs/cgroup:cgroup2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, part := range filterPartitions(partitions, tt.includeVirtual) {
+				got = append(got, part.Mountpoint+":"+part.Fstype)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterPartitions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFstypeTemplates(t *testing.T) {
+	mounts := []MountStats{{Mountpoint: "/", Fstype: "ext4"}, {Mountpoint: "/var/lib/docker", Fstype: "xfs"}}
+	templates := getMountMetadataTemplate(mounts)
+	for key, want := range map[string]string{"disk_root_fstype": "ext4", "disk_var_lib_docker_fstype": "xfs"} {
+		if got := mountLatest(mounts, time.Time{})[key].Value; got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+		if templates[key].Datatype != "string" {
+			t.Errorf("%s datatype = %q, want string", key, templates[key].Datatype)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+const (
+	diskIOTablePrefix = "cpuinfo-diskio-table-"
+)
+
+// DiskIOStats holds per-device throughput derived from two consecutive
+// samples.
+type DiskIOStats struct {
+	Devices map[string]DiskIODeviceStats
+}
+
+type DiskIODeviceStats struct {
+	ReadBytesPerSec  float64
+	WriteBytesPerSec float64
+}
+
+// diskIOSampler keeps the previous per-device counters so that rates can be
+// computed between samples.
+type diskIOSampler struct {
+	deny     []string
+	prev     map[string]disk.IOCountersStat
+	prevTime time.Time
+}
+
+func (s *diskIOSampler) getDiskIOStats(ctx context.Context, reg *TemplateRegistry) (DiskIOStats, error) {
+	counters, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		log.Printf("err=%s", err.Error())
+		return DiskIOStats{}, &MetricError{Subsystem: "diskio", Err: err}
+	}
+	reg.RegisterTables(getDiskIOTableTemplate())
+	return s.update(counters, time.Now()), nil
+}
+
+// update records a new snapshot and returns the rates since the previous one.
+// Devices without a previous sample are left out until the next update.
+func (s *diskIOSampler) update(counters map[string]disk.IOCountersStat, now time.Time) DiskIOStats {
+	stats := DiskIOStats{Devices: map[string]DiskIODeviceStats{}}
+	current := make(map[string]disk.IOCountersStat, len(counters))
+	elapsed := now.Sub(s.prevTime).Seconds()
+
+	for name, c := range counters {
+		if matchAny(s.deny, name) {
+			continue
+		}
+		current[name] = c
+
+		prev, ok := s.prev[name]
+		if !ok || elapsed <= 0 || c.ReadBytes < prev.ReadBytes || c.WriteBytes < prev.WriteBytes {
+			continue
+		}
+		stats.Devices[name] = DiskIODeviceStats{
+			ReadBytesPerSec:  float64(c.ReadBytes-prev.ReadBytes) / elapsed,
+			WriteBytesPerSec: float64(c.WriteBytes-prev.WriteBytes) / elapsed,
+		}
+	}
+
+	s.prev = current
+	s.prevTime = now
+	return stats
+}
+
+func diskIOLatest(stats DiskIOStats, t time.Time) map[string]stringEntry {
+	names := make([]string, 0, len(stats.Devices))
+	for name := range stats.Devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	latest := map[string]stringEntry{}
+	for _, name := range names {
+		dev := stats.Devices[name]
+		latest[diskIOTablePrefix+name+tableEntryKeySeparator+"read_bytes_per_sec"] = stringEntry{
+			Timestamp: t,
+			Value:     formatNumber(dev.ReadBytesPerSec, 0),
+		}
+		latest[diskIOTablePrefix+name+tableEntryKeySeparator+"write_bytes_per_sec"] = stringEntry{
+			Timestamp: t,
+			Value:     formatNumber(dev.WriteBytesPerSec, 0),
+		}
+	}
+	return latest
+}
+
+func getDiskIOTableTemplate() map[string]tableTemplate {
+	return map[string]tableTemplate{
+		"cpuinfo-diskio-table": {
+			ID:     "cpuinfo-diskio-table",
+			Label:  "Disk I/O",
+			Prefix: diskIOTablePrefix,
+			Type:   multicolumnTableType,
+			Columns: []column{
+				{ID: "read_bytes_per_sec", Label: "Read (B/s)", DataType: "number"},
+				{ID: "write_bytes_per_sec", Label: "Write (B/s)", DataType: "number"},
+			},
+		},
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+func TestDiskIOSamplerUpdate(t *testing.T) {
+	start := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		first  map[string]disk.IOCountersStat
+		second map[string]disk.IOCountersStat
+		want   map[string]DiskIODeviceStats
+	}{
+		{
+			name: "rates over 10s",
+			first: map[string]disk.IOCountersStat{
+				"sda":     {ReadBytes: 1000, WriteBytes: 2000},
+				"nvme0n1": {ReadBytes: 0, WriteBytes: 0},
+			},
+			second: map[string]disk.IOCountersStat{
+				"sda":     {ReadBytes: 11000, WriteBytes: 2000},
+				"nvme0n1": {ReadBytes: 5000, WriteBytes: 100000},
+			},
+			want: map[string]DiskIODeviceStats{
+				"sda":     {ReadBytesPerSec: 1000, WriteBytesPerSec: 0},
+				"nvme0n1": {ReadBytesPerSec: 500, WriteBytesPerSec: 10000},
+			},
+		},
+		{
+			name:   "new device waits for a second sample",
+			first:  map[string]disk.IOCountersStat{"sda": {ReadBytes: 1000}},
+			second: map[string]disk.IOCountersStat{"sda": {ReadBytes: 1000}, "sdb": {ReadBytes: 500}},
+			want:   map[string]DiskIODeviceStats{"sda": {}},
+		},
+		{
+			name:   "counter reset",
+			first:  map[string]disk.IOCountersStat{"sda": {ReadBytes: 1000}},
+			second: map[string]disk.IOCountersStat{"sda": {ReadBytes: 10}},
+			want:   map[string]DiskIODeviceStats{},
+		},
+		{
+			name:   "denied devices",
+			first:  map[string]disk.IOCountersStat{"loop0": {ReadBytes: 0}},
+			second: map[string]disk.IOCountersStat{"loop0": {ReadBytes: 1000}},
+			want:   map[string]DiskIODeviceStats{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &diskIOSampler{deny: []string{"loop*"}}
+			s.update(tt.first, start)
+			got := s.update(tt.second, start.Add(10*time.Second))
+			if len(got.Devices) != len(tt.want) {
+				t.Fatalf("devices = %v, want %v", got.Devices, tt.want)
+			}
+			for name, want := range tt.want {
+				if got.Devices[name] != want {
+					t.Errorf("%s = %+v, want %+v", name, got.Devices[name], want)
+				}
+			}
+		})
+	}
+}
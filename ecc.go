@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultEDACPath = "/sys/devices/system/edac/mc"
+
+// ECCStats sums the memory errors counted by the EDAC memory controllers.
+// Growing correctable counts are an early sign of a failing DIMM.
+type ECCStats struct {
+	Correctable   int
+	Uncorrectable int
+}
+
+// getECCStats sums ce_count and ue_count of the memory controllers mc* under
+// root, normally /sys/devices/system/edac/mc. It returns an os.ErrNotExist
+// error when EDAC isn't loaded, as on hosts without ECC memory.
+func getECCStats(ctx context.Context, root string, reg *TemplateRegistry) (ECCStats, error) {
+	controllers, err := filepath.Glob(filepath.Join(root, "mc[0-9]*"))
+	if err != nil {
+		return ECCStats{}, &MetricError{Subsystem: "ecc", Err: err}
+	}
+	if len(controllers) == 0 {
+		return ECCStats{}, &MetricError{Subsystem: "ecc", Err: os.ErrNotExist}
+	}
+	var stats ECCStats
+	for _, mc := range controllers {
+		ce, err := readSysfsInt(filepath.Join(mc, "ce_count"))
+		if err != nil {
+			return ECCStats{}, &MetricError{Subsystem: "ecc", Err: err}
+		}
+		ue, err := readSysfsInt(filepath.Join(mc, "ue_count"))
+		if err != nil {
+			return ECCStats{}, &MetricError{Subsystem: "ecc", Err: err}
+		}
+		stats.Correctable += ce
+		stats.Uncorrectable += ue
+	}
+	reg.RegisterMetadata(getECCMetadataTemplate())
+	return stats, nil
+}
+
+func eccLatest(stats ECCStats, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"ecc_correctable":   {Timestamp: t, Value: formatNumber(float64(stats.Correctable), 0)},
+		"ecc_uncorrectable": {Timestamp: t, Value: formatNumber(float64(stats.Uncorrectable), 0)},
+	}
+}
+
+func getECCMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"ecc_correctable": {
+			ID:       "ecc_correctable",
+			Label:    "ECC Correctable Errors",
+			Datatype: "integer",
+			Priority: priorityHardware + 1.8,
+			From:     "latest",
+		},
+		"ecc_uncorrectable": {
+			ID:       "ecc_uncorrectable",
+			Label:    "ECC Uncorrectable Errors",
+			Datatype: "integer",
+			Priority: priorityHardware + 1.8,
+			From:     "latest",
+		},
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetECCStats(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        ECCStats
+		wantMissing bool
+		wantErr     bool
+	}{
+		{
+			name: "two controllers",
+			files: map[string]string{
+				"mc0/ce_count": "3\n", "mc0/ue_count": "0\n",
+				"mc1/ce_count": "14\n", "mc1/ue_count": "1\n",
+			},
+			want: ECCStats{Correctable: 17, Uncorrectable: 1},
+		},
+		{
+			name:  "clean",
+			files: map[string]string{"mc0/ce_count": "0\n", "mc0/ue_count": "0\n"},
+		},
+		{
+			name:        "EDAC not loaded",
+			files:       map[string]string{"power/control": "auto\n"},
+			wantMissing: true,
+		},
+		{
+			name:        "controller without ue_count",
+			files:       map[string]string{"mc0/ce_count": "2\n"},
+			wantMissing: true,
+		},
+		{
+			name:    "garbage count",
+			files:   map[string]string{"mc0/ce_count": "many\n", "mc0/ue_count": "0\n"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewTemplateRegistry()
+			got, err := getECCStats(context.Background(), writeTree(t, tt.files), reg)
+			if sysfsMissing(err) != tt.wantMissing {
+				t.Fatalf("getECCStats error = %v, want missing %v", err, tt.wantMissing)
+			}
+			if (err != nil) != (tt.wantErr || tt.wantMissing) {
+				t.Fatalf("getECCStats error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("getECCStats = %+v, want %+v", got, tt.want)
+			}
+			if len(reg.MetadataTemplates()) != 2 {
+				t.Errorf("templates = %v, want ecc_correctable and ecc_uncorrectable", reg.MetadataTemplates())
+			}
+		})
+	}
+}
+
+func TestECCLatest(t *testing.T) {
+	latest := eccLatest(ECCStats{Correctable: 17, Uncorrectable: 1}, time.Time{})
+	if got := latest["ecc_correctable"].Value; got != "17" {
+		t.Errorf("ecc_correctable = %q, want 17", got)
+	}
+	if got := latest["ecc_uncorrectable"].Value; got != "1" {
+		t.Errorf("ecc_uncorrectable = %q, want 1", got)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// smoothedKeys lists the usage and rate metrics that jump around between
+// samples and get an exponential moving average.
+var smoothedKeys = []string{
+	"cpu_user_percent",
+	"cpu_system_percent",
+	"cpu_idle_percent",
+	"net_rx_bytes_per_sec",
+	"net_tx_bytes_per_sec",
+	"net_total_errors_per_sec",
+	"net_tcp_retransmits_per_sec",
+	"swap_in_bytes_sec",
+	"swap_out_bytes_sec",
+}
+
+// emaSmoother keeps an exponential moving average of each smoothed key:
+// ema = alpha*value + (1-alpha)*ema. The first value of a key initializes its
+// average.
+type emaSmoother struct {
+	alpha  float64
+	values map[string]float64
+}
+
+// update feeds value for key and returns the new average.
+func (s *emaSmoother) update(key string, value float64) float64 {
+	if s.values == nil {
+		s.values = map[string]float64{}
+	}
+	prev, ok := s.values[key]
+	if ok {
+		value = s.alpha*value + (1-s.alpha)*prev
+	}
+	s.values[key] = value
+	return value
+}
+
+// smooth adds to latest a <key>_ema entry, and template, for every smoothed
+// key in raw, which holds the collected values before formatting. Keys
+// missing from a collection keep their average for the next.
+func (s *emaSmoother) smooth(latest map[string]stringEntry, raw map[string]float64, reg *TemplateRegistry, t time.Time) {
+	templates := reg.MetadataTemplates()
+	for _, key := range smoothedKeys {
+		value, ok := raw[key]
+		if !ok {
+			continue
+		}
+		avg := s.update(key, value)
+		formatted := formatNumber(avg, 2)
+		if strings.HasSuffix(key, "_percent") {
+			formatted = formatPercent(avg)
+		}
+		latest[key+"_ema"] = stringEntry{Timestamp: t, Value: formatted}
+		if tmpl, ok := templates[key]; ok {
+			tmpl.ID = key + "_ema"
+			tmpl.Label += " (smoothed)"
+			tmpl.Priority += 0.001
+			reg.RegisterMetadata(map[string]metadataTemplate{tmpl.ID: tmpl})
+		}
+	}
+}
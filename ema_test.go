@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEMAStepChange(t *testing.T) {
+	tests := []struct {
+		name  string
+		alpha float64
+		steps []float64
+		want  []float64
+	}{
+		{
+			name:  "first sample initializes",
+			alpha: 0.5,
+			steps: []float64{40},
+			want:  []float64{40},
+		},
+		{
+			name:  "step from 0 to 100",
+			alpha: 0.5,
+			steps: []float64{0, 100, 100, 100, 100},
+			want:  []float64{0, 50, 75, 87.5, 93.75},
+		},
+		{
+			name:  "alpha 1 follows the input",
+			alpha: 1,
+			steps: []float64{10, 90, 30},
+			want:  []float64{10, 90, 30},
+		},
+		{
+			name:  "small alpha",
+			alpha: 0.1,
+			steps: []float64{100, 0, 0},
+			want:  []float64{100, 90, 81},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := emaSmoother{alpha: tt.alpha}
+			for i, v := range tt.steps {
+				if got := s.update("k", v); math.Abs(got-tt.want[i]) > 1e-9 {
+					t.Errorf("step %d: ema = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEMAConverges(t *testing.T) {
+	s := emaSmoother{alpha: 0.3}
+	s.update("k", 0)
+	var got float64
+	for i := 0; i < 50; i++ {
+		got = s.update("k", 100)
+	}
+	if math.Abs(got-100) > 0.01 {
+		t.Errorf("ema after 50 samples of 100 = %v, want ~100", got)
+	}
+}
+
+func TestEMASmoothRawValues(t *testing.T) {
+	setPercentPrecision(1)
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := emaSmoother{alpha: 0.5}
+	reg := NewTemplateRegistry()
+	reg.RegisterMetadata(getCPUUsageMetadataTemplate())
+
+	samples := []map[string]float64{
+		// Values that the 1-decimal Latest format would round.
+		{"cpu_user_percent": 10.04, "net_rx_bytes_per_sec": 1000.4},
+		{"cpu_user_percent": 20.04, "net_rx_bytes_per_sec": 2000.4},
+	}
+	var latest map[string]stringEntry
+	for _, raw := range samples {
+		latest = map[string]stringEntry{}
+		s.smooth(latest, raw, reg, now)
+	}
+	want := map[string]string{
+		"cpu_user_percent_ema":     "15.0",
+		"net_rx_bytes_per_sec_ema": "1500.40",
+	}
+	for k, v := range want {
+		if got := latest[k].Value; got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if _, ok := latest["cpu_system_percent_ema"]; ok {
+		t.Error("cpu_system_percent_ema set without a raw value")
+	}
+	tmpl, ok := reg.MetadataTemplates()["cpu_user_percent_ema"]
+	if !ok || tmpl.Label != "CPU User % (smoothed)" {
+		t.Errorf("cpu_user_percent_ema template = %+v", tmpl)
+	}
+}
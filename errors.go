@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	// ErrNoCPUInfo is returned when the CPU list is empty.
+	ErrNoCPUInfo = errors.New("no CPU info available")
+	// ErrNoMemInfo is returned when the total memory is unknown.
+	ErrNoMemInfo = errors.New("no memory info available")
+	// ErrPermissionDenied matches any MetricError caused by missing
+	// permissions, e.g. reading a root-only sysfs file.
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// MetricError is returned by the get*Stats collectors, identifying the
+// subsystem that failed.
+type MetricError struct {
+	Subsystem string
+	Err       error
+}
+
+func (e *MetricError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Subsystem, e.Err)
+}
+
+func (e *MetricError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is(err, ErrPermissionDenied) hold for permission errors
+// from the os package.
+func (e *MetricError) Is(target error) bool {
+	return target == ErrPermissionDenied && errors.Is(e.Err, os.ErrPermission)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMetricError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		target     error
+		wantIs     bool
+		wantSubsys string
+	}{
+		{
+			name:       "no cpu info",
+			err:        &MetricError{Subsystem: "cpu", Err: ErrNoCPUInfo},
+			target:     ErrNoCPUInfo,
+			wantIs:     true,
+			wantSubsys: "cpu",
+		},
+		{
+			name:       "wrapped again",
+			err:        fmt.Errorf("collect: %w", &MetricError{Subsystem: "mem", Err: ErrNoMemInfo}),
+			target:     ErrNoMemInfo,
+			wantIs:     true,
+			wantSubsys: "mem",
+		},
+		{
+			name:       "permission error from os",
+			err:        &MetricError{Subsystem: "thermal", Err: &os.PathError{Op: "open", Path: "/sys/x", Err: os.ErrPermission}},
+			target:     ErrPermissionDenied,
+			wantIs:     true,
+			wantSubsys: "thermal",
+		},
+		{
+			name:       "missing file isn't a permission error",
+			err:        &MetricError{Subsystem: "thermal", Err: os.ErrNotExist},
+			target:     ErrPermissionDenied,
+			wantIs:     false,
+			wantSubsys: "thermal",
+		},
+		{
+			name:   "plain error",
+			err:    errors.New("boom"),
+			target: ErrNoCPUInfo,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.wantIs {
+				t.Errorf("errors.Is(%v, %v) = %v, want %v", tt.err, tt.target, got, tt.wantIs)
+			}
+			var me *MetricError
+			if ok := errors.As(tt.err, &me); ok != (tt.wantSubsys != "") {
+				t.Fatalf("errors.As = %v, want %v", ok, tt.wantSubsys != "")
+			}
+			if me != nil && me.Subsystem != tt.wantSubsys {
+				t.Errorf("Subsystem = %q, want %q", me.Subsystem, tt.wantSubsys)
+			}
+		})
+	}
+}
+
+func TestCollectorPermissionDenied(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("needs file permissions that apply")
+	}
+	root := writeTree(t, map[string]string{"online": "0-3\n"})
+	if err := os.Chmod(filepath.Join(root, "online"), 0); err != nil {
+		t.Fatal(err)
+	}
+	_, err := getCPUOnlineStats(context.Background(), root, nil)
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("err = %v, want ErrPermissionDenied", err)
+	}
+}
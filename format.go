@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// percentPrecision is the number of decimals of percent values, set with
+// setPercentPrecision.
+var percentPrecision int32 = 1
+
+// formatNumber formats v for a Latest entry. The wire format only carries
+// strings, so numbers are always written the same way regardless of locale:
+// plain digits, "." as decimal separator, no thousands separators and no
+// exponent. precision is the number of decimals, or -1 for the fewest digits
+// that represent v exactly.
+func formatNumber(v float64, precision int) string {
+	s := strconv.FormatFloat(v, 'f', precision, 64)
+	// Avoid "-0" and "-0.0" for tiny negative values rounded to zero.
+	if strings.HasPrefix(s, "-") && strings.Trim(s, "-0.") == "" {
+		s = s[1:]
+	}
+	return s
+}
+
+// formatMB formats a size in bytes as whole MB (MiB).
+func formatMB(bytes uint64) string {
+	return formatNumber(float64(bytes)/(1<<20), 0)
+}
+
+// formatPercent formats a percentage with the configured precision.
+func formatPercent(v float64) string {
+	return formatNumber(v, int(atomic.LoadInt32(&percentPrecision)))
+}
+
+// setPercentPrecision sets the decimals of percent values, clamped to 0-3.
+func setPercentPrecision(precision int) {
+	if precision < 0 {
+		precision = 0
+	} else if precision > 3 {
+		precision = 3
+	}
+	atomic.StoreInt32(&percentPrecision, int32(precision))
+}
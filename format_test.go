@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryUnits(t *testing.T) {
+	tests := []struct {
+		name  string
+		bytes uint64
+		want  string
+	}{
+		{name: "zero", bytes: 0, want: "0"},
+		{name: "odd size", bytes: 8253661184, want: "8253661184"},
+		{name: "1 TiB", bytes: 1 << 40, want: "1099511627776"},
+		{name: "beyond float precision", bytes: 1<<53 + 2, want: "9007199254740994"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := selfLatest(SelfStats{MemoryBytes: tt.bytes}, time.Time{})["self_memory_bytes"].Value
+			if got != tt.want {
+				t.Errorf("self_memory_bytes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("filesize entries", func(t *testing.T) {
+		p := NewPlugin("host", loadConfig())
+		c, err := p.metrics(context.Background())
+		if err != nil {
+			t.Fatalf("metrics: %v", err)
+		}
+		for id, tmpl := range c.templates.MetadataTemplates() {
+			entry, ok := c.node.Latest[id]
+			if tmpl.Datatype != "filesize" || !ok {
+				continue
+			}
+			if _, err := strconv.ParseUint(entry.Value, 10, 64); err != nil {
+				t.Errorf("%s = %q is not an integer byte count", id, entry.Value)
+			}
+		}
+		if _, ok := c.node.Latest["platform_memory"]; !ok {
+			t.Error("no platform_memory")
+		}
+	})
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		v         float64
+		precision int
+		want      string
+	}{
+		{v: 1000000, precision: 0, want: "1000000"},
+		{v: 0, precision: 0, want: "0"},
+		{v: 0, precision: 2, want: "0.00"},
+		{v: 1e21, precision: 0, want: "1000000000000000000000"},
+		{v: 3.14159, precision: 2, want: "3.14"},
+		{v: 0.125, precision: -1, want: "0.125"},
+		{v: -0.001, precision: 1, want: "0.0"},
+		{v: -2.5, precision: 1, want: "-2.5"},
+	}
+	for _, tt := range tests {
+		if got := formatNumber(tt.v, tt.precision); got != tt.want {
+			t.Errorf("formatNumber(%v, %d) = %q, want %q", tt.v, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestPercentPrecision(t *testing.T) {
+	tests := []struct {
+		precision int
+		want      string
+	}{
+		{precision: 0, want: "43"},
+		{precision: 1, want: "42.6"},
+		{precision: 2, want: "42.57"},
+		{precision: -1, want: "43"},
+		{precision: 5, want: "42.567"},
+	}
+	defer setPercentPrecision(1)
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.precision), func(t *testing.T) {
+			setPercentPrecision(tt.precision)
+			if got := formatPercent(42.567); got != tt.want {
+				t.Errorf("formatPercent(42.567) = %q, want %q", got, tt.want)
+			}
+			latest := cpuUsageLatest(CPUUsageStats{UserPercent: 42.567}, time.Time{})
+			if got := latest["cpu_user_percent"].Value; got != tt.want {
+				t.Errorf("cpu_user_percent = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
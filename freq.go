@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// getCPUBaseMhz reads the advertised base frequency of cpu0 from
+// <cpuRoot>/cpu0/cpufreq/base_frequency, where cpuRoot is normally
+// /sys/devices/system/cpu. Only some cpufreq drivers, such as intel_pstate,
+// provide it.
+func getCPUBaseMhz(cpuRoot string, reg *TemplateRegistry) (float64, error) {
+	s, err := readSysfsString(filepath.Join(cpuRoot, "cpu0", "cpufreq", "base_frequency"))
+	if err != nil {
+		return 0, &MetricError{Subsystem: "cpu_base_freq", Err: err}
+	}
+	khz, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, &MetricError{Subsystem: "cpu_base_freq", Err: err}
+	}
+	reg.RegisterMetadata(getCPUFreqMetadataTemplate("cpu_base_mhz"))
+	return float64(khz) / 1000, nil
+}
+
+func cpuFreqLatest(key string, mhz float64, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		key: {Timestamp: t, Value: formatNumber(mhz, 0)},
+	}
+}
+
+func getCPUFreqMetadataTemplate(ids ...string) map[string]metadataTemplate {
+	return pickMetadata(map[string]metadataTemplate{
+		"cpu_mhz": {
+			ID:       "cpu_mhz",
+			Label:    "CPU Frequency (MHz)",
+			Datatype: "number",
+			Priority: priorityHardware + 0.3,
+			From:     "latest",
+		},
+		"cpu_base_mhz": {
+			ID:       "cpu_base_mhz",
+			Label:    "CPU Base Frequency (MHz)",
+			Datatype: "number",
+			Priority: priorityHardware + 0.3,
+			From:     "latest",
+		},
+	}, ids...)
+}
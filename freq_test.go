@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetCPUBaseMhz(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        string
+		wantMissing bool
+		wantErr     bool
+	}{
+		{
+			name:  "intel_pstate",
+			files: map[string]string{"cpu0/cpufreq/base_frequency": "2100000\n"},
+			want:  "2100",
+		},
+		{
+			name:  "fractional MHz",
+			files: map[string]string{"cpu0/cpufreq/base_frequency": "2399600\n"},
+			want:  "2400",
+		},
+		{
+			name:        "driver without base frequency",
+			files:       map[string]string{"cpu0/cpufreq/scaling_cur_freq": "3400000\n"},
+			wantMissing: true,
+		},
+		{
+			name:    "garbage",
+			files:   map[string]string{"cpu0/cpufreq/base_frequency": "unknown\n"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewTemplateRegistry()
+			mhz, err := getCPUBaseMhz(writeTree(t, tt.files), reg)
+			switch {
+			case tt.wantMissing:
+				if !sysfsMissing(err) {
+					t.Fatalf("getCPUBaseMhz error = %v, want a missing error", err)
+				}
+				return
+			case tt.wantErr:
+				if err == nil || sysfsMissing(err) {
+					t.Fatalf("getCPUBaseMhz error = %v, want a parse error", err)
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			if got := cpuFreqLatest("cpu_base_mhz", mhz, time.Time{})["cpu_base_mhz"].Value; got != tt.want {
+				t.Errorf("cpu_base_mhz = %q, want %q", got, tt.want)
+			}
+			templates := reg.MetadataTemplates()
+			if _, ok := templates["cpu_base_mhz"]; !ok {
+				t.Error("no cpu_base_mhz template")
+			}
+			if _, ok := templates["cpu_mhz"]; ok {
+				t.Error("the base frequency registered the cpu_mhz template")
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/shirou/gopsutil/v3/load"
+)
+
+func getLoadStats(ctx context.Context, reg *TemplateRegistry) (LoadStats, error) {
+	avg, err := load.AvgWithContext(ctx)
+	if err != nil {
+		log.Printf("err=%s", err.Error())
+		return LoadStats{}, &MetricError{Subsystem: "load", Err: err}
+	}
+	reg.RegisterMetadata(getLoadMetadataTemplate())
+	return LoadStats{Load1: avg.Load1, Load5: avg.Load5, Load15: avg.Load15}, nil
+}
@@ -0,0 +1,24 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// getLoadStats reports the number of processes as a rough proxy, since
+// Windows has no load average.
+func getLoadStats(ctx context.Context, reg *TemplateRegistry) (LoadStats, error) {
+	info, err := host.InfoWithContext(ctx)
+	if err != nil {
+		log.Printf("err=%s", err.Error())
+		return LoadStats{}, &MetricError{Subsystem: "load", Err: err}
+	}
+	procs := float64(info.Procs)
+	reg.RegisterMetadata(getLoadMetadataTemplate())
+	return LoadStats{Load1: procs, Load5: procs, Load15: procs}, nil
+}
@@ -0,0 +1,23 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetLoadStatsProcsProxy(t *testing.T) {
+	reg := NewTemplateRegistry()
+	got, err := getLoadStats(context.Background(), reg)
+	if err != nil {
+		t.Fatalf("getLoadStats: %v", err)
+	}
+	if got.Load1 < 1 || got.Load5 != got.Load1 || got.Load15 != got.Load1 {
+		t.Errorf("getLoadStats = %+v, want the process count in every field", got)
+	}
+	if _, ok := reg.MetadataTemplates()["load_1"]; !ok {
+		t.Error("load_1 template not registered")
+	}
+}
@@ -2,7 +2,10 @@ module cpuinfo
 
 go 1.17
 
-require github.com/shirou/gopsutil/v3 v3.22.2
+require (
+	github.com/shirou/gopsutil/v3 v3.22.2
+	golang.org/x/time v0.3.0
+)
 
 require (
 	github.com/go-ole/go-ole v1.2.6 // indirect
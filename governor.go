@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// getCPUGovernor returns the cpufreq scaling governor shared by all CPUs
+// under cpuRoot, normally /sys/devices/system/cpu, or "mixed" when they
+// differ. Without cpufreq the error wraps os.ErrNotExist.
+func getCPUGovernor(cpuRoot string, reg *TemplateRegistry) (string, error) {
+	paths, err := filepath.Glob(filepath.Join(cpuRoot, "cpu[0-9]*", "cpufreq", "scaling_governor"))
+	if err != nil {
+		return "", &MetricError{Subsystem: "cpu_governor", Err: err}
+	}
+	if len(paths) == 0 {
+		return "", &MetricError{Subsystem: "cpu_governor", Err: os.ErrNotExist}
+	}
+
+	governor := ""
+	for _, path := range paths {
+		g, err := readSysfsString(path)
+		if err != nil {
+			return "", &MetricError{Subsystem: "cpu_governor", Err: err}
+		}
+		if governor != "" && g != governor {
+			governor = "mixed"
+			break
+		}
+		governor = g
+	}
+	reg.RegisterMetadata(getCPUGovernorMetadataTemplate())
+	return governor, nil
+}
+
+func cpuGovernorLatest(governor string, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"cpu_governor": {Timestamp: t, Value: governor},
+	}
+}
+
+func getCPUGovernorMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"cpu_governor": {
+			ID:       "cpu_governor",
+			Label:    "CPU Governor",
+			Priority: prioritySoftware,
+			From:     "latest",
+		},
+	}
+}
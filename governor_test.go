@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestGetCPUGovernor(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        string
+		wantMissing bool
+	}{
+		{
+			name: "uniform",
+			files: map[string]string{
+				"cpu0/cpufreq/scaling_governor": "performance\n",
+				"cpu1/cpufreq/scaling_governor": "performance\n",
+			},
+			want: "performance",
+		},
+		{
+			name: "mixed",
+			files: map[string]string{
+				"cpu0/cpufreq/scaling_governor": "performance\n",
+				"cpu1/cpufreq/scaling_governor": "powersave\n",
+				"cpu2/cpufreq/scaling_governor": "performance\n",
+			},
+			want: "mixed",
+		},
+		{
+			name:        "no cpufreq",
+			files:       map[string]string{"cpu0/online": "1\n"},
+			wantMissing: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getCPUGovernor(writeTree(t, tt.files), nil)
+			if sysfsMissing(err) != tt.wantMissing || err != nil && !tt.wantMissing {
+				t.Fatalf("getCPUGovernor error = %v, want missing %v", err, tt.wantMissing)
+			}
+			if got != tt.want {
+				t.Errorf("getCPUGovernor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// nvidiaSMICommand is the command used to query GPUs, it prints one GPU name
+// per line.
+var nvidiaSMICommand = []string{"nvidia-smi", "--query-gpu=name", "--format=csv,noheader"}
+
+// GPUStats describes the NVIDIA GPUs of the host.
+type GPUStats struct {
+	Count int
+	Model string
+}
+
+// getGPUStats runs nvidia-smi with a deadline of timeout. ok is false when GPU
+// info is unavailable: nvidia-smi isn't installed, or it didn't answer in time,
+// which happens when the driver hangs. The process is killed on timeout.
+func getGPUStats(ctx context.Context, command []string, timeout time.Duration, reg *TemplateRegistry) (stats GPUStats, ok bool, err error) {
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return GPUStats{}, false, nil
+	}
+
+	out, err := runCommand(ctx, timeout, path, command[1:]...)
+	if err == errCommandTimeout {
+		warnf("%s timed out after %s, GPU info unavailable", command[0], timeout)
+		return GPUStats{}, false, nil
+	}
+	if err != nil {
+		return GPUStats{}, false, &MetricError{Subsystem: "gpu", Err: err}
+	}
+
+	var models []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(string(out), "\n") {
+		name := strings.TrimSpace(line)
+		if name == "" {
+			continue
+		}
+		stats.Count++
+		if !seen[name] {
+			seen[name] = true
+			models = append(models, name)
+		}
+	}
+	if stats.Count == 0 {
+		return GPUStats{}, false, nil
+	}
+	stats.Model = strings.Join(models, " / ")
+	reg.RegisterMetadata(getGPUMetadataTemplate())
+	return stats, true, nil
+}
+
+func gpuLatest(stats GPUStats, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"gpu_count": {Timestamp: t, Value: formatNumber(float64(stats.Count), 0)},
+		"gpu_model": {Timestamp: t, Value: stats.Model},
+	}
+}
+
+func getGPUMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"gpu_count": {
+			ID:       "gpu_count",
+			Label:    "GPU Count",
+			Datatype: "integer",
+			Priority: priorityHardware + 1.6,
+			From:     "latest",
+		},
+		"gpu_model": {
+			ID:       "gpu_model",
+			Label:    "GPU Model",
+			Priority: priorityHardware + 1.6,
+			From:     "latest",
+		},
+	}
+}
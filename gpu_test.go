@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestGetGPUStats(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "finished")
+	tests := []struct {
+		name    string
+		script  string
+		want    GPUStats
+		wantOK  bool
+		wantErr bool
+	}{
+		{
+			name:   "two GPUs",
+			script: "echo 'NVIDIA A100-SXM4-40GB'; echo 'NVIDIA A100-SXM4-40GB'",
+			want:   GPUStats{Count: 2, Model: "NVIDIA A100-SXM4-40GB"},
+			wantOK: true,
+		},
+		{
+			name:   "mixed models",
+			script: "printf 'Tesla T4\\nNVIDIA L4\\n'",
+			want:   GPUStats{Count: 2, Model: "Tesla T4 / NVIDIA L4"},
+			wantOK: true,
+		},
+		{name: "no GPUs", script: "true"},
+		{name: "hung driver", script: "sleep 1; touch " + marker},
+		{name: "failure", script: "echo 'NVIDIA-SMI has failed' >&2; exit 9", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			smi := filepath.Join(dir, "nvidia-smi")
+			if err := ioutil.WriteFile(smi, []byte("#!/bin/sh\n"+tt.script+"\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			reg := NewTemplateRegistry()
+			start := time.Now()
+			got, ok, err := getGPUStats(context.Background(), []string{smi}, 200*time.Millisecond, reg)
+			if (err != nil) != tt.wantErr || ok != tt.wantOK || got != tt.want {
+				t.Errorf("getGPUStats = %+v, %v, %v, want %+v, %v, wantErr %v", got, ok, err, tt.want, tt.wantOK, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("took %s, want the timeout to stop it", elapsed)
+			}
+			if _, registered := reg.MetadataTemplates()["gpu_count"]; registered != tt.wantOK {
+				t.Errorf("gpu_count template registered = %v, want %v", registered, tt.wantOK)
+			}
+		})
+	}
+
+	// The hung script would have created the marker had it not been killed.
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the timed out nvidia-smi kept running")
+	}
+}
+
+func TestGetGPUStatsWithoutNvidiaSMI(t *testing.T) {
+	got, ok, err := getGPUStats(context.Background(), []string{filepath.Join(t.TempDir(), "nvidia-smi")}, time.Second, nil)
+	if err != nil || ok || got != (GPUStats{}) {
+		t.Errorf("getGPUStats = %+v, %v, %v, want unavailable", got, ok, err)
+	}
+}
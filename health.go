@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// collectorHealth records which collectors fell back to empty data during a
+// single collection, so that it can be surfaced as the degraded_collectors row.
+type collectorHealth struct {
+	degraded []string
+}
+
+func (h *collectorHealth) degrade(collector string, err error) {
+	errorf("%v", err)
+	h.degraded = append(h.degraded, collector)
+}
+
+// entry lists the degraded collectors, and is empty when all are healthy.
+func (h *collectorHealth) entry(t time.Time) stringEntry {
+	names := append([]string(nil), h.degraded...)
+	sort.Strings(names)
+	return stringEntry{Timestamp: t, Value: strings.Join(names, ", ")}
+}
+
+func getHealthMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"degraded_collectors": {
+			ID:       "degraded_collectors",
+			Label:    "Degraded Collectors",
+			Priority: prioritySelf + 5,
+			From:     "latest",
+		},
+	}
+}
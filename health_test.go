@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingCollector always fails with err.
+type failingCollector struct {
+	name string
+	err  error
+}
+
+func (c failingCollector) Name() string { return c.name }
+
+func (c failingCollector) Collect(ctx context.Context) (map[string]stringEntry, []metadataTemplate, error) {
+	return nil, nil, c.err
+}
+
+func TestCollectorHealthEntry(t *testing.T) {
+	tests := []struct {
+		name     string
+		degraded []string
+		want     string
+	}{
+		{name: "healthy", want: ""},
+		{name: "one", degraded: []string{"gpu"}, want: "gpu"},
+		{name: "two, sorted", degraded: []string{"net", "gpu"}, want: "gpu, net"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h collectorHealth
+			for _, name := range tt.degraded {
+				h.degrade(name, errors.New(name+" failed"))
+			}
+			if got := h.entry(time.Time{}).Value; got != tt.want {
+				t.Errorf("entry = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDegradedCollectorsReported(t *testing.T) {
+	p := NewPlugin("host", loadConfig())
+	p.RegisterCollector(failingCollector{name: "broken_a", err: errors.New("a failed")})
+	p.RegisterCollector(failingCollector{name: "broken_b", err: errors.New("b failed")})
+
+	c, err := p.metrics(context.Background())
+	if err != nil {
+		t.Fatalf("metrics: %v", err)
+	}
+	row := c.node.Latest["degraded_collectors"].Value
+	for _, want := range []string{"broken_a", "broken_b"} {
+		if !strings.Contains(row, want) {
+			t.Errorf("degraded_collectors = %q, missing %s", row, want)
+		}
+	}
+	if _, ok := c.templates.MetadataTemplates()["degraded_collectors"]; !ok {
+		t.Error("no degraded_collectors template")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// historySamples is how many samples a metric keeps for Scope's sparklines.
+const historySamples = 60
+
+// metricRow is one sample of a metric, in Scope's "metrics" format.
+type metricRow struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+}
+
+// metric is a time series in a node's Metrics, which Scope draws as a
+// sparkline scaled between Min and Max.
+type metric struct {
+	Samples []metricRow `json:"samples"`
+	Min     float64     `json:"min"`
+	Max     float64     `json:"max"`
+}
+
+// metricTemplate describes how Scope renders a metric. Format is "percent",
+// "filesize" or empty for plain numbers.
+type metricTemplate struct {
+	ID       string  `json:"id"`
+	Label    string  `json:"label,omitempty"`
+	Format   string  `json:"format,omitempty"`
+	Priority float64 `json:"priority,omitempty"`
+}
+
+// metricHistory keeps the last historySamples samples of a metric.
+type metricHistory struct {
+	rows []metricRow
+}
+
+// add appends a sample, dropping the oldest beyond historySamples.
+func (h *metricHistory) add(t time.Time, value float64) {
+	h.rows = append(h.rows, metricRow{Date: t, Value: value})
+	if len(h.rows) > historySamples {
+		// Copy so that the backing array doesn't grow without bound.
+		h.rows = append([]metricRow(nil), h.rows[len(h.rows)-historySamples:]...)
+	}
+}
+
+// metric returns a copy of the samples, so that cached nodes don't change
+// with later samples.
+func (h *metricHistory) metric(min, max float64) metric {
+	return metric{Samples: append([]metricRow(nil), h.rows...), Min: min, Max: max}
+}
+
+// hostMetrics records the sample's CPU utilization and returns the
+// histories for the host node's Metrics, nil before the first CPU usage.
+func (p *Plugin) hostMetrics(s hostSample) map[string]metric {
+	if s.CPUUsage != nil {
+		p.cpuHistory.add(s.Time, math.Max(0, 100-s.CPUUsage.IdlePercent))
+	}
+	if len(p.cpuHistory.rows) == 0 {
+		return nil
+	}
+	return map[string]metric{
+		"cpu_utilization": p.cpuHistory.metric(0, 100),
+	}
+}
+
+// prefixMetrics is prefixSets for Metrics.
+func prefixMetrics(metrics map[string]metric, prefix string) map[string]metric {
+	if prefix == "" {
+		return metrics
+	}
+	prefixed := make(map[string]metric, len(metrics))
+	for k, v := range metrics {
+		prefixed[prefix+k] = v
+	}
+	return prefixed
+}
+
+// getMetricTemplates describes the host node's Metrics, with keys and IDs
+// prefixed by prefix.
+func getMetricTemplates(prefix string) map[string]metricTemplate {
+	return map[string]metricTemplate{
+		prefix + "cpu_utilization": {
+			ID:       prefix + "cpu_utilization",
+			Label:    "CPU",
+			Format:   "percent",
+			Priority: 1,
+		},
+	}
+}
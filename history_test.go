@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricHistoryAdd(t *testing.T) {
+	t0 := time.Unix(1646136000, 0)
+	tests := []struct {
+		name      string
+		added     int
+		wantLen   int
+		wantFirst float64
+	}{
+		{name: "one", added: 1, wantLen: 1, wantFirst: 0},
+		{name: "below the limit", added: historySamples - 1, wantLen: historySamples - 1, wantFirst: 0},
+		{name: "at the limit", added: historySamples, wantLen: historySamples, wantFirst: 0},
+		{name: "one over", added: historySamples + 1, wantLen: historySamples, wantFirst: 1},
+		{name: "many over", added: 5 * historySamples, wantLen: historySamples, wantFirst: 4 * historySamples},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h metricHistory
+			for i := 0; i < tt.added; i++ {
+				h.add(t0.Add(time.Duration(i)*time.Second), float64(i))
+			}
+			m := h.metric(0, 100)
+			if len(m.Samples) != tt.wantLen {
+				t.Fatalf("samples = %d, want %d", len(m.Samples), tt.wantLen)
+			}
+			if got := m.Samples[0].Value; got != tt.wantFirst {
+				t.Errorf("oldest sample = %v, want %v", got, tt.wantFirst)
+			}
+			last := m.Samples[len(m.Samples)-1]
+			if last.Value != float64(tt.added-1) || !last.Date.Equal(t0.Add(time.Duration(tt.added-1)*time.Second)) {
+				t.Errorf("newest sample = %+v, want sample %d", last, tt.added-1)
+			}
+			if cap(h.rows) > 2*historySamples {
+				t.Errorf("backing array holds %d rows, want at most %d", cap(h.rows), 2*historySamples)
+			}
+		})
+	}
+}
+
+func TestMetricHistoryCopy(t *testing.T) {
+	t0 := time.Unix(1646136000, 0)
+	var h metricHistory
+	for i := 0; i < historySamples; i++ {
+		h.add(t0.Add(time.Duration(i)*time.Second), 10)
+	}
+	cached := h.metric(0, 100)
+	h.add(t0.Add(time.Hour), 90)
+	h.rows[0].Value = 55
+
+	if cached.Samples[0].Value != 10 || cached.Samples[len(cached.Samples)-1].Value != 10 {
+		t.Errorf("cached metric changed with later samples: %+v", cached.Samples)
+	}
+	if cached.Min != 0 || cached.Max != 100 {
+		t.Errorf("min, max = %v, %v, want 0, 100", cached.Min, cached.Max)
+	}
+}
+
+func TestHostMetrics(t *testing.T) {
+	t0 := time.Unix(1646136000, 0)
+	tests := []struct {
+		name    string
+		idle    []float64 // negative for a sample without CPU usage
+		want    []float64
+		wantNil bool
+	}{
+		{name: "no usage yet", idle: []float64{-1}, wantNil: true},
+		{name: "utilization", idle: []float64{85, 40}, want: []float64{15, 60}},
+		{name: "sample without usage keeps the history", idle: []float64{85, -1}, want: []float64{15}},
+		{name: "idle above 100 clamps to 0", idle: []float64{100.5}, want: []float64{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPlugin("host", loadConfig())
+			var got map[string]metric
+			for i, idle := range tt.idle {
+				s := hostSample{Time: t0.Add(time.Duration(i) * time.Second)}
+				if idle >= 0 {
+					s.CPUUsage = &CPUUsageStats{IdlePercent: idle}
+				}
+				got = p.hostMetrics(s)
+			}
+			if tt.wantNil {
+				if got != nil {
+					t.Errorf("hostMetrics = %v, want nil", got)
+				}
+				return
+			}
+			samples := got["cpu_utilization"].Samples
+			if len(samples) != len(tt.want) {
+				t.Fatalf("samples = %+v, want values %v", samples, tt.want)
+			}
+			for i, want := range tt.want {
+				if samples[i].Value != want {
+					t.Errorf("sample %d = %v, want %v", i, samples[i].Value, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Sources of core types for Config.CoreTypeSource.
+const (
+	coreTypeAuto     = "auto"
+	coreTypePMU      = "pmu"
+	coreTypeCapacity = "capacity"
+)
+
+// CoreTypeStats counts the logical CPUs of each type on hybrid chips, or all
+// of them as Uniform on chips with a single core type.
+type CoreTypeStats struct {
+	Performance int
+	Efficiency  int
+	Uniform     int
+}
+
+// getCoreTypeStats counts performance and efficiency cores from source:
+// "pmu" reads the CPU lists of the Intel hybrid cpu_core and cpu_atom PMUs,
+// two levels above cpuRoot, "capacity" compares the cpu*/cpu_capacity of ARM
+// big.LITTLE chips, and "auto" tries both in that order. When the source
+// finds a single core type, or has nothing to read, it falls back to counting
+// the cpu* directories of cpuRoot as Uniform.
+func getCoreTypeStats(ctx context.Context, cpuRoot, source string, reg *TemplateRegistry) (CoreTypeStats, error) {
+	var stats CoreTypeStats
+	var ok bool
+	var err error
+	switch source {
+	case coreTypePMU:
+		stats, ok, err = readPMUCoreTypes(cpuRoot)
+	case coreTypeCapacity:
+		stats, ok, err = readCapacityCoreTypes(cpuRoot)
+	default:
+		stats, ok, err = readPMUCoreTypes(cpuRoot)
+		if err == nil && !ok || sysfsMissing(err) {
+			stats, ok, err = readCapacityCoreTypes(cpuRoot)
+		}
+	}
+	if err == nil && !ok || sysfsMissing(err) {
+		stats, err = readUniformCoreCount(cpuRoot)
+	}
+	if err != nil {
+		return CoreTypeStats{}, &MetricError{Subsystem: "core_types", Err: err}
+	}
+	reg.RegisterMetadata(getCoreTypeMetadataTemplate())
+	return stats, nil
+}
+
+// readUniformCoreCount counts the cpu* directories of cpuRoot.
+func readUniformCoreCount(cpuRoot string) (CoreTypeStats, error) {
+	cpus, err := filepath.Glob(filepath.Join(cpuRoot, "cpu[0-9]*"))
+	if err != nil {
+		return CoreTypeStats{}, err
+	}
+	if len(cpus) == 0 {
+		return CoreTypeStats{}, os.ErrNotExist
+	}
+	return CoreTypeStats{Uniform: len(cpus)}, nil
+}
+
+func readPMUCoreTypes(cpuRoot string) (CoreTypeStats, bool, error) {
+	devices := filepath.Join(cpuRoot, "..", "..")
+	core, err := readSysfsString(filepath.Join(devices, "cpu_core", "cpus"))
+	if err != nil {
+		return CoreTypeStats{}, false, err
+	}
+	atom, err := readSysfsString(filepath.Join(devices, "cpu_atom", "cpus"))
+	if err != nil {
+		return CoreTypeStats{}, false, err
+	}
+	var stats CoreTypeStats
+	if stats.Performance, err = parseCPURange(core); err != nil {
+		return CoreTypeStats{}, false, err
+	}
+	if stats.Efficiency, err = parseCPURange(atom); err != nil {
+		return CoreTypeStats{}, false, err
+	}
+	return stats, stats.Performance > 0 && stats.Efficiency > 0, nil
+}
+
+// readCapacityCoreTypes counts the CPUs with the highest cpu_capacity as
+// performance cores and all others as efficiency cores.
+func readCapacityCoreTypes(cpuRoot string) (CoreTypeStats, bool, error) {
+	paths, err := filepath.Glob(filepath.Join(cpuRoot, "cpu[0-9]*", "cpu_capacity"))
+	if err != nil {
+		return CoreTypeStats{}, false, err
+	}
+	if len(paths) == 0 {
+		return CoreTypeStats{}, false, os.ErrNotExist
+	}
+	capacities := make([]int, 0, len(paths))
+	for _, path := range paths {
+		s, err := readSysfsString(path)
+		if err != nil {
+			return CoreTypeStats{}, false, err
+		}
+		capacity, err := strconv.Atoi(s)
+		if err != nil {
+			return CoreTypeStats{}, false, fmt.Errorf("invalid cpu_capacity %q in %s", s, path)
+		}
+		capacities = append(capacities, capacity)
+	}
+	stats, ok := coreTypesFromCapacities(capacities)
+	return stats, ok, nil
+}
+
+func coreTypesFromCapacities(capacities []int) (CoreTypeStats, bool) {
+	max := 0
+	for _, c := range capacities {
+		if c > max {
+			max = c
+		}
+	}
+	var stats CoreTypeStats
+	for _, c := range capacities {
+		if c == max {
+			stats.Performance++
+		} else {
+			stats.Efficiency++
+		}
+	}
+	return stats, stats.Efficiency > 0
+}
+
+// coreTypeLatest emits uniform_cores alone on chips with a single core type.
+func coreTypeLatest(stats CoreTypeStats, t time.Time) map[string]stringEntry {
+	if stats.Uniform > 0 {
+		return map[string]stringEntry{
+			"uniform_cores": {Timestamp: t, Value: formatNumber(float64(stats.Uniform), 0)},
+		}
+	}
+	return map[string]stringEntry{
+		"performance_cores": {Timestamp: t, Value: formatNumber(float64(stats.Performance), 0)},
+		"efficiency_cores":  {Timestamp: t, Value: formatNumber(float64(stats.Efficiency), 0)},
+	}
+}
+
+func getCoreTypeMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"performance_cores": {
+			ID:       "performance_cores",
+			Label:    "Performance Cores",
+			Datatype: "integer",
+			Priority: priorityHardware + 0.4,
+			From:     "latest",
+		},
+		"efficiency_cores": {
+			ID:       "efficiency_cores",
+			Label:    "Efficiency Cores",
+			Datatype: "integer",
+			Priority: priorityHardware + 0.4,
+			From:     "latest",
+		},
+		"uniform_cores": {
+			ID:       "uniform_cores",
+			Label:    "Cores",
+			Datatype: "integer",
+			Priority: priorityHardware + 0.4,
+			From:     "latest",
+		},
+	}
+}
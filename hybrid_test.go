@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetCoreTypeStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		files   map[string]string
+		want    CoreTypeStats
+		wantErr bool
+	}{
+		{
+			name:   "intel hybrid",
+			source: coreTypeAuto,
+			files: map[string]string{
+				"devices/cpu_core/cpus":       "0-7\n",
+				"devices/cpu_atom/cpus":       "8-15\n",
+				"devices/system/cpu/cpu0/.ok": "",
+			},
+			want: CoreTypeStats{Performance: 8, Efficiency: 8},
+		},
+		{
+			name:   "big.LITTLE",
+			source: coreTypeAuto,
+			files: map[string]string{
+				"devices/system/cpu/cpu0/cpu_capacity": "1024\n",
+				"devices/system/cpu/cpu1/cpu_capacity": "1024\n",
+				"devices/system/cpu/cpu2/cpu_capacity": "446\n",
+				"devices/system/cpu/cpu3/cpu_capacity": "446\n",
+				"devices/system/cpu/cpu4/cpu_capacity": "446\n",
+			},
+			want: CoreTypeStats{Performance: 2, Efficiency: 3},
+		},
+		{
+			name:   "uniform capacities",
+			source: coreTypeCapacity,
+			files: map[string]string{
+				"devices/system/cpu/cpu0/cpu_capacity": "1024\n",
+				"devices/system/cpu/cpu1/cpu_capacity": "1024\n",
+			},
+			want: CoreTypeStats{Uniform: 2},
+		},
+		{
+			name:   "uniform without type info",
+			source: coreTypePMU,
+			files: map[string]string{
+				"devices/cpu_core/cpus":          "0-3\n",
+				"devices/system/cpu/cpu0/.ok":    "",
+				"devices/system/cpu/cpu1/.ok":    "",
+				"devices/system/cpu/cpu2/.ok":    "",
+				"devices/system/cpu/cpu3/.ok":    "",
+				"devices/system/cpu/cpufreq/.ok": "",
+			},
+			want: CoreTypeStats{Uniform: 4},
+		},
+		{
+			name:    "no cpus",
+			source:  coreTypeAuto,
+			files:   map[string]string{"devices/system/cpu/online": "0\n"},
+			wantErr: true,
+		},
+		{
+			name:   "invalid capacity",
+			source: coreTypeCapacity,
+			files: map[string]string{
+				"devices/system/cpu/cpu0/cpu_capacity": "big\n",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpuRoot := filepath.Join(writeTree(t, tt.files), "devices", "system", "cpu")
+			reg := NewTemplateRegistry()
+			got, err := getCoreTypeStats(context.Background(), cpuRoot, tt.source, reg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCoreTypeStats error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getCoreTypeStats = %+v, want %+v", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			for k := range coreTypeLatest(got, time.Time{}) {
+				if _, ok := reg.MetadataTemplates()[k]; !ok {
+					t.Errorf("%s has no metadata template", k)
+				}
+			}
+		})
+	}
+}
+
+func TestCoreTypeLatest(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats CoreTypeStats
+		want  map[string]string
+	}{
+		{
+			name:  "hybrid",
+			stats: CoreTypeStats{Performance: 6, Efficiency: 8},
+			want:  map[string]string{"performance_cores": "6", "efficiency_cores": "8"},
+		},
+		{
+			name:  "uniform",
+			stats: CoreTypeStats{Uniform: 16},
+			want:  map[string]string{"uniform_cores": "16"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := coreTypeLatest(tt.stats, time.Time{})
+			if len(got) != len(tt.want) {
+				t.Errorf("got %d keys, want %d", len(got), len(tt.want))
+			}
+			for k, want := range tt.want {
+				if got[k].Value != want {
+					t.Errorf("%s = %q, want %q", k, got[k].Value, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// shutdownPushTimeout bounds the final push on SIGTERM.
+const shutdownPushTimeout = 3 * time.Second
+
+// hostSample keeps the raw stats of the latest collection for the push
+// targets, which need numbers rather than Latest strings.
+type hostSample struct {
+	Time     time.Time
+	CPU      CPUStats
+	CPUUsage *CPUUsageStats
+	Mem      MemStats
+	Load     *LoadStats
+}
+
+var influxTagEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// formatLineProtocol renders s as InfluxDB line protocol, one line per
+// measurement, tagged with host.
+func formatLineProtocol(host string, s hostSample) string {
+	var b strings.Builder
+	tags := "host=" + influxTagEscaper.Replace(host)
+	ts := s.Time.UnixNano()
+
+	cpuFields := fmt.Sprintf("processor_count=%di", s.CPU.ProcessorCount)
+	if s.CPUUsage != nil {
+		cpuFields += fmt.Sprintf(",user_percent=%s,system_percent=%s,idle_percent=%s",
+			formatNumber(s.CPUUsage.UserPercent, -1),
+			formatNumber(s.CPUUsage.SystemPercent, -1),
+			formatNumber(s.CPUUsage.IdlePercent, -1))
+	}
+	fmt.Fprintf(&b, "cpu,%s %s %d\n", tags, cpuFields, ts)
+	fmt.Fprintf(&b, "mem,%s total_bytes=%di %d\n", tags, s.Mem.MemTotalBytes, ts)
+	if s.Load != nil {
+		fmt.Fprintf(&b, "load,%s load1=%s,load5=%s,load15=%s %d\n", tags,
+			formatNumber(s.Load.Load1, -1), formatNumber(s.Load.Load5, -1), formatNumber(s.Load.Load15, -1), ts)
+	}
+	return b.String()
+}
+
+// runInfluxPusher writes the latest sample to the InfluxDB database every
+// refresh interval, until stop is closed.
+func (p *Plugin) runInfluxPusher(stop <-chan struct{}) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	for {
+		if err := p.pushInfluxSample(client); err != nil {
+			errorf("%v", err)
+		}
+
+		p.lock.Lock()
+		interval := p.cfg.RefreshInterval
+		p.lock.Unlock()
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// pushInfluxSample pushes the latest sample, if there is one yet and
+// InfluxDB is configured.
+func (p *Plugin) pushInfluxSample(client *http.Client) error {
+	p.lock.Lock()
+	last, cfg := p.last, p.cfg
+	p.lock.Unlock()
+
+	if last == nil || cfg.InfluxURL == "" {
+		return nil
+	}
+	return pushInflux(client, cfg.InfluxURL, cfg.InfluxDB, formatLineProtocol(p.HostID, last.sample))
+}
+
+// finalPush pushes the latest sample once more before the plugin exits, so
+// that InfluxDB has the node's last state. It gives up after timeout, e.g.
+// when a hung collection holds the lock.
+func (p *Plugin) finalPush(timeout time.Duration) {
+	done := make(chan error, 1)
+	go func() {
+		done <- p.pushInfluxSample(&http.Client{Timeout: timeout})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			errorf("final push: %v", err)
+		}
+	case <-time.After(timeout):
+		warnf("final push did not complete within %s", timeout)
+	}
+}
+
+func pushInflux(client *http.Client, baseURL, db, body string) error {
+	u := strings.TrimSuffix(baseURL, "/") + "/write?" + url.Values{"db": {db}, "precision": {"ns"}}.Encode()
+	resp, err := client.Post(u, "text/plain; charset=utf-8", bytes.NewBufferString(body))
+	if err != nil {
+		return fmt.Errorf("failed to push to influxdb: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to push to influxdb: %s", resp.Status)
+	}
+	return nil
+}
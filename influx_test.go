@@ -0,0 +1,132 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestFormatLineProtocol(t *testing.T) {
+	at := time.Unix(1646136000, 0)
+	tests := []struct {
+		name   string
+		host   string
+		sample hostSample
+		want   string
+	}{
+		{
+			name:   "first sample",
+			host:   "node-1",
+			sample: hostSample{Time: at, CPU: CPUStats{ProcessorCount: 8}, Mem: MemStats{MemTotalBytes: 8 << 30}},
+			want: "cpu,host=node-1 processor_count=8i 1646136000000000000\n" +
+				"mem,host=node-1 total_bytes=8589934592i 1646136000000000000\n",
+		},
+		{
+			name: "usage and load",
+			host: "node-1",
+			sample: hostSample{
+				Time:     at,
+				CPU:      CPUStats{ProcessorCount: 4},
+				CPUUsage: &CPUUsageStats{UserPercent: 12.5, SystemPercent: 2.5, IdlePercent: 85},
+				Mem:      MemStats{MemTotalBytes: 1024},
+				Load:     &LoadStats{Load1: 0.5, Load5: 0.25, Load15: 1},
+			},
+			want: "cpu,host=node-1 processor_count=4i,user_percent=12.5,system_percent=2.5,idle_percent=85 1646136000000000000\n" +
+				"mem,host=node-1 total_bytes=1024i 1646136000000000000\n" +
+				"load,host=node-1 load1=0.5,load5=0.25,load15=1 1646136000000000000\n",
+		},
+		{
+			name:   "escaped host tag",
+			host:   "my host,a=b",
+			sample: hostSample{Time: at},
+			want: `cpu,host=my\ host\,a\=b processor_count=0i 1646136000000000000` + "\n" +
+				`mem,host=my\ host\,a\=b total_bytes=0i 1646136000000000000` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLineProtocol(tt.host, tt.sample); got != tt.want {
+				t.Errorf("formatLineProtocol =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinalPush(t *testing.T) {
+	at := time.Unix(1646136000, 0)
+	tests := []struct {
+		name     string
+		influx   bool
+		sample   bool
+		wantBody string
+	}{
+		{
+			name:   "pushes the last sample",
+			influx: true,
+			sample: true,
+			wantBody: "cpu,host=host processor_count=0i,user_percent=12.5,system_percent=2.5,idle_percent=85 1646136000000000000\n" +
+				"mem,host=host total_bytes=0i 1646136000000000000\n",
+		},
+		{name: "no sample yet", influx: true},
+		{name: "influx disabled", sample: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pushes []string
+			var query url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				pushes = append(pushes, string(body))
+				query = r.URL.Query()
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			cfg := loadConfig()
+			cfg.CollectMode = collectBackground
+			if tt.influx {
+				cfg.InfluxURL, cfg.InfluxDB = srv.URL, "metrics"
+			}
+			p := NewPlugin("host", cfg)
+			if tt.sample {
+				p.last = goldenCollection(p, at)
+			}
+			p.finalPush(5 * time.Second)
+
+			if tt.wantBody == "" {
+				if len(pushes) != 0 {
+					t.Fatalf("pushed %q, want no push", pushes)
+				}
+				return
+			}
+			if len(pushes) != 1 || pushes[0] != tt.wantBody {
+				t.Fatalf("pushes = %q, want one push of\n%s", pushes, tt.wantBody)
+			}
+			if db := query.Get("db"); db != "metrics" {
+				t.Errorf("db = %q, want %q", db, "metrics")
+			}
+		})
+	}
+}
+
+func TestFinalPushGivesUpOnHungLock(t *testing.T) {
+	cfg := loadConfig()
+	cfg.InfluxURL, cfg.InfluxDB = "http://127.0.0.1:1", "metrics"
+	p := NewPlugin("host", cfg)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.finalPush(50 * time.Millisecond)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("finalPush didn't return after its timeout")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+// maxIPAddressesLength is where Scope truncates the ip_addresses row on
+// hosts with many addresses.
+const maxIPAddressesLength = 100
+
+// InterfaceStats lists the network interfaces accepted by the interface
+// filter and their global unicast addresses, ordered by interface name.
+type InterfaceStats struct {
+	Names     []string
+	Addresses []string
+}
+
+func getInterfaceStats(ctx context.Context, filter ifaceFilter, reg *TemplateRegistry) (InterfaceStats, error) {
+	ifaces, err := psnet.InterfacesWithContext(ctx)
+	if err != nil {
+		return InterfaceStats{}, &MetricError{Subsystem: "ip_addresses", Err: err}
+	}
+	reg.RegisterMetadata(getIPAddressesMetadataTemplate())
+	reg.RegisterSets(getInterfacesSetTemplate())
+	return InterfaceStats{
+		Names:     interfaceNames(ifaces, filter),
+		Addresses: ipAddresses(ifaces, filter),
+	}, nil
+}
+
+func interfaceNames(ifaces psnet.InterfaceStatList, filter ifaceFilter) []string {
+	var names []string
+	for _, iface := range ifaces {
+		if filter.match(iface.Name) {
+			names = append(names, iface.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func ipAddresses(ifaces psnet.InterfaceStatList, filter ifaceFilter) []string {
+	sort.Slice(ifaces, func(i, j int) bool { return ifaces[i].Name < ifaces[j].Name })
+	var addrs []string
+	for _, iface := range ifaces {
+		if !filter.match(iface.Name) {
+			continue
+		}
+		for _, addr := range iface.Addrs {
+			ip, _, err := net.ParseCIDR(addr.Addr)
+			if err != nil {
+				ip = net.ParseIP(addr.Addr)
+			}
+			// Loopback and link-local addresses don't identify the host.
+			if ip == nil || !ip.IsGlobalUnicast() {
+				continue
+			}
+			addrs = append(addrs, ip.String())
+		}
+	}
+	return addrs
+}
+
+func ipAddressesLatest(addrs []string, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"ip_addresses": {Timestamp: t, Value: strings.Join(addrs, ", ")},
+	}
+}
+
+func getIPAddressesMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"ip_addresses": {
+			ID:       "ip_addresses",
+			Label:    "IP Addresses",
+			Truncate: maxIPAddressesLength,
+			Priority: prioritySoftware + 1,
+			From:     "latest",
+		},
+	}
+}
+
+// getInterfacesSetTemplate describes the network_interfaces set.
+func getInterfacesSetTemplate() map[string]setTemplate {
+	return map[string]setTemplate{
+		"network_interfaces": {
+			ID:       "network_interfaces",
+			Label:    "Network Interfaces",
+			Priority: prioritySoftware + 1,
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+func TestIPAddresses(t *testing.T) {
+	ifaces := psnet.InterfaceStatList{
+		{Name: "wlan0", Addrs: psnet.InterfaceAddrList{{Addr: "192.0.2.7/24"}}},
+		{Name: "lo", Addrs: psnet.InterfaceAddrList{{Addr: "127.0.0.1/8"}, {Addr: "::1/128"}}},
+		{Name: "eth0", Addrs: psnet.InterfaceAddrList{
+			{Addr: "10.0.0.5/16"},
+			{Addr: "fe80::1/64"},
+			{Addr: "2001:db8::5/64"},
+		}},
+		{Name: "veth1a2b", Addrs: psnet.InterfaceAddrList{{Addr: "172.17.0.1/16"}}},
+		{Name: "tun0", Addrs: psnet.InterfaceAddrList{{Addr: "198.51.100.1"}}},
+	}
+	tests := []struct {
+		name       string
+		filter     ifaceFilter
+		wantNames  []string
+		wantAddrs  []string
+		wantLatest string
+	}{
+		{
+			name:       "no filter skips loopback and link-local",
+			wantNames:  []string{"eth0", "lo", "tun0", "veth1a2b", "wlan0"},
+			wantAddrs:  []string{"10.0.0.5", "2001:db8::5", "198.51.100.1", "172.17.0.1", "192.0.2.7"},
+			wantLatest: "10.0.0.5, 2001:db8::5, 198.51.100.1, 172.17.0.1, 192.0.2.7",
+		},
+		{
+			name:       "virtual interfaces excluded",
+			filter:     ifaceFilter{deny: []string{"lo", "veth*", "tun*"}},
+			wantNames:  []string{"eth0", "wlan0"},
+			wantAddrs:  []string{"10.0.0.5", "2001:db8::5", "192.0.2.7"},
+			wantLatest: "10.0.0.5, 2001:db8::5, 192.0.2.7",
+		},
+		{
+			name:       "allow list",
+			filter:     ifaceFilter{allow: []string{"wlan*"}},
+			wantNames:  []string{"wlan0"},
+			wantAddrs:  []string{"192.0.2.7"},
+			wantLatest: "192.0.2.7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := interfaceNames(ifaces, tt.filter); !reflect.DeepEqual(got, tt.wantNames) {
+				t.Errorf("interfaceNames = %v, want %v", got, tt.wantNames)
+			}
+			addrs := ipAddresses(ifaces, tt.filter)
+			if !reflect.DeepEqual(addrs, tt.wantAddrs) {
+				t.Errorf("ipAddresses = %v, want %v", addrs, tt.wantAddrs)
+			}
+			if got := ipAddressesLatest(addrs, time.Time{})["ip_addresses"].Value; got != tt.wantLatest {
+				t.Errorf("ip_addresses = %q, want %q", got, tt.wantLatest)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// K8sLimitStats holds the pod resource limits exposed through the
+// Kubernetes downward API. Zero means the file is absent.
+type K8sLimitStats struct {
+	CPUCores    float64
+	MemoryBytes uint64
+}
+
+// getK8sLimitStats reads the limit files of a downwardAPI volume with
+// resourceFieldRef limits.cpu and limits.memory and the default divisor of
+// 1. Each file is identified by its name: "cpu" holds cores, rounded up, and
+// "memory" bytes. Missing files leave their limit at zero.
+func getK8sLimitStats(ctx context.Context, files []string, reg *TemplateRegistry) (K8sLimitStats, error) {
+	var stats K8sLimitStats
+	for _, file := range files {
+		s, err := readSysfsString(file)
+		if sysfsMissing(err) {
+			continue
+		}
+		if err != nil {
+			return K8sLimitStats{}, &MetricError{Subsystem: "k8s_limits", Err: err}
+		}
+		switch filepath.Base(file) {
+		case "cpu":
+			if stats.CPUCores, err = strconv.ParseFloat(s, 64); err != nil || stats.CPUCores < 0 {
+				return K8sLimitStats{}, &MetricError{Subsystem: "k8s_limits", Err: fmt.Errorf("invalid CPU limit %q", s)}
+			}
+		case "memory":
+			if stats.MemoryBytes, err = strconv.ParseUint(s, 10, 64); err != nil {
+				return K8sLimitStats{}, &MetricError{Subsystem: "k8s_limits", Err: fmt.Errorf("invalid memory limit %q", s)}
+			}
+		default:
+			return K8sLimitStats{}, &MetricError{Subsystem: "k8s_limits", Err: fmt.Errorf("unknown limit file %s, want cpu or memory", file)}
+		}
+	}
+	reg.RegisterMetadata(getK8sLimitMetadataTemplate(stats))
+	return stats, nil
+}
+
+// checkK8sLimits describes where the pod limits don't match the cgroup limits
+// the plugin detected, which points at a cgroup detection problem. The
+// downward API rounds CPU up to whole cores, so that rounding is tolerated.
+func checkK8sLimits(k8s K8sLimitStats, cgroup CgroupStats, cgroupMemoryBytes uint64) []string {
+	var mismatches []string
+	if k8s.CPUCores > 0 && cgroup.CPUQuotaCores > 0 &&
+		math.Abs(k8s.CPUCores-cgroup.CPUQuotaCores) > 0.01 && k8s.CPUCores != math.Ceil(cgroup.CPUQuotaCores) {
+		mismatches = append(mismatches, fmt.Sprintf("Kubernetes CPU limit is %v cores but the cgroup quota is %v cores", k8s.CPUCores, cgroup.CPUQuotaCores))
+	}
+	if k8s.MemoryBytes > 0 && cgroupMemoryBytes > 0 && k8s.MemoryBytes != cgroupMemoryBytes {
+		mismatches = append(mismatches, fmt.Sprintf("Kubernetes memory limit is %d bytes but the cgroup limit is %d bytes", k8s.MemoryBytes, cgroupMemoryBytes))
+	}
+	return mismatches
+}
+
+// readCgroupMemoryLimit reads the memory limit of the plugin's cgroup from
+// cgroup v2 (memory.max) or v1 (memory/memory.limit_in_bytes). It returns 0
+// when unlimited.
+func readCgroupMemoryLimit(root string) (uint64, error) {
+	s, err := readSysfsString(filepath.Join(root, "memory.max"))
+	if err != nil {
+		if s, err = readSysfsString(filepath.Join(root, "memory", "memory.limit_in_bytes")); err != nil {
+			return 0, err
+		}
+	}
+	if s == "max" {
+		return 0, nil
+	}
+	limit, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	// cgroup v1 reports "unlimited" as a huge page-aligned number.
+	if limit >= math.MaxInt64/2 {
+		return 0, nil
+	}
+	return limit, nil
+}
+
+func k8sLimitLatest(stats K8sLimitStats, t time.Time) map[string]stringEntry {
+	latest := map[string]stringEntry{}
+	if stats.CPUCores > 0 {
+		latest["k8s_cpu_limit_cores"] = stringEntry{Timestamp: t, Value: formatNumber(stats.CPUCores, -1)}
+	}
+	if stats.MemoryBytes > 0 {
+		latest["k8s_mem_limit_mb"] = stringEntry{Timestamp: t, Value: formatMB(stats.MemoryBytes)}
+	}
+	return latest
+}
+
+func getK8sLimitMetadataTemplate(stats K8sLimitStats) map[string]metadataTemplate {
+	templates := map[string]metadataTemplate{}
+	if stats.CPUCores > 0 {
+		templates["k8s_cpu_limit_cores"] = metadataTemplate{
+			ID:       "k8s_cpu_limit_cores",
+			Label:    "Pod CPU Limit (cores)",
+			Datatype: "number",
+			Priority: prioritySelf,
+			From:     "latest",
+		}
+	}
+	if stats.MemoryBytes > 0 {
+		templates["k8s_mem_limit_mb"] = metadataTemplate{
+			ID:       "k8s_mem_limit_mb",
+			Label:    "Pod Memory Limit (MB)",
+			Datatype: "number",
+			Priority: prioritySelf,
+			From:     "latest",
+		}
+	}
+	return templates
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetK8sLimitStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string // file name to content, "" for missing
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "cpu and memory",
+			files: map[string]string{"cpu": "2\n", "memory": "536870912\n"},
+			want:  map[string]string{"k8s_cpu_limit_cores": "2", "k8s_mem_limit_mb": "512"},
+		},
+		{
+			name:  "fractional cpu",
+			files: map[string]string{"cpu": "0.5"},
+			want:  map[string]string{"k8s_cpu_limit_cores": "0.5"},
+		},
+		{
+			name:  "memory file missing",
+			files: map[string]string{"cpu": "4", "memory": ""},
+			want:  map[string]string{"k8s_cpu_limit_cores": "4"},
+		},
+		{
+			name:    "invalid cpu",
+			files:   map[string]string{"cpu": "two"},
+			wantErr: true,
+		},
+		{
+			name:    "negative cpu",
+			files:   map[string]string{"cpu": "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid memory",
+			files:   map[string]string{"memory": "512Mi"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown file",
+			files:   map[string]string{"ephemeral-storage": "1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var files []string
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				files = append(files, path)
+				if content == "" {
+					continue
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			reg := NewTemplateRegistry()
+			stats, err := getK8sLimitStats(context.Background(), files, reg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := map[string]string{}
+			for k, v := range k8sLimitLatest(stats, time.Time{}) {
+				got[k] = v.Value
+				if _, ok := reg.MetadataTemplates()[k]; !ok {
+					t.Errorf("no template for %s", k)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("latest = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckK8sLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		k8s        K8sLimitStats
+		cgroup     CgroupStats
+		cgroupMem  uint64
+		mismatches int
+	}{
+		{"matching", K8sLimitStats{CPUCores: 2, MemoryBytes: 1 << 30}, CgroupStats{CPUQuotaCores: 2}, 1 << 30, 0},
+		{"cpu rounded up", K8sLimitStats{CPUCores: 1}, CgroupStats{CPUQuotaCores: 0.5}, 0, 0},
+		{"cpu differs", K8sLimitStats{CPUCores: 4}, CgroupStats{CPUQuotaCores: 2}, 0, 1},
+		{"memory differs", K8sLimitStats{MemoryBytes: 1 << 30}, CgroupStats{}, 2 << 30, 1},
+		{"no cgroup limits", K8sLimitStats{CPUCores: 4, MemoryBytes: 1 << 30}, CgroupStats{}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkK8sLimits(tt.k8s, tt.cgroup, tt.cgroupMem); len(got) != tt.mismatches {
+				t.Errorf("mismatches = %q, want %d", got, tt.mismatches)
+			}
+		})
+	}
+}
+
+func TestValidateK8sLimitFiles(t *testing.T) {
+	tests := []struct {
+		files   []string
+		wantErr bool
+	}{
+		{[]string{"/etc/pod-limits/cpu", "/etc/pod-limits/memory"}, false},
+		{nil, false},
+		{[]string{"/etc/pod-limits"}, true},
+	}
+	for _, tt := range tests {
+		cfg := loadConfig()
+		cfg.K8sLimitFiles = tt.files
+		if err := cfg.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate(%v) = %v, wantErr %v", tt.files, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// labelKeyPrefix namespaces the keys of extra labels, so that a label can't
+// replace a built-in key such as cpu_model.
+const labelKeyPrefix = "label_"
+
+// parseExtraLabels parses a comma-separated list of key=value pairs. Keys must
+// be non-empty and only contain [a-z0-9_]; values must be non-empty. Invalid
+// pairs are logged and skipped.
+func parseExtraLabels(s string) map[string]string {
+	labels := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			warnf("skipping extra label %q: expected key=value", pair)
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if !validLabelKey(key) {
+			warnf("skipping extra label %q: key must match [a-z0-9_]+", pair)
+			continue
+		}
+		if value == "" {
+			warnf("skipping extra label %q: empty value", pair)
+			continue
+		}
+		labels[key] = value
+	}
+	return labels
+}
+
+func validLabelKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func extraLabelsLatest(labels map[string]string, t time.Time) map[string]stringEntry {
+	latest := make(map[string]stringEntry, len(labels))
+	for key, value := range labels {
+		latest[labelKeyPrefix+key] = stringEntry{Timestamp: t, Value: value}
+	}
+	return latest
+}
+
+func getExtraLabelsMetadataTemplate(labels map[string]string) map[string]metadataTemplate {
+	templates := make(map[string]metadataTemplate, len(labels))
+	for key := range labels {
+		templates[labelKeyPrefix+key] = metadataTemplate{
+			ID:       labelKeyPrefix + key,
+			Label:    key,
+			Priority: prioritySoftware + 4,
+			From:     "latest",
+		}
+	}
+	return templates
+}
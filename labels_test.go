@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseExtraLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]string
+	}{
+		{name: "none", in: "", want: map[string]string{}},
+		{name: "one", in: "rack=r12", want: map[string]string{"rack": "r12"}},
+		{
+			name: "many",
+			in:   " rack=r12, zone = eu_west_1 ,team=infra=core",
+			want: map[string]string{"rack": "r12", "zone": "eu_west_1", "team": "infra=core"},
+		},
+		{
+			name: "invalid pairs skipped",
+			in:   "rack=r12,nokey,Rack=upper,empty=,=v,,ok_1=yes",
+			want: map[string]string{"rack": "r12", "ok_1": "yes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseExtraLabels(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseExtraLabels(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtraLabelsDontReplaceBuiltinKeys(t *testing.T) {
+	cfg := loadConfig()
+	cfg.CollectMode = collectBackground
+	cfg.ExtraLabels = parseExtraLabels("cpu_model=fake,degraded_collectors=none,rack=r12")
+	p := NewPlugin("host", cfg)
+	if err := p.collect(context.Background()); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	latest, templates := p.last.node.Latest, p.last.templates.MetadataTemplates()
+
+	tests := []struct {
+		key, want string
+	}{
+		{key: "label_cpu_model", want: "fake"},
+		{key: "label_degraded_collectors", want: "none"},
+		{key: "label_rack", want: "r12"},
+	}
+	for _, tt := range tests {
+		if got := latest[tt.key].Value; got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.key, got, tt.want)
+		}
+		if tmpl, ok := templates[tt.key]; !ok || tmpl.ID != tt.key || tmpl.Label != strings.TrimPrefix(tt.key, labelKeyPrefix) {
+			t.Errorf("template %s = %+v, %v", tt.key, tmpl, ok)
+		}
+	}
+	if got := latest["cpu_model"].Value; got == "fake" {
+		t.Error("the cpu_model label replaced the built-in cpu_model")
+	}
+	if got := latest["degraded_collectors"].Value; got == "none" {
+		t.Error("the degraded_collectors label replaced the built-in degraded_collectors")
+	}
+	if got := templates["degraded_collectors"].Label; got == "degraded_collectors" {
+		t.Errorf("degraded_collectors template label = %q, replaced by the extra label", got)
+	}
+	if _, ok := latest["rack"]; ok {
+		t.Error("rack reported without the label_ prefix")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import "time"
+
+// LoadStats holds the 1, 5 and 15 minute load averages.
+type LoadStats struct {
+	Load1  float64
+	Load5  float64
+	Load15 float64
+}
+
+// loadLatest emits the load averages, and the 1 minute load per logical
+// core, comparable across machine sizes, when cores is known.
+func loadLatest(stats LoadStats, cores int, t time.Time) map[string]stringEntry {
+	latest := map[string]stringEntry{
+		"load_1":  {Timestamp: t, Value: formatNumber(stats.Load1, 2)},
+		"load_5":  {Timestamp: t, Value: formatNumber(stats.Load5, 2)},
+		"load_15": {Timestamp: t, Value: formatNumber(stats.Load15, 2)},
+	}
+	if cores > 0 {
+		latest["load_1_per_core"] = stringEntry{Timestamp: t, Value: formatNumber(stats.Load1/float64(cores), 2)}
+	}
+	return latest
+}
+
+func getLoadMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"load_1": {
+			ID:       "load_1",
+			Label:    "Load (1m)",
+			Datatype: "number",
+			Priority: priorityUtilization + 0.1,
+			From:     "latest",
+		},
+		"load_5": {
+			ID:       "load_5",
+			Label:    "Load (5m)",
+			Datatype: "number",
+			Priority: priorityUtilization + 0.1,
+			From:     "latest",
+		},
+		"load_15": {
+			ID:       "load_15",
+			Label:    "Load (15m)",
+			Datatype: "number",
+			Priority: priorityUtilization + 0.1,
+			From:     "latest",
+		},
+		"load_1_per_core": {
+			ID:       "load_1_per_core",
+			Label:    "Load per Core (1m)",
+			Datatype: "number",
+			Priority: priorityUtilization + 0.1,
+			From:     "latest",
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadLatest(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	stats := LoadStats{Load1: 2, Load5: 1.5, Load15: 0.25}
+	tests := []struct {
+		name  string
+		stats LoadStats
+		cores int
+		want  map[string]string
+	}{
+		{
+			name:  "unknown cores",
+			stats: stats,
+			cores: 0,
+			want:  map[string]string{"load_1": "2.00", "load_5": "1.50", "load_15": "0.25"},
+		},
+		{
+			name:  "negative cores",
+			stats: stats,
+			cores: -1,
+			want:  map[string]string{"load_1": "2.00", "load_5": "1.50", "load_15": "0.25"},
+		},
+		{
+			name:  "per core",
+			stats: stats,
+			cores: 4,
+			want:  map[string]string{"load_1": "2.00", "load_5": "1.50", "load_15": "0.25", "load_1_per_core": "0.50"},
+		},
+		{
+			name:  "overloaded large host",
+			stats: LoadStats{Load1: 96, Load5: 80, Load15: 64},
+			cores: 64,
+			want:  map[string]string{"load_1": "96.00", "load_5": "80.00", "load_15": "64.00", "load_1_per_core": "1.50"},
+		},
+		{
+			name:  "single core",
+			stats: LoadStats{Load1: 0.333},
+			cores: 1,
+			want:  map[string]string{"load_1": "0.33", "load_5": "0.00", "load_15": "0.00", "load_1_per_core": "0.33"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := loadLatest(tt.stats, tt.cores, now)
+			if len(got) != len(tt.want) {
+				t.Errorf("got %d keys, want %d", len(got), len(tt.want))
+			}
+			for k, want := range tt.want {
+				if got[k].Value != want {
+					t.Errorf("%s = %q, want %q", k, got[k].Value, want)
+				}
+			}
+		})
+	}
+
+	if _, ok := getLoadMetadataTemplate()["load_1_per_core"]; !ok {
+		t.Error("no load_1_per_core template")
+	}
+}
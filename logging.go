@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	logLevelDebug int32 = iota
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+)
+
+var logLevel = logLevelInfo
+
+func parseLogLevel(s string) (int32, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return logLevelDebug, nil
+	case "", "info":
+		return logLevelInfo, nil
+	case "warn", "warning":
+		return logLevelWarn, nil
+	case "error":
+		return logLevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// setLogLevel changes the log level, it is safe to call while logging.
+func setLogLevel(s string) error {
+	level, err := parseLogLevel(s)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&logLevel, level)
+	return nil
+}
+
+func debugf(format string, args ...interface{}) {
+	if atomic.LoadInt32(&logLevel) <= logLevelDebug {
+		log.Printf("debug: "+format, args...)
+	}
+}
+
+func warnf(format string, args ...interface{}) {
+	if atomic.LoadInt32(&logLevel) <= logLevelWarn {
+		log.Printf("warning: "+format, args...)
+	}
+}
+
+func errorf(format string, args ...interface{}) {
+	if atomic.LoadInt32(&logLevel) <= logLevelError {
+		log.Printf("error: "+format, args...)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLevelledLogging(t *testing.T) {
+	tests := []struct {
+		level string
+		want  []string
+	}{
+		{level: "debug", want: []string{"debug: d", "warning: w", "error: e"}},
+		{level: "info", want: []string{"warning: w", "error: e"}},
+		{level: "warn", want: []string{"warning: w", "error: e"}},
+		{level: "error", want: []string{"error: e"}},
+	}
+	defer setLogLevel("info")
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	log.SetFlags(0)
+	defer log.SetFlags(log.LstdFlags)
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			if err := setLogLevel(tt.level); err != nil {
+				t.Fatal(err)
+			}
+			buf.Reset()
+			debugf("d")
+			warnf("w")
+			errorf("e")
+			got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("logged %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
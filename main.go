@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os/signal"
+	"os/user"
 	"sync"
 	"syscall"
 	"time"
@@ -13,26 +18,63 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/host"
 	"github.com/shirou/gopsutil/v3/mem"
+	"golang.org/x/time/rate"
 )
 
 const (
-	cpuinfoTablePrefix = "cpuinfo-table-"
+	defaultCPUInfoTablePrefix = "cpuinfo-table-"
+
+	// Scope renders tables of this type with one row per entry, keyed as
+	// prefix + row ID + tableEntryKeySeparator + column ID.
+	multicolumnTableType   = "multicolumn-table"
+	tableEntryKeySeparator = "___"
+)
+
+// Build metadata, injected at build time with
+// -ldflags "-X main.version=... -X main.buildDate=... -X main.commit=...".
+var (
+	version   = "dev"
+	buildDate = "unknown"
+	commit    = "unknown"
 )
 
+func versionString() string {
+	return fmt.Sprintf("cpuinfo-plugin version %s (built %s, %s)", version, buildDate, commit)
+}
+
 type CPUStats struct {
 	CPUModel       string
 	ProcessorCount int
+	CPUFlags       []string
+	// Mhz is the current frequency of the first CPU as reported by the
+	// kernel, which includes boost.
+	Mhz float64
+	// Microarch is the microarchitecture name, empty when not known for
+	// the architecture.
+	Microarch string
 }
 
+// MemStats holds memory sizes in bytes; Scope's filesize datatype takes
+// care of formatting them.
 type MemStats struct {
-	MemTotalGB int
+	MemTotalBytes uint64
+	UsedPercent   float64
 }
 
-func setupSocket(socketPath string) (net.Listener, error) {
-	os.RemoveAll(filepath.Dir(socketPath))
+// setupSocket listens on socketPath, only accessible by the owner, or by
+// the owner and group when group is set.
+func setupSocket(socketPath, group string) (net.Listener, error) {
+	cleanupSocket(socketPath)
+	if isSymlink(filepath.Dir(socketPath)) {
+		log.Printf("Socket directory %q is a symlink, it will be used but not removed", filepath.Dir(socketPath))
+	}
 	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
 		return nil, fmt.Errorf("failed to create directory %q: %v", filepath.Dir(socketPath), err)
 	}
@@ -40,49 +82,252 @@ func setupSocket(socketPath string) (net.Listener, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to listen on %q: %v", socketPath, err)
 	}
+	if err := restrictSocket(socketPath, group); err != nil {
+		listener.Close()
+		return nil, err
+	}
 
 	log.Printf("Listening on: unix://%s", socketPath)
 	return listener, nil
 }
 
-func setupSignals(socketPath string) {
+// cleanupSocket removes the socket and its directory, unless other files
+// such as the state file live there. A symlinked directory is left alone:
+// removing it would unlink the operator's symlink.
+func cleanupSocket(socketPath string) {
+	os.Remove(socketPath)
+	if dir := filepath.Dir(socketPath); !isSymlink(dir) {
+		os.Remove(dir)
+	}
+}
+
+// restrictSocket makes the socket owner-only, the socket file otherwise
+// inheriting the umask, or gives it and its directory to group and lets the
+// group use them.
+func restrictSocket(socketPath, group string) error {
+	mode := os.FileMode(0600)
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return fmt.Errorf("failed to look up socket group: %v", err)
+		}
+		gid, err := strconv.Atoi(g.Gid)
+		if err != nil {
+			return fmt.Errorf("invalid gid %q of group %q", g.Gid, group)
+		}
+		if err := os.Chown(socketPath, -1, gid); err != nil {
+			return fmt.Errorf("failed to set the group of %q: %v", socketPath, err)
+		}
+		mode = 0660
+		if dir := filepath.Dir(socketPath); !isSymlink(dir) {
+			if err := os.Chown(dir, -1, gid); err != nil {
+				return fmt.Errorf("failed to set the group of %q: %v", dir, err)
+			}
+			if err := os.Chmod(dir, 0750); err != nil {
+				return fmt.Errorf("failed to set the mode of %q: %v", dir, err)
+			}
+		}
+	}
+	if err := os.Chmod(socketPath, mode); err != nil {
+		return fmt.Errorf("failed to set the mode of %q: %v", socketPath, err)
+	}
+	return nil
+}
+
+func isSymlink(path string) bool {
+	fi, err := os.Lstat(path)
+	return err == nil && fi.Mode()&os.ModeSymlink != 0
+}
+
+// setupSocketWithRetry calls setupSocket, retrying with exponential backoff
+// for up to timeout. Scope may create /var/run/scope shortly after we start.
+func setupSocketWithRetry(socketPath, group string, timeout time.Duration) (net.Listener, error) {
+	const maxBackoff = 5 * time.Second
+	backoff := 250 * time.Millisecond
+	deadline := time.Now().Add(timeout)
+	for {
+		listener, err := setupSocket(socketPath, group)
+		if err == nil {
+			return listener, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, err
+		}
+		warnf("%v, retrying in %s", err, backoff)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// resolveHostID returns override if set, and the hostname otherwise.
+func resolveHostID(override string) (string, error) {
+	hostID := strings.TrimSpace(override)
+	if hostID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return "", fmt.Errorf("failed to get hostname: %v", err)
+		}
+		hostID = hostname
+	}
+	if hostID == "" {
+		return "", fmt.Errorf("host ID is empty, set SCOPE_HOST_ID or -host-id")
+	}
+	return hostID, nil
+}
+
+// resolveDomainSuffix replaces the special "machine-id" suffix with the
+// machine UUID.
+func resolveDomainSuffix(suffix string) (string, error) {
+	if suffix != machineIDSuffix {
+		return suffix, nil
+	}
+	id, err := host.HostID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get machine ID: %v", err)
+	}
+	return id, nil
+}
+
+// setupListener listens on the TCP address cfg.ListenTCP if set, with mutual
+// TLS when the TLS files are set, and on the Unix socket cfg.SocketPath
+// otherwise. cleanup removes the socket.
+func setupListener(cfg Config) (listener net.Listener, cleanup func(), err error) {
+	if cfg.ListenTCP != "" {
+		listener, err := net.Listen("tcp", cfg.ListenTCP)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to listen on %q: %v", cfg.ListenTCP, err)
+		}
+		if cfg.TLSCert == "" {
+			log.Printf("Listening on: tcp://%s", listener.Addr())
+			return listener, func() {}, nil
+		}
+		tlsConfig, err := mtlsConfig(cfg.TLSCert, cfg.TLSKey, cfg.TLSCA)
+		if err != nil {
+			listener.Close()
+			return nil, nil, err
+		}
+		log.Printf("Listening on: https://%s, client certificates required", listener.Addr())
+		return tls.NewListener(listener, tlsConfig), func() {}, nil
+	}
+	listener, err = setupSocketWithRetry(cfg.SocketPath, cfg.SocketGroup, cfg.SocketRetryTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	return listener, func() { cleanupSocket(cfg.SocketPath) }, nil
+}
+
+func setupSignals(cleanup func(), plugin *Plugin) {
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-interrupt
-		os.RemoveAll(filepath.Dir(socketPath))
+		plugin.finalPush(shutdownPushTimeout)
+		cleanup()
 		os.Exit(0)
 	}()
 }
 
 func main() {
-	// We put the socket in a sub-directory to have more control on the permissions
-	const socketPath = "/var/run/scope/plugins/cpuinfo/cpuinfo.sock"
-	hostID, _ := os.Hostname()
+	cfg := loadConfig()
 
-	// Handle the exit signal
-	setupSignals(socketPath)
+	listMetricsFlag := flag.Bool("list-metrics", false, "print all metric keys and their templates as JSON and exit")
+	validateReportFlag := flag.Bool("validate-report", false, "collect one report, check it against the Scope report schema and exit nonzero if it doesn't match")
+	versionFlag := flag.Bool("version", false, "print version information and exit")
+	flag.StringVar(&cfg.HostID, "host-id", cfg.HostID, "host ID used for the Scope node, defaults to $SCOPE_HOST_ID or the hostname")
+	flag.StringVar(&cfg.ConfigFile, "config", "", "JSON config file, re-read on SIGHUP")
+	flag.StringVar(&cfg.TemplatesFile, "templates", cfg.TemplatesFile, "JSON file with metadata and table templates overriding the built-in ones by ID")
+	flag.StringVar(&cfg.KeyPrefix, "key-prefix", cfg.KeyPrefix, "prefix added to every reported key and template ID, e.g. cpuinfo_")
+	flag.StringVar(&cfg.CollectMode, "collect-mode", cfg.CollectMode, "background to serve metrics collected every refresh interval, sync to collect them on every report")
+	flag.DurationVar(&cfg.StdoutInterval, "stdout-interval", cfg.StdoutInterval, "also print the report to stdout as a JSON line at this interval, 0 disables")
+	flag.Func("iface-filter", "comma-separated interface globs left out of network stats and ip_addresses, defaults to $CPUINFO_NET_IFACE_DENY", func(s string) error {
+		cfg.NetIfaceDeny = splitList(s)
+		return nil
+	})
+	flag.IntVar(&cfg.PercentPrecision, "percent-precision", cfg.PercentPrecision, "decimals of percent values, 0 to 3")
+	flag.BoolVar(&cfg.Pretty, "pretty", cfg.Pretty, "indent the JSON served on /report, /control and the debug endpoints")
+	flag.BoolVar(&cfg.StdoutDeltas, "stdout-deltas", cfg.StdoutDeltas, "after the first -stdout-interval line, only print the values that changed")
+	flag.StringVar(&cfg.InfluxURL, "influx-url", cfg.InfluxURL, "push CPU, memory and load to this InfluxDB URL every refresh interval")
+	flag.StringVar(&cfg.InfluxDB, "influx-db", cfg.InfluxDB, "InfluxDB database used with -influx-url")
+	flag.BoolVar(&cfg.NoController, "no-controller", cfg.NoController, "only advertise the reporter interface and serve no /control")
+	flag.StringVar(&cfg.ControlToken, "control-token", cfg.ControlToken, "require this bearer token on /control requests, defaults to $CPUINFO_CONTROL_TOKEN")
+	flag.Parse()
+
+	if cfg.ConfigFile != "" {
+		if err := applyConfigFile(&cfg, cfg.ConfigFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		log.Fatal(err)
+	}
+	setLogLevel(cfg.LogLevel)
+	setPercentPrecision(cfg.PercentPrecision)
+
+	if *versionFlag {
+		fmt.Println(versionString())
+		os.Exit(0)
+	}
+
+	if *listMetricsFlag {
+		if err := listMetrics(os.Stdout, cfg); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	hostID, err := resolveHostID(cfg.HostID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.DomainSuffix, err = resolveDomainSuffix(cfg.DomainSuffix)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, name := range cfg.dropPrivilegedCollectors(hasPrivileges(cfg.ProcPath)) {
+		warnf("disabled the %s collector, its %s need root, CAP_SYS_ADMIN or CAP_PERFMON", name, privilegedCollectors[name])
+	}
+
+	if *validateReportFlag {
+		if err := checkReport(NewPlugin(hostID, cfg)); err != nil {
+			log.Fatalf("invalid report: %v", err)
+		}
+		fmt.Println("report is valid")
+		os.Exit(0)
+	}
 
 	log.Printf("Starting on %s...\n", hostID)
+	logStartup(cfg)
 
-	_, err := getCPUStats()
+	_, err = getCPUStats(context.Background(), nil)
 	if err != nil {
 		log.Fatal(err)
 	}
+	checkGOMAXPROCS(cfg.CgroupPath)
+
+	plugin := NewPlugin(hostID, cfg)
+	if cfg.StateFile != "" {
+		if err := plugin.loadState(cfg.StateFile); err != nil {
+			warnf("%v", err)
+		}
+	}
 
-	listener, err := setupSocket(socketPath)
+	listener, cleanup, err := setupListener(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer func() {
 		listener.Close()
-		os.RemoveAll(filepath.Dir(socketPath))
+		cleanup()
 	}()
 
-	plugin := &Plugin{HostID: hostID}
-	http.HandleFunc("/report", plugin.Report)
-	if err := http.Serve(listener, nil); err != nil {
-		log.Printf("error: %v", err)
+	// Handle the exit signal
+	setupSignals(cleanup, plugin)
+
+	if err := plugin.Run(listener); err != nil {
+		errorf("%v", err)
 	}
 }
 
@@ -90,8 +335,101 @@ func main() {
 type Plugin struct {
 	HostID string
 
-	lock        sync.Mutex
+	// now returns the time used for all timestamps in reports, time.Now
+	// unless replaced to get deterministic output.
+	now func() time.Time
+
+	// lock guards cfg and last. It is never held while collecting, so that
+	// reports are served from the last collection while another runs.
+	lock sync.Mutex
+	cfg  Config
+	// last is the latest successful collection.
+	last *collection
+
+	// collecting is a semaphore held by the collection in progress. It
+	// guards the samplers and collectors below.
+	collecting  chan struct{}
 	cpuinfoMode bool
+	net         netSampler
+	cpuTimes    cpuTimesSampler
+	diskIO      diskIOSampler
+	tcp         tcpSampler
+	swap        swapSampler
+	ema         emaSmoother
+	cpuHistory  metricHistory
+	overrides   templateOverrides
+	self        selfSampler
+	// k8sLimitsWarned is set once a limit mismatch was logged, so it is
+	// logged only once.
+	k8sLimitsWarned bool
+	// privileged is whether the plugin may collect the privilegedCollectors.
+	privileged    bool
+	refreshCounts refreshCounts
+
+	intervalChanged chan struct{}
+	watchdog        watchdog
+
+	// collectors run first in every collection, starting with cpu and mem.
+	cpu           *CPUCollector
+	mem           *MemCollector
+	procs         *ProcessCollector
+	collectors    []MetricCollector
+	reportLimiter *rate.Limiter
+}
+
+// NewPlugin returns a Plugin reporting for hostID with the given config.
+func NewPlugin(hostID string, cfg Config) *Plugin {
+	cpuCollector, memCollector := &CPUCollector{}, &MemCollector{}
+	p := &Plugin{
+		HostID:          hostID,
+		now:             time.Now,
+		cfg:             cfg,
+		intervalChanged: make(chan struct{}, 1),
+		collecting:      make(chan struct{}, 1),
+		reportLimiter:   newReportLimiter(cfg.ReportRateLimit),
+		privileged:      hasPrivileges(cfg.ProcPath),
+		net: netSampler{
+			filter: ifaceFilter{allow: cfg.NetIfaceAllow, deny: cfg.NetIfaceDeny},
+			table:  cfg.NetTable,
+		},
+		diskIO:     diskIOSampler{deny: cfg.DiskIODeny},
+		ema:        emaSmoother{alpha: cfg.EMAAlpha},
+		cpu:        cpuCollector,
+		mem:        memCollector,
+		collectors: []MetricCollector{cpuCollector, memCollector},
+	}
+	// validate has already checked the templates file.
+	p.overrides, _ = loadTemplateOverrides(cfg.TemplatesFile)
+	if cfg.processesEnabled() {
+		p.procs = &ProcessCollector{
+			N:           cfg.TopNProcesses,
+			TopCPU:      cfg.TopCPUProcess,
+			TopMem:      cfg.TopMemProcess,
+			States:      cfg.ProcessStates,
+			MinInterval: cfg.ProcessMinInterval,
+			source:      &gopsutilProcessSource{withStatus: cfg.ProcessStates},
+		}
+		p.collectors = append(p.collectors, p.procs)
+	}
+	return p
+}
+
+// Run starts the refresher and the configured reporters, then serves the
+// plugin on listener until serving fails. Collectors must be registered
+// before.
+func (p *Plugin) Run(listener net.Listener) error {
+	cfg := p.config()
+	p.setupReload()
+	if cfg.CollectMode == collectBackground {
+		go p.runRefresher(make(chan struct{}))
+	}
+	if cfg.InfluxURL != "" {
+		go p.runInfluxPusher(make(chan struct{}))
+	}
+	if cfg.StdoutInterval > 0 {
+		go p.runStdoutReporter(os.Stdout, cfg.StdoutInterval, cfg.StdoutDeltas, make(chan struct{}))
+	}
+	return http.Serve(listener, logRequests(p))
 }
 
 type request struct {
@@ -103,21 +441,53 @@ type response struct {
 	ShortcutReport *report `json:"shortcutReport,omitempty"`
 }
 
+// report holds one field per topology, named like Scope's. Host is always
+// reported; the other topologies are only serialized when they are set.
 type report struct {
-	Host    topology
-	Plugins []pluginSpec
+	Host      topology
+	Disk      *topology `json:",omitempty"`
+	Process   *topology `json:",omitempty"`
+	Container *topology `json:",omitempty"`
+	Pod       *topology `json:",omitempty"`
+	Service   *topology `json:",omitempty"`
+	Plugins   []pluginSpec
 }
 
 type topology struct {
 	Nodes             map[string]node             `json:"nodes"`
 	MetadataTemplates map[string]metadataTemplate `json:"metadata_templates,omitempty"`
 	TableTemplates    map[string]tableTemplate    `json:"table_templates,omitempty"`
+	MetricTemplates   map[string]metricTemplate   `json:"metric_templates,omitempty"`
+	Controls          map[string]control          `json:"controls,omitempty"`
+	// Adjacency maps node IDs to the IDs of the nodes they have edges to,
+	// across topologies.
+	Adjacency map[string][]string `json:"adjacency,omitempty"`
+	// Merge declares how Scope should correlate the topology's nodes with
+	// its own. Only the host topology sets it.
+	Merge *mergeSpec `json:"merge,omitempty"`
+}
+
+// mergeSpec is a merge hint, e.g. {"by": "hostname"}. Scope itself merges
+// nodes by ID, see getTopologyHost; the hint names the key the IDs derive
+// from.
+type mergeSpec struct {
+	By string `json:"by"`
 }
 
 type tableTemplate struct {
-	ID     string `json:"id"`
-	Label  string `json:"label"`
-	Prefix string `json:"prefix"`
+	ID      string   `json:"id"`
+	Label   string   `json:"label"`
+	Prefix  string   `json:"prefix"`
+	Type    string   `json:"type,omitempty"`
+	Columns []column `json:"columns,omitempty"`
+	// LastUpdated is when the table's values were collected.
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+type column struct {
+	ID       string `json:"id"`
+	Label    string `json:"label"`
+	DataType string `json:"dataType"`
 }
 
 type metadataTemplate struct {
@@ -129,8 +499,39 @@ type metadataTemplate struct {
 	From     string  `json:"from,omitempty"` // Defines how to get the value from a report node
 }
 
+// setTemplate describes one of a node's Sets. Scope has no separate set
+// templates, it reads metadata templates with From "sets", see
+// TemplateRegistry.RegisterSets.
+type setTemplate struct {
+	ID       string
+	Label    string
+	Truncate int
+	Priority float64
+}
+
+// metadata returns the metadata template Scope reads the set through.
+func (t setTemplate) metadata() metadataTemplate {
+	return metadataTemplate{
+		ID:       t.ID,
+		Label:    t.Label,
+		Truncate: t.Truncate,
+		Priority: t.Priority,
+		From:     "sets",
+	}
+}
+
 type node struct {
-	Latest map[string]stringEntry `json:"latest,omitempty"`
+	Latest         map[string]stringEntry  `json:"latest,omitempty"`
+	LatestControls map[string]controlEntry `json:"latestControls,omitempty"`
+	Adjacency      []string                `json:"adjacency,omitempty"`
+	// Sets holds multi-valued attributes, described by metadata templates
+	// with From "sets".
+	Sets map[string][]string `json:"sets,omitempty"`
+	// Counters holds numeric values Scope can render as graphs.
+	Counters map[string]float64 `json:"counters,omitempty"`
+	// Metrics holds recent samples Scope renders as sparklines, described
+	// by the topology's metric templates.
+	Metrics map[string]metric `json:"metrics,omitempty"`
 }
 
 type stringEntry struct {
@@ -146,71 +547,564 @@ type pluginSpec struct {
 	APIVersion  string   `json:"api_version,omitempty"`
 }
 
-func (p *Plugin) makeReport() (*report, error) {
-	metrics, err := p.metrics()
+// collection is one collection of the host node, with the templates
+// registered and the sample taken while collecting it.
+type collection struct {
+	node      node
+	templates *TemplateRegistry
+	sample    hostSample
+	// processes are the top processes for the Process topology, nil when
+	// it is disabled.
+	processes []ProcessStats
+	// disks are the disk devices for the Disk topology, nil when it is
+	// disabled or failed.
+	disks []DiskStats
+	// remotes are the reports of the remote hosts that answered.
+	remotes []report
+	// degraded lists the collectors that failed. When the collection
+	// failed, it holds the required collector that did.
+	degraded []string
+}
+
+// config returns a copy of the current configuration.
+func (p *Plugin) config() Config {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.cfg
+}
+
+// makeReport returns ctx's error if ctx is done before the report is
+// complete.
+func (p *Plugin) makeReport(ctx context.Context) (*report, error) {
+	c, err := p.hostCollection(ctx)
 	if err != nil {
 		return nil, err
 	}
+	cfg := p.config()
+	hostNodeID := p.getTopologyHost(cfg.DomainSuffix)
+
+	metrics := c.node
+	interfaces := []string{"reporter"}
+	if !cfg.NoController {
+		metrics.LatestControls = latestControls(p.now())
+		interfaces = append(interfaces, "controller")
+	}
 	rpt := &report{
 		Host: topology{
 			Nodes: map[string]node{
-				p.getTopologyHost(): metrics,
+				hostNodeID: metrics,
 			},
-			TableTemplates:    getTableTemplate(),
-			MetadataTemplates: getMetadataTemplate(),
+			TableTemplates:    stampTables(c.templates.TableTemplates(), c.sample.Time),
+			MetadataTemplates: c.templates.MetadataTemplates(),
 		},
 		Plugins: []pluginSpec{
 			{
 				ID:          "cpuinfo",
-				Label:       "cpuinfo",
-				Description: "Adds a graph of CPU and memory info to hosts",
-				Interfaces:  []string{"reporter", "controller"},
+				Label:       envString("CPUINFO_PLUGIN_LABEL", "cpuinfo"),
+				Description: envString("CPUINFO_PLUGIN_DESCRIPTION", fmt.Sprintf("Adds a graph of CPU and memory info to hosts (%s)", version)),
+				Interfaces:  interfaces,
 				APIVersion:  "1",
 			},
 		},
 	}
+	if cfg.MergeBy != "none" {
+		rpt.Host.Merge = &mergeSpec{By: cfg.MergeBy}
+	}
+	if len(metrics.Metrics) > 0 {
+		rpt.Host.MetricTemplates = getMetricTemplates(cfg.KeyPrefix)
+	}
+	if !cfg.NoController {
+		rpt.Host.Controls = getControls()
+	}
+	mergeRemoteHosts(&rpt.Host, c.remotes)
+
+	if c.disks != nil {
+		rpt.Disk = p.diskTopology(c.disks, hostNodeID, p.now())
+		rpt.Host.Adjacency = diskHostEdges(rpt.Disk, hostNodeID)
+	}
+	if c.processes != nil {
+		rpt.Process = p.processTopology(c.processes, hostNodeID, p.now())
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return rpt, nil
 }
 
-func (p *Plugin) metrics() (node, error) {
-	cpuInfo, err := getCPUStats()
+// hostCollection returns the last collection of the refresher, collecting
+// on the spot in sync mode or if the refresher hasn't run yet.
+func (p *Plugin) hostCollection(ctx context.Context) (*collection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	last, mode := p.last, p.cfg.CollectMode
+	p.lock.Unlock()
+	if last != nil && mode != collectSync {
+		return last, nil
+	}
+	c, err := p.metrics(ctx)
 	if err != nil {
-		return node{}, err
+		return nil, err
+	}
+	p.publish(ctx, c)
+	return c, nil
+}
+
+// publish makes c the last collection, unless ctx is done: the watchdog
+// abandons hung collections by cancelling their ctx, and their late results
+// are dropped.
+func (p *Plugin) publish(ctx context.Context, c *collection) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if ctx.Err() != nil {
+		return false
+	}
+	p.last = c
+	return true
+}
+
+// metrics collects the host node. Only one collection runs at a time, others
+// wait for it until their ctx is done. It stops with ctx's error when ctx is
+// done.
+func (p *Plugin) metrics(ctx context.Context) (*collection, error) {
+	select {
+	case p.collecting <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
+	defer func() { <-p.collecting }()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cfg := p.config()
+	reg := NewTemplateRegistry()
+
+	// Remote reports are fetched while the host is collected, and are
+	// waited for at the end.
+	remotes := make(chan []report, 1)
+	go func() {
+		if len(cfg.RemoteHosts) == 0 {
+			remotes <- nil
+			return
+		}
+		client := &http.Client{Timeout: remoteReportTimeout}
+		remotes <- fetchRemoteReports(ctx, client, cfg.RemoteHosts, cfg.TCPPort)
+	}()
+
+	n := node{Latest: map[string]stringEntry{}}
+	tnot := p.now()
+	var health collectorHealth
+	// raw holds the unformatted values of the keys smoothed by p.ema.
+	raw := map[string]float64{}
+
+	if failed, err := p.runCollectors(ctx, n.Latest, reg, &health, tnot); err != nil {
+		if failed == "" {
+			return nil, err
+		}
+		return &collection{degraded: []string{failed}}, err
+	}
+	cpuInfo, memInfo := p.cpu.Stats, p.mem.Stats
 
-	memInfo, err := getMemStats()
+	n.Sets = cpuFlagsSets(cpuInfo.CPUFlags)
+	for k, v := range cpuFlagsLatest(cpuInfo.CPUFlags, tnot) {
+		n.Latest[k] = v
+	}
+	reg.RegisterSets(getCPUFlagsSetTemplate(cfg.CPUFlagsTruncate))
+	reg.RegisterTables(getTableTemplate())
+
+	sample := hostSample{Time: tnot, CPU: cpuInfo, Mem: memInfo}
+
+	usage, ok, err := p.cpuTimes.getCPUUsageStats(ctx, reg)
 	if err != nil {
-		return node{}, err
+		health.degrade("cpu_usage", err)
+	} else if ok {
+		for k, v := range cpuUsageLatest(usage, tnot) {
+			n.Latest[k] = v
+		}
+		raw["cpu_user_percent"] = usage.UserPercent
+		raw["cpu_system_percent"] = usage.SystemPercent
+		raw["cpu_idle_percent"] = usage.IdlePercent
+		sample.CPUUsage = &usage
 	}
 
-	n := node{}
-	tnot := time.Now()
-	n.Latest = map[string]stringEntry{
-		"cpu_model": {
-			Timestamp: tnot,
-			Value:     cpuInfo.CPUModel,
-		},
-		"processor_count": {
-			Timestamp: tnot,
-			Value:     fmt.Sprintf("%d", cpuInfo.ProcessorCount),
-		},
-		"platform_memory": {
-			Timestamp: tnot,
-			Value:     fmt.Sprintf("%d", memInfo.MemTotalGB),
-		},
+	cacheInfo, err := getCacheTopology(cfg.CPUSysfsPath, reg)
+	if err == nil {
+		for k, v := range cacheLatest(cacheInfo, tnot) {
+			n.Latest[k] = v
+		}
+	} else if !sysfsMissing(err) {
+		health.degrade("cache", err)
+	}
+
+	swapRates, ok, err := p.swap.getSwapRateStats(ctx, reg)
+	if err != nil {
+		health.degrade("swap", err)
+	} else if ok {
+		for k, v := range swapLatest(swapRates, tnot) {
+			n.Latest[k] = v
+		}
+		raw["swap_in_bytes_sec"] = swapRates.InBytesPerSec
+		raw["swap_out_bytes_sec"] = swapRates.OutBytesPerSec
+	}
+
+	commitInfo, err := getCommitStats(ctx, cfg.ProcPath, memInfo.MemTotalBytes, reg)
+	if err == nil {
+		for k, v := range commitLatest(commitInfo, tnot) {
+			n.Latest[k] = v
+		}
+	} else if !sysfsMissing(err) {
+		health.degrade("mem_commit", err)
+	}
+
+	eccInfo, err := getECCStats(ctx, cfg.EDACPath, reg)
+	if err == nil {
+		for k, v := range eccLatest(eccInfo, tnot) {
+			n.Latest[k] = v
+		}
+	} else if !sysfsMissing(err) {
+		health.degrade("ecc", err)
+	}
+
+	numaInfo, err := getNUMAMemStats(ctx, cfg.NUMANodePath, reg)
+	if err == nil {
+		for k, v := range numaLatest(numaInfo, tnot) {
+			n.Latest[k] = v
+		}
+	} else if !sysfsMissing(err) {
+		health.degrade("numa", err)
+	}
+
+	vmInfo, err := getVMSysctlStats(ctx, filepath.Join(cfg.ProcPath, "sys"), reg)
+	if err == nil {
+		for k, v := range vmSysctlLatest(vmInfo, tnot) {
+			n.Latest[k] = v
+		}
+	} else if !sysfsMissing(err) {
+		health.degrade("vm_sysctl", err)
+	}
+
+	coreTypes, err := getCoreTypeStats(ctx, cfg.CPUSysfsPath, cfg.CoreTypeSource, reg)
+	if err == nil {
+		for k, v := range coreTypeLatest(coreTypes, tnot) {
+			n.Latest[k] = v
+		}
+	} else if err != nil && !sysfsMissing(err) {
+		health.degrade("core_types", err)
+	}
+
+	onlineInfo, err := getCPUOnlineStats(ctx, cfg.CPUSysfsPath, reg)
+	if err == nil {
+		for k, v := range cpuOnlineLatest(onlineInfo, tnot) {
+			n.Latest[k] = v
+		}
+	} else if !sysfsMissing(err) {
+		health.degrade("cpu_online", err)
+	}
+
+	baseMhz, err := getCPUBaseMhz(cfg.CPUSysfsPath, reg)
+	if err == nil {
+		for k, v := range cpuFreqLatest("cpu_base_mhz", baseMhz, tnot) {
+			n.Latest[k] = v
+		}
+	} else if !sysfsMissing(err) {
+		health.degrade("cpu_base_freq", err)
+	}
+
+	governor, err := getCPUGovernor(cfg.CPUSysfsPath, reg)
+	if err == nil {
+		for k, v := range cpuGovernorLatest(governor, tnot) {
+			n.Latest[k] = v
+		}
+	} else if !sysfsMissing(err) {
+		health.degrade("cpu_governor", err)
+	}
+
+	vulnInfo, err := getVulnerabilityStats(ctx, cfg.CPUSysfsPath, reg)
+	if err == nil {
+		for k, v := range vulnerabilityLatest(vulnInfo, tnot) {
+			n.Latest[k] = v
+		}
+	} else if !sysfsMissing(err) {
+		health.degrade("cpu_vulnerabilities", err)
+	}
+
+	smtEnabled, err := getSMTEnabled(ctx, cfg.CPUSysfsPath, reg)
+	if err != nil {
+		health.degrade("smt", err)
+	} else {
+		for k, v := range smtLatest(smtEnabled, tnot) {
+			n.Latest[k] = v
+		}
+	}
+
+	cgroupInfo, err := getCgroupStats(ctx, cfg.CgroupPath, reg)
+	if err == nil {
+		for k, v := range cgroupLatest(cgroupInfo, tnot) {
+			n.Latest[k] = v
+		}
+	} else if !sysfsMissing(err) {
+		health.degrade("cgroup", err)
+	}
+
+	if len(cfg.K8sLimitFiles) > 0 {
+		k8sLimits, err := getK8sLimitStats(ctx, cfg.K8sLimitFiles, reg)
+		if err != nil {
+			health.degrade("k8s_limits", err)
+		} else {
+			for k, v := range k8sLimitLatest(k8sLimits, tnot) {
+				n.Latest[k] = v
+			}
+			memLimit, _ := readCgroupMemoryLimit(cfg.CgroupPath)
+			if mismatches := checkK8sLimits(k8sLimits, cgroupInfo, memLimit); len(mismatches) > 0 && !p.k8sLimitsWarned {
+				for _, m := range mismatches {
+					warnf("%s", m)
+				}
+				p.k8sLimitsWarned = true
+			}
+		}
+	}
+
+	if cfg.CStateStats {
+		cstates, err := getCStateStats(ctx, cfg.CPUSysfsPath, reg)
+		if err == nil {
+			for k, v := range cstateLatest(cstates, tnot) {
+				n.Latest[k] = v
+			}
+		} else if !sysfsMissing(err) {
+			health.degrade("cstate", err)
+		}
+	}
+
+	if cfg.PSUStats {
+		psuInfo, err := getPSUStats(ctx, defaultPowerSupplyPath, reg)
+		if err == nil {
+			for k, v := range psuLatest(psuInfo, tnot) {
+				n.Latest[k] = v
+			}
+		} else if !sysfsMissing(err) {
+			health.degrade("psu", err)
+		}
 	}
 
-	return n, nil
+	if cfg.MemBandwidth {
+		bw, ok, err := getMemBandwidthStats(ctx, defaultMemBandwidthReader(), reg)
+		if err != nil {
+			health.degrade("mem_bandwidth", err)
+		} else if ok {
+			for k, v := range memBandwidthLatest(bw, tnot) {
+				n.Latest[k] = v
+			}
+		}
+	}
+
+	if cfg.SelfStats {
+		selfInfo, ok, err := p.self.getSelfStats(ctx, cfg.ProcPath, cfg.CgroupPath, reg)
+		if err == nil && ok {
+			for k, v := range selfLatest(selfInfo, tnot) {
+				n.Latest[k] = v
+			}
+		} else if err != nil && !sysfsMissing(err) {
+			health.degrade("self", err)
+		}
+	}
+
+	if cfg.GPUStats {
+		gpuInfo, ok, err := getGPUStats(ctx, nvidiaSMICommand, cfg.GPUTimeout, reg)
+		if err != nil {
+			health.degrade("gpu", err)
+		} else if ok {
+			for k, v := range gpuLatest(gpuInfo, tnot) {
+				n.Latest[k] = v
+			}
+		}
+	}
+
+	securityInfo, err := getSecurityStats(ctx, defaultSysPath, reg)
+	if err == nil {
+		for k, v := range securityLatest(securityInfo, tnot) {
+			n.Latest[k] = v
+		}
+	} else if !sysfsMissing(err) {
+		health.degrade("security", err)
+	}
+
+	failedUnits, ok, err := getFailedUnits(ctx, defaultSystemdRunDir, systemctlFailedCommand, reg)
+	if err != nil {
+		health.degrade("systemd", err)
+	} else if ok {
+		for k, v := range failedUnitsLatest(failedUnits, tnot) {
+			n.Latest[k] = v
+		}
+	}
+
+	loadInfo, err := getLoadStats(ctx, reg)
+	if err != nil {
+		if !sysfsMissing(err) {
+			health.degrade("load", err)
+		}
+	} else {
+		for k, v := range loadLatest(loadInfo, cpuInfo.ProcessorCount, tnot) {
+			n.Latest[k] = v
+		}
+		sample.Load = &loadInfo
+	}
+
+	netInfo, err := p.net.getNetStats(ctx, reg)
+	if err != nil {
+		health.degrade("net", err)
+	} else {
+		for k, v := range netLatest(netInfo, p.net.table, tnot) {
+			n.Latest[k] = v
+		}
+		raw["net_rx_bytes_per_sec"] = netInfo.RxBytesPerSec
+		raw["net_tx_bytes_per_sec"] = netInfo.TxBytesPerSec
+		raw["net_total_errors_per_sec"] = netInfo.ErrorsPerSec
+	}
+
+	retransPerSec, ok, err := p.tcp.getTCPRetransmitRate(ctx, cfg.ProcPath, reg)
+	if err == nil && ok {
+		for k, v := range tcpLatest(retransPerSec, tnot) {
+			n.Latest[k] = v
+		}
+		raw["net_tcp_retransmits_per_sec"] = retransPerSec
+	} else if err != nil && !sysfsMissing(err) {
+		health.degrade("tcp", err)
+	}
+
+	ifaceInfo, err := getInterfaceStats(ctx, p.net.filter, reg)
+	if err != nil {
+		health.degrade("ip_addresses", err)
+	} else {
+		for k, v := range ipAddressesLatest(ifaceInfo.Addresses, tnot) {
+			n.Latest[k] = v
+		}
+		n.Sets["network_interfaces"] = ifaceInfo.Names
+	}
+
+	routeInfo, err := getDefaultRouteStats(ctx, cfg.IPv4RoutePath, cfg.IPv6RoutePath, reg)
+	if err == nil {
+		for k, v := range defaultRouteLatest(routeInfo, tnot) {
+			n.Latest[k] = v
+		}
+	} else if !sysfsMissing(err) {
+		health.degrade("routes", err)
+	}
+
+	diskIOInfo, err := p.diskIO.getDiskIOStats(ctx, reg)
+	if err != nil {
+		health.degrade("diskio", err)
+	} else {
+		for k, v := range diskIOLatest(diskIOInfo, tnot) {
+			n.Latest[k] = v
+		}
+	}
+
+	inodes, ok, err := getRootInodeStats(ctx, reg)
+	if err != nil {
+		health.degrade("inodes", err)
+	} else if ok {
+		for k, v := range inodeLatest(inodes, tnot) {
+			n.Latest[k] = v
+		}
+	}
+
+	mounts, err := getMountStats(ctx, cfg.DiskIncludeVirtual, reg)
+	if err != nil {
+		health.degrade("mounts", err)
+	} else {
+		for k, v := range mountLatest(mounts, tnot) {
+			n.Latest[k] = v
+		}
+	}
+
+	for k, v := range runtimeLatest(tnot) {
+		n.Latest[k] = v
+	}
+	reg.RegisterMetadata(getRuntimeMetadataTemplate())
+
+	for k, v := range privilegesLatest(p.privileged, tnot) {
+		n.Latest[k] = v
+	}
+	reg.RegisterMetadata(getPrivilegesMetadataTemplate())
+
+	for k, v := range extraLabelsLatest(cfg.ExtraLabels, tnot) {
+		n.Latest[k] = v
+	}
+	reg.RegisterMetadata(getExtraLabelsMetadataTemplate(cfg.ExtraLabels))
+
+	n.Latest["degraded_collectors"] = health.entry(tnot)
+	reg.RegisterMetadata(getHealthMetadataTemplate())
+
+	// Disks are collected with the host, rather than per report, as
+	// smartctl takes a while per disk.
+	var disks []DiskStats
+	if cfg.DiskTopology {
+		disks, err = getDiskStats(ctx)
+		if err != nil {
+			health.degrade("disk_topology", err)
+		}
+	}
+
+	if cfg.RefreshFailuresRow {
+		for k, v := range refreshFailuresLatest(&p.refreshCounts, tnot) {
+			n.Latest[k] = v
+		}
+		reg.RegisterMetadata(getRefreshFailuresMetadataTemplate())
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if cfg.Counters {
+		n.Counters = hostCounters(sample)
+	}
+	n.Metrics = p.hostMetrics(sample)
+	if p.ema.alpha > 0 {
+		p.ema.smooth(n.Latest, raw, reg, tnot)
+	}
+	p.overrides.apply(reg)
+
+	n.Latest = prefixLatest(n.Latest, cfg.KeyPrefix)
+	n.Sets = prefixSets(n.Sets, cfg.KeyPrefix)
+	n.Counters = prefixCounters(n.Counters, cfg.KeyPrefix)
+	n.Metrics = prefixMetrics(n.Metrics, cfg.KeyPrefix)
+	c := &collection{
+		node:      n,
+		templates: reg.prefixed(cfg.KeyPrefix),
+		sample:    sample,
+		disks:     disks,
+		remotes:   <-remotes,
+		degraded:  health.degraded,
+	}
+	if p.procs != nil && p.procs.N > 0 {
+		c.processes = p.procs.Top
+	}
+	return c, nil
 }
 
 func getMetadataTemplate() map[string]metadataTemplate {
+	// Long model names overflow the Scope panel, 0 disables truncation.
+	truncateCPUModel := envInt("CPUINFO_TRUNCATE_CPU_MODEL", 40)
+	if truncateCPUModel < 0 {
+		truncateCPUModel = 0
+	}
+
 	return map[string]metadataTemplate{
 		"cpu_model": {
 			ID:       "cpu_model",
 			Label:    "CPU Model",
+			Truncate: truncateCPUModel,
+			Datatype: "",
+			Priority: priorityHardware,
+			From:     "latest",
+		},
+		"cpu_microarch": {
+			ID:       "cpu_microarch",
+			Label:    "CPU Microarchitecture",
 			Truncate: 0,
 			Datatype: "",
-			Priority: 13.5,
+			Priority: priorityHardware + 0.1,
 			From:     "latest",
 		},
 		"processor_count": {
@@ -218,7 +1112,7 @@ func getMetadataTemplate() map[string]metadataTemplate {
 			Label:    "Processor Count",
 			Truncate: 0,
 			Datatype: "integer",
-			Priority: 13.5,
+			Priority: priorityHardware,
 			From:     "latest",
 		},
 		"platform_memory": {
@@ -226,75 +1120,208 @@ func getMetadataTemplate() map[string]metadataTemplate {
 			Label:    "Platform Memory",
 			Truncate: 0,
 			Datatype: "filesize",
-			Priority: 13.5,
+			Priority: priorityHardware + 0.2,
 			From:     "latest",
 		},
 	}
 }
 
+// metricInfo describes a single metric key for --list-metrics.
+type metricInfo struct {
+	ID       string  `json:"id"`
+	Label    string  `json:"label"`
+	Datatype string  `json:"datatype"`
+	Priority float64 `json:"priority"`
+}
+
+// listMetrics runs one collection with cfg and writes every metadata and
+// table template registered by the collectors to w as a JSON array, ordered
+// by priority and then ID.
+func listMetrics(w io.Writer, cfg Config) error {
+	p := NewPlugin("", cfg)
+	c, err := p.metrics(context.Background())
+	if err != nil {
+		return err
+	}
+
+	var infos []metricInfo
+	for _, t := range c.templates.MetadataTemplates() {
+		infos = append(infos, metricInfo{ID: t.ID, Label: t.Label, Datatype: t.Datatype, Priority: t.Priority})
+	}
+	for _, t := range c.templates.TableTemplates() {
+		infos = append(infos, metricInfo{ID: t.ID, Label: t.Label, Datatype: "table"})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Priority != infos[j].Priority {
+			return infos[i].Priority < infos[j].Priority
+		}
+		return infos[i].ID < infos[j].ID
+	})
+
+	raw, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(raw))
+	return err
+}
+
+// cpuinfoTablePrefix returns the row key prefix of the CPU info table. It is
+// configurable so that several instances of the plugin don't share a table.
+func cpuinfoTablePrefix() string {
+	return envString("CPUINFO_TABLE_PREFIX", defaultCPUInfoTablePrefix)
+}
+
+func validateTablePrefix(prefix string) error {
+	if !strings.HasSuffix(prefix, "-") {
+		return fmt.Errorf("table prefix %q must end with \"-\"", prefix)
+	}
+	return nil
+}
+
 func getTableTemplate() map[string]tableTemplate {
+	prefix := cpuinfoTablePrefix()
+	id := strings.TrimSuffix(prefix, "-")
 	return map[string]tableTemplate{
-		"cpuinfo-table": {
-			ID:     "cpuinfo-table",
-			Label:  "Host CPU and RAM Info",
-			Prefix: cpuinfoTablePrefix,
+		id: {
+			ID:     id,
+			Label:  envString("CPUINFO_TABLE_LABEL", "Host CPU and RAM Info"),
+			Prefix: prefix,
 		},
 	}
 }
 
+// ServeHTTP routes the plugin's endpoints, so that the plugin can be served
+// without the global mux.
+func (p *Plugin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/report":
+		p.Report(w, r)
+	case "/control":
+		if p.config().NoController {
+			http.NotFound(w, r)
+			return
+		}
+		p.Control(w, r)
+	case "/healthz":
+		p.watchdog.healthz(w, r)
+	case "/config":
+		p.Config(w, r)
+	case "/metrics":
+		p.refreshCounts.ServeHTTP(w, r)
+	case "/selftest":
+		p.SelfTest(w, r)
+	case "/debug/cpuinfo":
+		p.debugProcFile("cpuinfo")(w, r)
+	case "/debug/meminfo":
+		p.debugProcFile("meminfo")(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
 // Report is called by scope when a new report is needed. It is part of the
 // "reporter" interface, which all plugins must implement.
 func (p *Plugin) Report(w http.ResponseWriter, r *http.Request) {
-	p.lock.Lock()
-	defer p.lock.Unlock()
+	if p.reportLimiter != nil && !p.reportLimiter.Allow() {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "report rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
 
-	rpt, err := p.makeReport()
+	rpt, err := p.makeReport(r.Context())
 	if err != nil {
-		log.Printf("error: %v", err)
+		errorf("%v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	raw, err := json.Marshal(*rpt)
+	raw, err := marshalResponse(*rpt, p.config().Pretty)
 	if err != nil {
-		log.Printf("error: %v", err)
+		errorf("%v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(raw)
 }
 
-func (p *Plugin) getTopologyHost() string {
-	return fmt.Sprintf("%s;<host>", p.HostID)
+// marshalResponse encodes v as compact JSON, or indented when pretty is set.
+func marshalResponse(v interface{}, pretty bool) ([]byte, error) {
+	if pretty {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
+
+// getTopologyHost returns the host node ID. Scope merges nodes by ID, so this
+// has to match the ID its probe gives the host. A non-empty suffix is appended to
+// the host ID to keep nodes with the same short hostname apart, e.g.
+// "ubuntu.us-east-1a;<host>".
+func (p *Plugin) getTopologyHost(suffix string) string {
+	if suffix == "" {
+		return fmt.Sprintf("%s;<host>", p.HostID)
+	}
+	return fmt.Sprintf("%s.%s;<host>", p.HostID, suffix)
 }
 
-func getMemStats() (MemStats, error) {
-	memory, err := mem.VirtualMemory()
+func getMemStats(ctx context.Context, reg *TemplateRegistry) (MemStats, error) {
+	memory, err := mem.VirtualMemoryWithContext(ctx)
 	if err != nil {
 		log.Printf("err=%s", err.Error())
-		return MemStats{}, err
+		return MemStats{}, &MetricError{Subsystem: "mem", Err: err}
 	}
 
-	var gb int
-	gb = int(memory.Total / 1024 / 1024 / 1024)
-	if !isPowerOfTwo(uint64(gb)) {
-		gb = int(gb + 1)
+	if memory.Total == 0 {
+		return MemStats{}, &MetricError{Subsystem: "mem", Err: ErrNoMemInfo}
 	}
-
-	memStats := MemStats{MemTotalGB: gb}
+	memStats := MemStats{MemTotalBytes: memory.Total, UsedPercent: memory.UsedPercent}
+	reg.RegisterMetadata(pickMetadata(getMetadataTemplate(), "platform_memory"))
 	return memStats, nil
 }
 
-func getCPUStats() (CPUStats, error) {
-	cpus, err := cpu.Info()
+// joinCPUModels returns the distinct model names of cpus, in order, joined
+// with " / " for hosts with mixed CPU packages.
+func joinCPUModels(cpus []cpu.InfoStat) string {
+	var models []string
+	seen := map[string]bool{}
+	for _, c := range cpus {
+		if c.ModelName == "" || seen[c.ModelName] {
+			continue
+		}
+		seen[c.ModelName] = true
+		models = append(models, c.ModelName)
+	}
+	return strings.Join(models, " / ")
+}
+
+func getCPUStats(ctx context.Context, reg *TemplateRegistry) (CPUStats, error) {
+	cpus, err := cpu.InfoWithContext(ctx)
 	if err != nil {
 		log.Printf("err=%s", err.Error())
-		return CPUStats{}, err
+		return CPUStats{}, &MetricError{Subsystem: "cpu", Err: err}
+	}
+	if len(cpus) == 0 {
+		return CPUStats{}, &MetricError{Subsystem: "cpu", Err: ErrNoCPUInfo}
+	}
+	stats := CPUStats{
+		CPUModel:       joinCPUModels(cpus),
+		ProcessorCount: len(cpus),
+		CPUFlags:       cpus[0].Flags,
+		Mhz:            cpus[0].Mhz,
+		Microarch:      lookupMicroarch(cpus[0].VendorID, cpus[0].Family, cpus[0].Model),
+	}
+	if isARM() {
+		if model := getARMCPUModel(); model != "" {
+			stats.CPUModel = model
+		}
+	}
+	reg.RegisterMetadata(pickMetadata(getMetadataTemplate(), "cpu_model", "processor_count"))
+	if stats.Microarch != "" {
+		reg.RegisterMetadata(pickMetadata(getMetadataTemplate(), "cpu_microarch"))
+	}
+	if stats.Mhz > 0 {
+		reg.RegisterMetadata(getCPUFreqMetadataTemplate("cpu_mhz"))
 	}
-	stats := CPUStats{CPUModel: cpus[0].ModelName, ProcessorCount: len(cpus)}
 	return stats, nil
 }
-
-func isPowerOfTwo(x uint64) bool {
-	return (x & (x - 1)) == 0
-}
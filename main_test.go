@@ -0,0 +1,764 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenCollection returns a fixed host collection taken at t, as the
+// refresher would have published it.
+func goldenCollection(p *Plugin, t time.Time) *collection {
+	reg := NewTemplateRegistry()
+	reg.RegisterMetadata(getCPUUsageMetadataTemplate())
+	reg.RegisterMetadata(getDefaultRouteMetadataTemplate())
+	reg.RegisterSets(getCPUFlagsSetTemplate(0))
+	reg.RegisterSets(getInterfacesSetTemplate())
+	usage := CPUUsageStats{UserPercent: 12.5, SystemPercent: 2.5, IdlePercent: 85}
+	sample := hostSample{Time: t, CPUUsage: &usage}
+
+	n := node{Latest: map[string]stringEntry{}, Sets: cpuFlagsSets([]string{"fpu", "sse2", "avx2"})}
+	n.Sets["network_interfaces"] = []string{"eth0", "wlan0"}
+	for k, v := range cpuUsageLatest(usage, t) {
+		n.Latest[k] = v
+	}
+	for k, v := range defaultRouteLatest(DefaultRouteStats{IPv4: true}, t) {
+		n.Latest[k] = v
+	}
+	n.Metrics = p.hostMetrics(sample)
+	return &collection{
+		node:      n,
+		templates: reg,
+		sample:    sample,
+		processes: []ProcessStats{{PID: 42, PPID: 1, Name: "scope", CPUPercent: 3.25, RSSBytes: 64 << 20}},
+	}
+}
+
+// cancellingCollector cancels the collection's ctx from within Collect.
+type cancellingCollector struct {
+	cancel context.CancelFunc
+}
+
+func (c *cancellingCollector) Name() string { return "cancelling" }
+
+func (c *cancellingCollector) Collect(ctx context.Context) (map[string]stringEntry, []metadataTemplate, error) {
+	c.cancel()
+	<-ctx.Done()
+	return nil, nil, nil
+}
+
+func TestMakeReportCancelled(t *testing.T) {
+	tests := []struct {
+		name string
+		// cancel arranges for ctx to be cancelled, before or while
+		// makeReport collects.
+		cancel func(p *Plugin, cancel context.CancelFunc)
+	}{
+		{
+			name:   "before the call",
+			cancel: func(p *Plugin, cancel context.CancelFunc) { cancel() },
+		},
+		{
+			name: "during the collection",
+			cancel: func(p *Plugin, cancel context.CancelFunc) {
+				p.RegisterCollector(&cancellingCollector{cancel: cancel})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.CollectMode = collectSync
+			p := NewPlugin("host", cfg)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			tt.cancel(p, cancel)
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := p.makeReport(ctx)
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("makeReport = %v, want %v", err, context.Canceled)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("makeReport hung after ctx was cancelled")
+			}
+			if p.last != nil {
+				t.Error("the cancelled collection was cached")
+			}
+		})
+	}
+}
+
+func TestMakeReportGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		golden string
+		config func(cfg *Config)
+	}{
+		{
+			name:   "default",
+			golden: "report.golden.json",
+			config: func(cfg *Config) {},
+		},
+		{
+			name:   "merge by host id without controls",
+			golden: "report_host_id.golden.json",
+			config: func(cfg *Config) {
+				cfg.MergeBy = "host_id"
+				cfg.NoController = true
+			},
+		},
+		{
+			name:   "no merge hint",
+			golden: "report_no_merge.golden.json",
+			config: func(cfg *Config) { cfg.MergeBy = "none" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.CollectMode = collectBackground
+			tt.config(&cfg)
+			p := NewPlugin("host", cfg)
+			now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+			p.now = func() time.Time { return now }
+			p.last = goldenCollection(p, now)
+
+			rpt, err := p.makeReport(context.Background())
+			if err != nil {
+				t.Fatalf("makeReport: %v", err)
+			}
+			got, err := marshalResponse(rpt, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validateReport(got); err != nil {
+				t.Errorf("invalid report: %v", err)
+			}
+
+			path := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := ioutil.WriteFile(path, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("report differs from %s, rerun with -update to see the diff:\n%s", path, got)
+			}
+		})
+	}
+}
+
+func TestReportTopologies(t *testing.T) {
+	tests := []struct {
+		name      string
+		processes []ProcessStats
+		disks     []DiskStats
+		extra     func(*report)
+		want      []string
+	}{
+		{name: "host only", want: []string{"Host"}},
+		{
+			name:      "processes",
+			processes: []ProcessStats{{PID: 42, PPID: 1, Name: "scope"}},
+			want:      []string{"Host", "Process"},
+		},
+		{
+			name:  "disks",
+			disks: []DiskStats{{Name: "sda", SizeGB: 512, Type: "SSD"}},
+			want:  []string{"Disk", "Host"},
+		},
+		{
+			name: "containers, pods and services",
+			extra: func(rpt *report) {
+				rpt.Container = &topology{Nodes: map[string]node{"c1;<container>": {}}}
+				rpt.Pod = &topology{Nodes: map[string]node{"p1;<pod>": {}}}
+				rpt.Service = &topology{Nodes: map[string]node{"s1;<service>": {}}}
+			},
+			want: []string{"Container", "Host", "Pod", "Service"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.CollectMode = collectBackground
+			p := NewPlugin("host", cfg)
+			c := goldenCollection(p, time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC))
+			c.processes, c.disks = tt.processes, tt.disks
+			p.last = c
+
+			rpt, err := p.makeReport(context.Background())
+			if err != nil {
+				t.Fatalf("makeReport: %v", err)
+			}
+			if tt.extra != nil {
+				tt.extra(rpt)
+			}
+			raw, err := json.Marshal(rpt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validateReport(raw); err != nil {
+				t.Errorf("invalid report: %v", err)
+			}
+
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, name := range []string{"Container", "Disk", "Host", "Pod", "Process", "Service"} {
+				if _, ok := fields[name]; ok {
+					got = append(got, name)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("topologies = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInjectedClock(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, mode := range []string{collectBackground, collectSync} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.CollectMode = mode
+			p := NewPlugin("host", cfg)
+			p.now = func() time.Time { return now }
+			if mode == collectBackground {
+				if err := p.collect(context.Background()); err != nil {
+					t.Fatalf("collect: %v", err)
+				}
+			}
+
+			rpt, err := p.makeReport(context.Background())
+			if err != nil {
+				t.Fatalf("makeReport: %v", err)
+			}
+			topologies := map[string]*topology{"Host": &rpt.Host, "Disk": rpt.Disk, "Process": rpt.Process}
+			for name, top := range topologies {
+				if top == nil {
+					continue
+				}
+				for id, n := range top.Nodes {
+					for k, e := range n.Latest {
+						if !e.Timestamp.Equal(now) {
+							t.Errorf("%s %s %s timestamp = %s, want %s", name, id, k, e.Timestamp, now)
+						}
+					}
+					for k, e := range n.LatestControls {
+						if !e.Timestamp.Equal(now) {
+							t.Errorf("%s %s control %s timestamp = %s, want %s", name, id, k, e.Timestamp, now)
+						}
+					}
+					for k, m := range n.Metrics {
+						for _, row := range m.Samples {
+							if !row.Date.Equal(now) {
+								t.Errorf("%s %s metric %s date = %s, want %s", name, id, k, row.Date, now)
+							}
+						}
+					}
+				}
+				for id, table := range top.TableTemplates {
+					if !table.LastUpdated.Equal(now) {
+						t.Errorf("%s table %s last updated = %s, want %s", name, id, table.LastUpdated, now)
+					}
+				}
+			}
+			if len(rpt.Host.Nodes) == 0 {
+				t.Error("no host node")
+			}
+		})
+	}
+}
+
+func TestTableLastUpdated(t *testing.T) {
+	sampled := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		tables map[string]tableTemplate
+		at     time.Time
+	}{
+		{name: "no tables", at: sampled},
+		{name: "stamped with the sample time", tables: getTableTemplate(), at: sampled},
+		{name: "stamp overwritten", tables: map[string]tableTemplate{"t": {ID: "t", LastUpdated: sampled.Add(-time.Hour)}}, at: sampled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stamped := stampTables(tt.tables, tt.at)
+			if len(stamped) != len(tt.tables) {
+				t.Fatalf("stampTables returned %d tables, want %d", len(stamped), len(tt.tables))
+			}
+			for id, table := range stamped {
+				if !table.LastUpdated.Equal(tt.at) {
+					t.Errorf("%s last updated = %s, want %s", id, table.LastUpdated, tt.at)
+				}
+			}
+		})
+	}
+
+	t.Run("recent in the served report", func(t *testing.T) {
+		p := NewPlugin("host", loadConfig())
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report", nil))
+		var rpt struct {
+			Host struct {
+				TableTemplates map[string]struct {
+					LastUpdated *time.Time `json:"last_updated"`
+				} `json:"table_templates"`
+			}
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &rpt); err != nil {
+			t.Fatal(err)
+		}
+		if len(rpt.Host.TableTemplates) == 0 {
+			t.Fatal("no table templates")
+		}
+		for id, table := range rpt.Host.TableTemplates {
+			if table.LastUpdated == nil {
+				t.Errorf("%s has no last_updated", id)
+			} else if age := time.Since(*table.LastUpdated); age < 0 || age > time.Second {
+				t.Errorf("%s last updated %s ago, want within 1s", id, age)
+			}
+		}
+	})
+}
+
+func TestTCPListener(t *testing.T) {
+	cfg := loadConfig()
+	cfg.CollectMode = collectBackground
+	cfg.ListenTCP = "127.0.0.1:0"
+	cfg.SocketPath = filepath.Join(t.TempDir(), "unused", "cpuinfo.sock")
+	listener, cleanup, err := setupListener(cfg)
+	if err != nil {
+		t.Fatalf("setupListener: %v", err)
+	}
+	defer cleanup()
+	defer listener.Close()
+	if network := listener.Addr().Network(); network != "tcp" {
+		t.Fatalf("listening on %s, want tcp", network)
+	}
+	if _, err := os.Stat(filepath.Dir(cfg.SocketPath)); !os.IsNotExist(err) {
+		t.Errorf("TCP mode set up the socket directory: %v", err)
+	}
+
+	p := NewPlugin("host", cfg)
+	p.last = goldenCollection(p, time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC))
+	go http.Serve(listener, p)
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateReport(raw); err != nil {
+		t.Errorf("invalid report: %v", err)
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	tests := []struct {
+		name                       string
+		version, buildDate, commit string
+		want                       string
+	}{
+		{
+			name:      "defaults",
+			version:   "dev",
+			buildDate: "unknown",
+			commit:    "unknown",
+			want:      "cpuinfo-plugin version dev (built unknown, unknown)",
+		},
+		{
+			name:      "release",
+			version:   "1.2.0",
+			buildDate: "2022-03-01T12:00:00Z",
+			commit:    "42fcd00",
+			want:      "cpuinfo-plugin version 1.2.0 (built 2022-03-01T12:00:00Z, 42fcd00)",
+		},
+	}
+	defer func(v, d, c string) { version, buildDate, commit = v, d, c }(version, buildDate, commit)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, buildDate, commit = tt.version, tt.buildDate, tt.commit
+			if got := versionString(); got != tt.want {
+				t.Errorf("versionString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostIDOverride(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name     string
+		override string
+		wantNode string
+	}{
+		{name: "override", override: "node-7", wantNode: "node-7;<host>"},
+		{name: "override with spaces", override: "  node-7 ", wantNode: "node-7;<host>"},
+		{name: "hostname", override: "", wantNode: hostname + ";<host>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hostID, err := resolveHostID(tt.override)
+			if err != nil {
+				t.Fatalf("resolveHostID: %v", err)
+			}
+			cfg := loadConfig()
+			cfg.CollectMode = collectBackground
+			p := NewPlugin(hostID, cfg)
+			p.last = goldenCollection(p, time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC))
+
+			rpt, err := p.makeReport(context.Background())
+			if err != nil {
+				t.Fatalf("makeReport: %v", err)
+			}
+			if _, ok := rpt.Host.Nodes[tt.wantNode]; !ok || len(rpt.Host.Nodes) != 1 {
+				t.Errorf("host nodes = %v, want only %q", rpt.Host.Nodes, tt.wantNode)
+			}
+		})
+	}
+}
+
+func TestPluginSpecEnv(t *testing.T) {
+	tests := []struct {
+		name            string
+		label, desc     string
+		wantLabel       string
+		wantDescription string
+	}{
+		{name: "defaults", wantLabel: "cpuinfo", wantDescription: "Adds a graph of CPU and memory info to hosts (" + version + ")"},
+		{name: "overridden", label: "cpu (staging)", desc: "CPU info for staging", wantLabel: "cpu (staging)", wantDescription: "CPU info for staging"},
+		{name: "label only", label: "cpu", wantLabel: "cpu", wantDescription: "Adds a graph of CPU and memory info to hosts (" + version + ")"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CPUINFO_PLUGIN_LABEL", tt.label)
+			t.Setenv("CPUINFO_PLUGIN_DESCRIPTION", tt.desc)
+			cfg := loadConfig()
+			cfg.CollectMode = collectBackground
+			p := NewPlugin("host", cfg)
+			p.last = goldenCollection(p, time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC))
+
+			rpt, err := p.makeReport(context.Background())
+			if err != nil {
+				t.Fatalf("makeReport: %v", err)
+			}
+			if len(rpt.Plugins) != 1 {
+				t.Fatalf("plugins = %v, want one", rpt.Plugins)
+			}
+			spec := rpt.Plugins[0]
+			if spec.ID != "cpuinfo" {
+				t.Errorf("ID = %q, want cpuinfo", spec.ID)
+			}
+			if spec.Label != tt.wantLabel {
+				t.Errorf("Label = %q, want %q", spec.Label, tt.wantLabel)
+			}
+			if spec.Description != tt.wantDescription {
+				t.Errorf("Description = %q, want %q", spec.Description, tt.wantDescription)
+			}
+		})
+	}
+}
+
+func TestGetTopologyHost(t *testing.T) {
+	tests := []struct {
+		name   string
+		hostID string
+		suffix string
+		want   string
+	}{
+		{name: "no suffix", hostID: "ubuntu", suffix: "", want: "ubuntu;<host>"},
+		{name: "zone suffix", hostID: "ubuntu", suffix: "us-east-1a", want: "ubuntu.us-east-1a;<host>"},
+		{name: "machine id", hostID: "ubuntu", suffix: "4c4c4544", want: "ubuntu.4c4c4544;<host>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPlugin(tt.hostID, loadConfig())
+			if got := p.getTopologyHost(tt.suffix); got != tt.want {
+				t.Errorf("getTopologyHost(%q) = %q, want %q", tt.suffix, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinCPUModels(t *testing.T) {
+	tests := []struct {
+		name string
+		cpus []cpu.InfoStat
+		want string
+	}{
+		{name: "no cpus", want: ""},
+		{
+			name: "one package",
+			cpus: []cpu.InfoStat{{ModelName: "Intel(R) Xeon(R) Gold 6248"}, {ModelName: "Intel(R) Xeon(R) Gold 6248"}},
+			want: "Intel(R) Xeon(R) Gold 6248",
+		},
+		{
+			name: "mixed packages",
+			cpus: []cpu.InfoStat{
+				{ModelName: "Intel(R) Xeon(R) Gold 6248"},
+				{ModelName: "Intel(R) Xeon(R) Gold 6230"},
+				{ModelName: "Intel(R) Xeon(R) Gold 6248"},
+			},
+			want: "Intel(R) Xeon(R) Gold 6248 / Intel(R) Xeon(R) Gold 6230",
+		},
+		{
+			name: "unnamed entries",
+			cpus: []cpu.InfoStat{{}, {ModelName: "AMD EPYC 7763"}},
+			want: "AMD EPYC 7763",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := joinCPUModels(tt.cpus); got != tt.want {
+				t.Errorf("joinCPUModels = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCPUModelTruncate(t *testing.T) {
+	tests := []struct {
+		env  string
+		want int
+	}{
+		{env: "", want: 40},
+		{env: "24", want: 24},
+		{env: "0", want: 0},
+		{env: "-5", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run("env="+tt.env, func(t *testing.T) {
+			t.Setenv("CPUINFO_TRUNCATE_CPU_MODEL", tt.env)
+			if got := getMetadataTemplate()["cpu_model"].Truncate; got != tt.want {
+				t.Errorf("cpu_model truncate = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONContentType(t *testing.T) {
+	cfg := loadConfig()
+	cfg.CollectMode = collectBackground
+	cfg.DebugEndpoints = true
+	p := NewPlugin("host", cfg)
+	p.last = goldenCollection(p, time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC))
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	controlBody := `{"NodeID": "host;<host>", "Control": "` + refreshControl + `"}`
+	tests := []struct {
+		method, path, body string
+	}{
+		{method: http.MethodGet, path: "/report"},
+		{method: http.MethodPost, path: "/control", body: controlBody},
+		{method: http.MethodGet, path: "/config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d", resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+		})
+	}
+}
+
+func TestPrettyJSON(t *testing.T) {
+	controlBody := `{"NodeID": "host;<host>", "Control": "` + refreshControl + `"}`
+	endpoints := []struct {
+		method, path, body string
+	}{
+		{method: http.MethodGet, path: "/report"},
+		{method: http.MethodPost, path: "/control", body: controlBody},
+		{method: http.MethodGet, path: "/config"},
+	}
+	tests := []struct {
+		name   string
+		env    string
+		pretty bool
+	}{
+		{name: "compact"},
+		{name: "pretty", pretty: true},
+		{name: "pretty from env", env: "true", pretty: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CPUINFO_PRETTY", tt.env)
+			cfg := loadConfig()
+			cfg.CollectMode = collectBackground
+			cfg.DebugEndpoints = true
+			if tt.env == "" {
+				cfg.Pretty = tt.pretty
+			}
+			p := NewPlugin("host", cfg)
+			p.last = goldenCollection(p, time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC))
+			srv := httptest.NewServer(p)
+			defer srv.Close()
+
+			for _, ep := range endpoints {
+				req, err := http.NewRequest(ep.method, srv.URL+ep.path, strings.NewReader(ep.body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := srv.Client().Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, err := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != http.StatusOK {
+					t.Fatalf("%s: status = %d", ep.path, resp.StatusCode)
+				}
+				if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("%s: Content-Type = %q, want application/json", ep.path, ct)
+				}
+				if !json.Valid(body) {
+					t.Fatalf("%s: invalid JSON: %s", ep.path, body)
+				}
+
+				var want bytes.Buffer
+				if tt.pretty {
+					err = json.Indent(&want, body, "", "  ")
+				} else {
+					err = json.Compact(&want, body)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(body, want.Bytes()) {
+					t.Errorf("%s: body isn't formatted with pretty %v:\n%s", ep.path, tt.pretty, body)
+				}
+				if indented := bytes.Contains(body, []byte("\n  \"")); indented != tt.pretty {
+					t.Errorf("%s: indented = %v, want %v", ep.path, indented, tt.pretty)
+				}
+			}
+		})
+	}
+}
+
+func TestPluginHandler(t *testing.T) {
+	cfg := loadConfig()
+	cfg.CollectMode = collectBackground
+	p := NewPlugin("host", cfg)
+	p.last = goldenCollection(p, time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name, method, path, body string
+		wantCode                 int
+		wantBody                 string
+	}{
+		{name: "report", method: http.MethodGet, path: "/report", wantCode: http.StatusOK, wantBody: `"Plugins"`},
+		{name: "refresh control", method: http.MethodPost, path: "/control",
+			body:     `{"NodeID": "host;<host>", "Control": "` + refreshControl + `"}`,
+			wantCode: http.StatusOK, wantBody: `"shortcutReport"`},
+		{name: "unknown control", method: http.MethodPost, path: "/control",
+			body:     `{"NodeID": "host;<host>", "Control": "reboot"}`,
+			wantCode: http.StatusBadRequest, wantBody: `unknown control "reboot"`},
+		{name: "healthz", method: http.MethodGet, path: "/healthz", wantCode: http.StatusOK, wantBody: `"status":"ok"`},
+		{name: "unknown route", method: http.MethodGet, path: "/nope", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestGetTableTemplate(t *testing.T) {
+	tests := []struct {
+		name, label, prefix string
+		want                tableTemplate
+		wantErr             bool
+	}{
+		{
+			name: "defaults",
+			want: tableTemplate{ID: "cpuinfo-table", Label: "Host CPU and RAM Info", Prefix: "cpuinfo-table-"},
+		},
+		{
+			name:   "configured",
+			label:  "Rack 7 CPU",
+			prefix: "rack7-cpuinfo-",
+			want:   tableTemplate{ID: "rack7-cpuinfo", Label: "Rack 7 CPU", Prefix: "rack7-cpuinfo-"},
+		},
+		{
+			name:    "prefix without dash",
+			prefix:  "rack7",
+			want:    tableTemplate{ID: "rack7", Label: "Host CPU and RAM Info", Prefix: "rack7"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CPUINFO_TABLE_LABEL", tt.label)
+			t.Setenv("CPUINFO_TABLE_PREFIX", tt.prefix)
+
+			tables := getTableTemplate()
+			if got := tables[tt.want.ID]; len(tables) != 1 || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getTableTemplate = %+v, want %+v", tables, tt.want)
+			}
+			if err := validateTablePrefix(cpuinfoTablePrefix()); (err != nil) != tt.wantErr {
+				t.Errorf("validateTablePrefix error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
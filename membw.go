@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// memBandwidthWindow is how long perf counts memory traffic per collection.
+const memBandwidthWindow = 500 * time.Millisecond
+
+// defaultPCMSharedMemoryPath is where Intel PCM's daemon publishes its
+// counters.
+const defaultPCMSharedMemoryPath = "/tmp/opcm.cpumon"
+
+var errNoMemBandwidthSource = errors.New("no memory bandwidth source")
+
+// MemBandwidthStats holds the memory controller throughput in GB/s.
+type MemBandwidthStats struct {
+	ReadGBps  float64
+	WriteGBps float64
+}
+
+// memBandwidthReader is a source of memory bandwidth samples.
+type memBandwidthReader interface {
+	ReadMemBandwidth(ctx context.Context) (MemBandwidthStats, error)
+}
+
+// defaultMemBandwidthReader reads PCM's shared memory when the PCM daemon
+// runs, and perf otherwise.
+func defaultMemBandwidthReader() memBandwidthReader {
+	return pcmMemBandwidthReader{
+		pcm:      pcmSharedMemoryFile(defaultPCMSharedMemoryPath),
+		fallback: perfMemBandwidthReader{command: perfMemBandwidthCommand},
+	}
+}
+
+// pcmMemory is PCM's shared memory, or a mock of it.
+type pcmMemory interface {
+	// MemoryMBps returns the system-wide memory read and write throughput
+	// in MB/s over PCM's last sampling interval. It returns an
+	// os.ErrNotExist error when PCM isn't running.
+	MemoryMBps() (read, write float64, err error)
+}
+
+// pcmMemBandwidthReader reads the memory bandwidth measured by Intel PCM,
+// falling back to another reader when PCM isn't running.
+type pcmMemBandwidthReader struct {
+	pcm      pcmMemory
+	fallback memBandwidthReader
+}
+
+func (r pcmMemBandwidthReader) ReadMemBandwidth(ctx context.Context) (MemBandwidthStats, error) {
+	read, write, err := r.pcm.MemoryMBps()
+	if errors.Is(err, errUnknownPCMLayout) {
+		debugf("ignoring PCM shared memory: %v", err)
+		return r.fallback.ReadMemBandwidth(ctx)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return r.fallback.ReadMemBandwidth(ctx)
+	}
+	if err != nil {
+		return MemBandwidthStats{}, err
+	}
+	return MemBandwidthStats{ReadGBps: read / 1000, WriteGBps: write / 1000}, nil
+}
+
+// pcmVersionSize is the length of the NUL-padded version string that starts
+// PCM's shared memory.
+const pcmVersionSize = 12
+
+// pcmLayout locates the system-wide memory throughput, a pair of
+// little-endian float32 MB/s values, in PCM's shared memory.
+type pcmLayout struct {
+	memReadOffset int
+}
+
+// pcmLayouts are the shared memory layouts that are decoded, by the version
+// string the PCM daemon writes. They are those of PCMDaemonCounters built
+// with the daemon's default limits: the version string, then the system,
+// core and memory counters.
+var pcmLayouts = map[string]pcmLayout{
+	"1.0.5": {memReadOffset: 0x2a9a20},
+}
+
+// errUnknownPCMLayout is returned for shared memory written by a PCM daemon
+// version missing from pcmLayouts, whose counters may be anywhere.
+var errUnknownPCMLayout = errors.New("unknown PCM shared memory layout")
+
+// pcmSharedMemoryFile is the path of PCM's shared memory.
+type pcmSharedMemoryFile string
+
+func (path pcmSharedMemoryFile) MemoryMBps() (read, write float64, err error) {
+	raw, err := ioutil.ReadFile(string(path))
+	if err != nil {
+		return 0, 0, err
+	}
+	return decodePCMMemory(raw)
+}
+
+// decodePCMMemory reads the system-wide memory throughput from raw PCM
+// shared memory. It refuses memory of a version without a known layout
+// with an errUnknownPCMLayout error, and rejects memory that is too short
+// for its layout or holds implausible values.
+func decodePCMMemory(raw []byte) (read, write float64, err error) {
+	if len(raw) < pcmVersionSize {
+		return 0, 0, fmt.Errorf("PCM shared memory is %d bytes, too short for a version", len(raw))
+	}
+	version := strings.TrimRight(string(raw[:pcmVersionSize]), "\x00")
+	layout, ok := pcmLayouts[version]
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: version %q", errUnknownPCMLayout, version)
+	}
+	if want := layout.memReadOffset + 8; len(raw) < want {
+		return 0, 0, fmt.Errorf("PCM %s shared memory is %d bytes, want at least %d", version, len(raw), want)
+	}
+	read = float64(math.Float32frombits(binary.LittleEndian.Uint32(raw[layout.memReadOffset:])))
+	write = float64(math.Float32frombits(binary.LittleEndian.Uint32(raw[layout.memReadOffset+4:])))
+	for _, v := range []float64{read, write} {
+		if math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+			return 0, 0, fmt.Errorf("implausible PCM memory throughput %v MB/s", v)
+		}
+	}
+	return read, write, nil
+}
+
+// perfMemBandwidthReader counts the integrated memory controller's
+// data_reads and data_writes events with perf over memBandwidthWindow. It
+// needs the uncore_imc PMU (Intel server CPUs) and perf_event access.
+type perfMemBandwidthReader struct {
+	command []string
+}
+
+var perfMemBandwidthCommand = []string{
+	"perf", "stat", "-a", "-x", ",", "--log-fd", "1",
+	"-e", "uncore_imc/data_reads/,uncore_imc/data_writes/",
+	"--", "sleep", strconv.FormatFloat(memBandwidthWindow.Seconds(), 'f', -1, 64),
+}
+
+func (r perfMemBandwidthReader) ReadMemBandwidth(ctx context.Context) (MemBandwidthStats, error) {
+	path, err := exec.LookPath(r.command[0])
+	if err != nil {
+		return MemBandwidthStats{}, errNoMemBandwidthSource
+	}
+	out, err := runCommand(ctx, memBandwidthWindow+2*time.Second, path, r.command[1:]...)
+	if err != nil {
+		return MemBandwidthStats{}, err
+	}
+	return parsePerfMemBandwidth(string(out), memBandwidthWindow)
+}
+
+// parsePerfMemBandwidth parses perf stat CSV output, "<value>,<unit>,<event>,...",
+// into GB/s over window. perf scales the IMC events to MiB; unscaled values
+// count 64-byte cache lines.
+func parsePerfMemBandwidth(out string, window time.Duration) (MemBandwidthStats, error) {
+	var readBytes, writeBytes float64
+	var found bool
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) < 3 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			// "<not supported>" or "<not counted>".
+			continue
+		}
+		bytes := v * 64
+		if fields[1] == "MiB" {
+			bytes = v * 1024 * 1024
+		}
+		switch {
+		case strings.Contains(fields[2], "data_reads"):
+			readBytes += bytes
+			found = true
+		case strings.Contains(fields[2], "data_writes"):
+			writeBytes += bytes
+			found = true
+		}
+	}
+	if !found {
+		return MemBandwidthStats{}, fmt.Errorf("no memory controller counters in perf output")
+	}
+	seconds := window.Seconds()
+	return MemBandwidthStats{
+		ReadGBps:  readBytes / seconds / 1e9,
+		WriteGBps: writeBytes / seconds / 1e9,
+	}, nil
+}
+
+// getMemBandwidthStats reads a sample from reader. ok is false when reader
+// has no source on this host.
+func getMemBandwidthStats(ctx context.Context, reader memBandwidthReader, reg *TemplateRegistry) (stats MemBandwidthStats, ok bool, err error) {
+	stats, err = reader.ReadMemBandwidth(ctx)
+	if err == errNoMemBandwidthSource {
+		return MemBandwidthStats{}, false, nil
+	}
+	if err != nil {
+		return MemBandwidthStats{}, false, &MetricError{Subsystem: "mem_bandwidth", Err: err}
+	}
+	reg.RegisterMetadata(getMemBandwidthMetadataTemplate())
+	return stats, true, nil
+}
+
+func memBandwidthLatest(stats MemBandwidthStats, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"mem_read_gbps":  {Timestamp: t, Value: formatNumber(stats.ReadGBps, 2)},
+		"mem_write_gbps": {Timestamp: t, Value: formatNumber(stats.WriteGBps, 2)},
+	}
+}
+
+func getMemBandwidthMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"mem_read_gbps": {
+			ID:       "mem_read_gbps",
+			Label:    "Memory Read (GB/s)",
+			Datatype: "number",
+			Priority: priorityUtilization + 0.4,
+			From:     "latest",
+		},
+		"mem_write_gbps": {
+			ID:       "mem_write_gbps",
+			Label:    "Memory Write (GB/s)",
+			Datatype: "number",
+			Priority: priorityUtilization + 0.4,
+			From:     "latest",
+		},
+	}
+}
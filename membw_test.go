@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// mockPCM is a pcmMemory returning fixed throughput.
+type mockPCM struct {
+	read, write float64
+	err         error
+}
+
+func (m mockPCM) MemoryMBps() (float64, float64, error) { return m.read, m.write, m.err }
+
+// stubMemBandwidthReader is a memBandwidthReader returning fixed stats.
+type stubMemBandwidthReader struct {
+	stats MemBandwidthStats
+	err   error
+}
+
+func (r stubMemBandwidthReader) ReadMemBandwidth(ctx context.Context) (MemBandwidthStats, error) {
+	return r.stats, r.err
+}
+
+func TestGetMemBandwidthStats(t *testing.T) {
+	perf := stubMemBandwidthReader{stats: MemBandwidthStats{ReadGBps: 1, WriteGBps: 2}}
+	tests := []struct {
+		name    string
+		reader  memBandwidthReader
+		want    MemBandwidthStats
+		wantOK  bool
+		wantErr bool
+	}{
+		{
+			name:   "pcm",
+			reader: pcmMemBandwidthReader{pcm: mockPCM{read: 12500, write: 4250}, fallback: perf},
+			want:   MemBandwidthStats{ReadGBps: 12.5, WriteGBps: 4.25},
+			wantOK: true,
+		},
+		{
+			name:   "pcm not running falls back to perf",
+			reader: pcmMemBandwidthReader{pcm: mockPCM{err: os.ErrNotExist}, fallback: perf},
+			want:   perf.stats,
+			wantOK: true,
+		},
+		{
+			name:   "unknown pcm layout falls back to perf",
+			reader: pcmMemBandwidthReader{pcm: mockPCM{err: fmt.Errorf("%w: version %q", errUnknownPCMLayout, "2.0.0")}, fallback: perf},
+			want:   perf.stats,
+			wantOK: true,
+		},
+		{
+			name:    "pcm error",
+			reader:  pcmMemBandwidthReader{pcm: mockPCM{err: errors.New("bad layout")}, fallback: perf},
+			wantErr: true,
+		},
+		{
+			name:   "no source",
+			reader: pcmMemBandwidthReader{pcm: mockPCM{err: os.ErrNotExist}, fallback: stubMemBandwidthReader{err: errNoMemBandwidthSource}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewTemplateRegistry()
+			got, ok, err := getMemBandwidthStats(context.Background(), tt.reader, reg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("got %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+			if _, registered := reg.MetadataTemplates()["mem_read_gbps"]; registered != tt.wantOK {
+				t.Errorf("mem_read_gbps template registered = %v, want %v", registered, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPCMSharedMemoryFile(t *testing.T) {
+	const readOffset = 0x2a9a20
+	// shm lays out PCM shared memory like a daemon of the given version.
+	shm := func(version string, read, write float32) []byte {
+		raw := make([]byte, readOffset+8)
+		copy(raw, version)
+		binary.LittleEndian.PutUint32(raw[readOffset:], math.Float32bits(read))
+		binary.LittleEndian.PutUint32(raw[readOffset+4:], math.Float32bits(write))
+		return raw
+	}
+	tests := []struct {
+		name        string
+		raw         []byte
+		read        float64
+		write       float64
+		wantErr     bool
+		notExists   bool
+		wantUnknown bool
+	}{
+		{name: "throughput", raw: shm("1.0.5", 8192, 1024), read: 8192, write: 1024},
+		{name: "idle", raw: shm("1.0.5", 0, 0)},
+		{name: "other version", raw: shm("2.0.0", 8192, 1024), wantErr: true, wantUnknown: true},
+		{name: "version prefix only", raw: shm("1.0.50", 8192, 1024), wantErr: true, wantUnknown: true},
+		{name: "no version", raw: shm("", 8192, 1024), wantErr: true, wantUnknown: true},
+		{name: "truncated", raw: shm("1.0.5", 8192, 1024)[:readOffset], wantErr: true},
+		{name: "shorter than the version", raw: []byte("1.0"), wantErr: true},
+		{name: "negative", raw: shm("1.0.5", -1, 0), wantErr: true},
+		{name: "nan", raw: shm("1.0.5", float32(math.NaN()), 0), wantErr: true},
+		{name: "missing", wantErr: true, notExists: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "opcm.cpumon")
+			if tt.raw != nil {
+				if err := ioutil.WriteFile(path, tt.raw, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			read, write, err := pcmSharedMemoryFile(path).MemoryMBps()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, os.ErrNotExist) != tt.notExists {
+				t.Errorf("errors.Is(%v, os.ErrNotExist) != %v", err, tt.notExists)
+			}
+			if errors.Is(err, errUnknownPCMLayout) != tt.wantUnknown {
+				t.Errorf("errors.Is(%v, errUnknownPCMLayout) != %v", err, tt.wantUnknown)
+			}
+			if read != tt.read || write != tt.write {
+				t.Errorf("got %v, %v MB/s, want %v, %v", read, write, tt.read, tt.write)
+			}
+		})
+	}
+}
+
+func TestParsePerfMemBandwidth(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    MemBandwidthStats
+		wantErr bool
+	}{
+		{
+			name: "scaled to MiB",
+			out:  "476.84,MiB,uncore_imc/data_reads/,500000000,100.00,,\n238.42,MiB,uncore_imc/data_writes/,500000000,100.00,,\n",
+			want: MemBandwidthStats{ReadGBps: 1, WriteGBps: 0.5},
+		},
+		{
+			name: "cache lines",
+			out:  "7812500,,uncore_imc/data_reads/,500000000,100.00,,\n0,,uncore_imc/data_writes/,500000000,100.00,,\n",
+			want: MemBandwidthStats{ReadGBps: 1},
+		},
+		{
+			name:    "not supported",
+			out:     "<not supported>,,uncore_imc/data_reads/,0,100.00,,\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePerfMemBandwidth(tt.out, 500*time.Millisecond)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if math.Abs(got.ReadGBps-tt.want.ReadGBps) > 0.01 || math.Abs(got.WriteGBps-tt.want.WriteGBps) > 0.01 {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultProcPath = "/proc"
+
+// CommitStats compares the memory committed by all processes with the total
+// memory, a ratio above 1 means the host relies on overcommit.
+type CommitStats struct {
+	CommittedBytes uint64
+	Ratio          float64
+}
+
+// getCommitStats reads Committed_AS from <procRoot>/meminfo and divides it by
+// totalBytes.
+func getCommitStats(ctx context.Context, procRoot string, totalBytes uint64, reg *TemplateRegistry) (CommitStats, error) {
+	f, err := os.Open(filepath.Join(procRoot, "meminfo"))
+	if err != nil {
+		return CommitStats{}, &MetricError{Subsystem: "mem_commit", Err: err}
+	}
+	defer f.Close()
+
+	meminfo, err := parseMeminfo(f)
+	if err != nil {
+		return CommitStats{}, &MetricError{Subsystem: "mem_commit", Err: err}
+	}
+	committed, ok := meminfo["Committed_AS"]
+	if !ok {
+		return CommitStats{}, &MetricError{Subsystem: "mem_commit", Err: fmt.Errorf("no Committed_AS in meminfo")}
+	}
+	if totalBytes == 0 {
+		return CommitStats{}, &MetricError{Subsystem: "mem_commit", Err: ErrNoMemInfo}
+	}
+
+	reg.RegisterMetadata(getCommitMetadataTemplate())
+	return CommitStats{
+		CommittedBytes: committed,
+		Ratio:          float64(committed) / float64(totalBytes),
+	}, nil
+}
+
+// parseMeminfo parses /proc/meminfo style "Key:   1234 kB" lines into bytes.
+func parseMeminfo(r io.Reader) (map[string]uint64, error) {
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		fields := strings.Fields(kv[1])
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			v *= 1024
+		}
+		values[strings.TrimSpace(kv[0])] = v
+	}
+	return values, scanner.Err()
+}
+
+func commitLatest(stats CommitStats, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"memory_commit_ratio": {Timestamp: t, Value: formatNumber(stats.Ratio, 2)},
+	}
+}
+
+func getCommitMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"memory_commit_ratio": {
+			ID:       "memory_commit_ratio",
+			Label:    "Memory Commit Ratio",
+			Datatype: "number",
+			Priority: priorityUtilization + 0.2,
+			From:     "latest",
+		},
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleMeminfo = `MemTotal:        8000000 kB
+MemFree:          412316 kB
+MemAvailable:    3815272 kB
+Committed_AS:   12000000 kB
+VmallocTotal:   34359738367 kB
+HugePages_Total:       0
+`
+
+func TestGetCommitStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		meminfo    string
+		totalBytes uint64
+		wantRatio  float64
+		wantErr    bool
+	}{
+		{name: "overcommitted", meminfo: sampleMeminfo, totalBytes: 8000000 * 1024, wantRatio: 1.5},
+		{name: "half committed", meminfo: "Committed_AS: 2048 kB\n", totalBytes: 4096 * 1024, wantRatio: 0.5},
+		{name: "no Committed_AS", meminfo: "MemTotal: 8000000 kB\n", totalBytes: 8000000 * 1024, wantErr: true},
+		{name: "unknown total", meminfo: sampleMeminfo, totalBytes: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeTree(t, map[string]string{"meminfo": tt.meminfo})
+			got, err := getCommitStats(context.Background(), root, tt.totalBytes, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCommitStats error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Ratio != tt.wantRatio {
+				t.Errorf("ratio = %v, want %v", got.Ratio, tt.wantRatio)
+			}
+			if tt.wantErr {
+				return
+			}
+			if v := commitLatest(got, time.Time{})["memory_commit_ratio"].Value; v != formatNumber(tt.wantRatio, 2) {
+				t.Errorf("memory_commit_ratio = %q", v)
+			}
+		})
+	}
+}
+
+func TestParseMeminfoUnits(t *testing.T) {
+	got, err := parseMeminfo(strings.NewReader(sampleMeminfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["MemFree"] != 412316*1024 {
+		t.Errorf("MemFree = %d, want kB converted to bytes", got["MemFree"])
+	}
+	if got["HugePages_Total"] != 0 {
+		t.Errorf("HugePages_Total = %d, want 0", got["HugePages_Total"])
+	}
+}
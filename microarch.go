@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Vendors in microarchLookup keys.
+const (
+	vendorIntel = iota
+	vendorAMD
+)
+
+// microarchLookup maps [vendor, family, model] to the microarchitecture
+// name. Where one model number covers several generations, which the
+// stepping tells apart, all are named.
+var microarchLookup = map[[3]int]string{
+	{vendorIntel, 6, 26}:  "Nehalem",
+	{vendorIntel, 6, 44}:  "Westmere",
+	{vendorIntel, 6, 42}:  "Sandy Bridge",
+	{vendorIntel, 6, 45}:  "Sandy Bridge-EP",
+	{vendorIntel, 6, 58}:  "Ivy Bridge",
+	{vendorIntel, 6, 62}:  "Ivy Bridge-EP",
+	{vendorIntel, 6, 60}:  "Haswell",
+	{vendorIntel, 6, 63}:  "Haswell-EP",
+	{vendorIntel, 6, 69}:  "Haswell",
+	{vendorIntel, 6, 70}:  "Haswell",
+	{vendorIntel, 6, 61}:  "Broadwell",
+	{vendorIntel, 6, 71}:  "Broadwell",
+	{vendorIntel, 6, 79}:  "Broadwell-EP",
+	{vendorIntel, 6, 86}:  "Broadwell-DE",
+	{vendorIntel, 6, 78}:  "Skylake",
+	{vendorIntel, 6, 94}:  "Skylake",
+	{vendorIntel, 6, 85}:  "Skylake-SP / Cascade Lake / Cooper Lake",
+	{vendorIntel, 6, 142}: "Kaby Lake / Coffee Lake / Whiskey Lake",
+	{vendorIntel, 6, 158}: "Kaby Lake / Coffee Lake",
+	{vendorIntel, 6, 165}: "Comet Lake",
+	{vendorIntel, 6, 126}: "Ice Lake",
+	{vendorIntel, 6, 106}: "Ice Lake-SP",
+	{vendorIntel, 6, 108}: "Ice Lake-D",
+	{vendorIntel, 6, 140}: "Tiger Lake",
+	{vendorIntel, 6, 141}: "Tiger Lake",
+	{vendorIntel, 6, 167}: "Rocket Lake",
+	{vendorIntel, 6, 151}: "Alder Lake",
+	{vendorIntel, 6, 154}: "Alder Lake",
+	{vendorIntel, 6, 183}: "Raptor Lake",
+	{vendorIntel, 6, 186}: "Raptor Lake",
+	{vendorIntel, 6, 143}: "Sapphire Rapids",
+	{vendorIntel, 6, 207}: "Emerald Rapids",
+	{vendorAMD, 21, 1}:    "Bulldozer",
+	{vendorAMD, 21, 2}:    "Piledriver",
+	{vendorAMD, 23, 1}:    "Zen",
+	{vendorAMD, 23, 17}:   "Zen",
+	{vendorAMD, 23, 8}:    "Zen+",
+	{vendorAMD, 23, 24}:   "Zen+",
+	{vendorAMD, 23, 49}:   "Zen 2",
+	{vendorAMD, 23, 96}:   "Zen 2",
+	{vendorAMD, 23, 113}:  "Zen 2",
+	{vendorAMD, 25, 1}:    "Zen 3",
+	{vendorAMD, 25, 33}:   "Zen 3",
+	{vendorAMD, 25, 80}:   "Zen 3",
+	{vendorAMD, 25, 17}:   "Zen 4",
+	{vendorAMD, 25, 97}:   "Zen 4",
+	{vendorAMD, 25, 160}:  "Zen 4c",
+}
+
+// lookupMicroarch names the microarchitecture of an x86 CPU from the vendor
+// ID, family and model reported in /proc/cpuinfo. It returns "" for other
+// vendors and architectures, where the numbers mean something else.
+func lookupMicroarch(vendorID, family, model string) string {
+	var vendor int
+	switch vendorID {
+	case "GenuineIntel":
+		vendor = vendorIntel
+	case "AuthenticAMD":
+		vendor = vendorAMD
+	default:
+		return ""
+	}
+	f, err := strconv.Atoi(family)
+	if err != nil {
+		return ""
+	}
+	m, err := strconv.Atoi(model)
+	if err != nil {
+		return ""
+	}
+	if name, ok := microarchLookup[[3]int{vendor, f, m}]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown (family %d, model %d)", f, m)
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestLookupMicroarch(t *testing.T) {
+	tests := []struct {
+		name                  string
+		vendor, family, model string
+		want                  string
+	}{
+		{name: "Haswell client", vendor: "GenuineIntel", family: "6", model: "60", want: "Haswell"},
+		{name: "Skylake server", vendor: "GenuineIntel", family: "6", model: "85", want: "Skylake-SP / Cascade Lake / Cooper Lake"},
+		{name: "Sapphire Rapids", vendor: "GenuineIntel", family: "6", model: "143", want: "Sapphire Rapids"},
+		{name: "Zen 2", vendor: "AuthenticAMD", family: "23", model: "49", want: "Zen 2"},
+		{name: "Zen 3", vendor: "AuthenticAMD", family: "25", model: "33", want: "Zen 3"},
+		{name: "same numbers as an AMD model", vendor: "GenuineIntel", family: "23", model: "49", want: "Unknown (family 23, model 49)"},
+		{name: "unknown Intel model", vendor: "GenuineIntel", family: "6", model: "250", want: "Unknown (family 6, model 250)"},
+		{name: "unknown AMD family", vendor: "AuthenticAMD", family: "26", model: "2", want: "Unknown (family 26, model 2)"},
+		{name: "other vendor", vendor: "HygonGenuine", family: "24", model: "0"},
+		{name: "ARM", vendor: "ARM", family: "8", model: "1"},
+		{name: "family not a number", vendor: "GenuineIntel", family: "six", model: "60"},
+		{name: "model missing", vendor: "AuthenticAMD", family: "25", model: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lookupMicroarch(tt.vendor, tt.family, tt.model); got != tt.want {
+				t.Errorf("lookupMicroarch(%q, %q, %q) = %q, want %q", tt.vendor, tt.family, tt.model, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// statusRecorder captures the status code written through it. Handlers that
+// never call WriteHeader answer 200.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// logRequests logs method, path, status and duration of each request at
+// debug level. It only wraps the ResponseWriter, so it can sit inside or
+// outside other middleware.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		debugf("%s %s %d %s", r.Method, r.URL.Path, rec.status, time.Since(start))
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRequests(t *testing.T) {
+	cfg := loadConfig()
+	cfg.CollectMode = collectBackground
+	p := NewPlugin("host", cfg)
+	p.last = goldenCollection(p, time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC))
+	handler := logRequests(p)
+
+	tests := []struct {
+		level string
+		path  string
+		want  string
+	}{
+		{level: "debug", path: "/report", want: "debug: GET /report 200 "},
+		{level: "debug", path: "/nope", want: "debug: GET /nope 404 "},
+		{level: "info", path: "/report", want: ""},
+	}
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	defer setLogLevel("info")
+	for _, tt := range tests {
+		t.Run(tt.level+tt.path, func(t *testing.T) {
+			if err := setLogLevel(tt.level); err != nil {
+				t.Fatal(err)
+			}
+			buf.Reset()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+			got := buf.String()
+			if tt.want == "" {
+				if got != "" {
+					t.Errorf("logged %q at %s level", got, tt.level)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("logged %q, want a line containing %q", got, tt.want)
+			}
+		})
+	}
+}
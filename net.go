@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+const (
+	netTablePrefix = "cpuinfo-net-table-"
+)
+
+// NetStats holds network throughput derived from two consecutive samples.
+type NetStats struct {
+	RxBytesPerSec float64
+	TxBytesPerSec float64
+	// ErrorsPerSec sums packet errors and drops, both directions, over all
+	// interfaces.
+	ErrorsPerSec float64
+	Interfaces   map[string]NetIfaceStats
+}
+
+type NetIfaceStats struct {
+	RxBytesPerSec float64
+	TxBytesPerSec float64
+	ErrRxPerSec   float64
+	ErrTxPerSec   float64
+	DropRxPerSec  float64
+	DropTxPerSec  float64
+}
+
+// ifaceFilter decides which interfaces are reported, using glob patterns.
+type ifaceFilter struct {
+	allow []string
+	deny  []string
+}
+
+func (f ifaceFilter) match(name string) bool {
+	if len(f.allow) > 0 && !matchAny(f.allow, name) {
+		return false
+	}
+	return !matchAny(f.deny, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// netSampler keeps the previous per-interface counters so that rates can be
+// computed between samples.
+type netSampler struct {
+	filter   ifaceFilter
+	table    bool
+	prev     map[string]psnet.IOCountersStat
+	prevTime time.Time
+}
+
+func (s *netSampler) getNetStats(ctx context.Context, reg *TemplateRegistry) (NetStats, error) {
+	counters, err := psnet.IOCountersWithContext(ctx, true)
+	if err != nil {
+		log.Printf("err=%s", err.Error())
+		return NetStats{}, &MetricError{Subsystem: "net", Err: err}
+	}
+	stats := s.update(counters, time.Now())
+	reg.RegisterMetadata(getNetMetadataTemplate())
+	reg.RegisterMetadata(getNetIfaceMetadataTemplate(stats))
+	if s.table {
+		reg.RegisterTables(getNetTableTemplate())
+	}
+	return stats, nil
+}
+
+// update records a new snapshot and returns the rates since the previous one.
+// Interfaces without a previous sample (new, or first call) are left out until
+// the next update; interfaces that disappeared are dropped.
+func (s *netSampler) update(counters []psnet.IOCountersStat, now time.Time) NetStats {
+	stats := NetStats{Interfaces: map[string]NetIfaceStats{}}
+	current := make(map[string]psnet.IOCountersStat, len(counters))
+	elapsed := now.Sub(s.prevTime).Seconds()
+
+	for _, c := range counters {
+		if !s.filter.match(c.Name) {
+			continue
+		}
+		current[c.Name] = c
+
+		prev, ok := s.prev[c.Name]
+		if !ok || elapsed <= 0 || counterReset(c, prev) {
+			continue
+		}
+		iface := NetIfaceStats{
+			RxBytesPerSec: float64(c.BytesRecv-prev.BytesRecv) / elapsed,
+			TxBytesPerSec: float64(c.BytesSent-prev.BytesSent) / elapsed,
+			ErrRxPerSec:   float64(c.Errin-prev.Errin) / elapsed,
+			ErrTxPerSec:   float64(c.Errout-prev.Errout) / elapsed,
+			DropRxPerSec:  float64(c.Dropin-prev.Dropin) / elapsed,
+			DropTxPerSec:  float64(c.Dropout-prev.Dropout) / elapsed,
+		}
+		stats.Interfaces[c.Name] = iface
+		stats.RxBytesPerSec += iface.RxBytesPerSec
+		stats.TxBytesPerSec += iface.TxBytesPerSec
+		stats.ErrorsPerSec += iface.ErrRxPerSec + iface.ErrTxPerSec + iface.DropRxPerSec + iface.DropTxPerSec
+	}
+
+	s.prev = current
+	s.prevTime = now
+	return stats
+}
+
+// counterReset reports whether any counter went backwards, e.g. after the
+// interface was recreated.
+func counterReset(c, prev psnet.IOCountersStat) bool {
+	return c.BytesRecv < prev.BytesRecv || c.BytesSent < prev.BytesSent ||
+		c.Errin < prev.Errin || c.Errout < prev.Errout ||
+		c.Dropin < prev.Dropin || c.Dropout < prev.Dropout
+}
+
+// netIfaceKeys lists the per-interface Latest keys, in display order.
+var netIfaceKeys = []struct {
+	suffix string
+	label  string
+	value  func(NetIfaceStats) float64
+}{
+	{"err_rx_per_sec", "Rx errors (/s)", func(s NetIfaceStats) float64 { return s.ErrRxPerSec }},
+	{"err_tx_per_sec", "Tx errors (/s)", func(s NetIfaceStats) float64 { return s.ErrTxPerSec }},
+	{"drop_rx_per_sec", "Rx drops (/s)", func(s NetIfaceStats) float64 { return s.DropRxPerSec }},
+	{"drop_tx_per_sec", "Tx drops (/s)", func(s NetIfaceStats) float64 { return s.DropTxPerSec }},
+}
+
+func netIfaceKey(name, suffix string) string {
+	return "net_" + name + "_" + suffix
+}
+
+func netLatest(stats NetStats, withTable bool, t time.Time) map[string]stringEntry {
+	latest := map[string]stringEntry{
+		"net_rx_bytes_per_sec":     {Timestamp: t, Value: formatNumber(stats.RxBytesPerSec, 0)},
+		"net_tx_bytes_per_sec":     {Timestamp: t, Value: formatNumber(stats.TxBytesPerSec, 0)},
+		"net_total_errors_per_sec": {Timestamp: t, Value: formatNumber(stats.ErrorsPerSec, 2)},
+	}
+	for name, iface := range stats.Interfaces {
+		for _, k := range netIfaceKeys {
+			latest[netIfaceKey(name, k.suffix)] = stringEntry{Timestamp: t, Value: formatNumber(k.value(iface), 2)}
+		}
+	}
+	if !withTable {
+		return latest
+	}
+
+	names := make([]string, 0, len(stats.Interfaces))
+	for name := range stats.Interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		iface := stats.Interfaces[name]
+		latest[netTablePrefix+name+tableEntryKeySeparator+"rx_bytes_per_sec"] = stringEntry{
+			Timestamp: t,
+			Value:     formatNumber(iface.RxBytesPerSec, 0),
+		}
+		latest[netTablePrefix+name+tableEntryKeySeparator+"tx_bytes_per_sec"] = stringEntry{
+			Timestamp: t,
+			Value:     formatNumber(iface.TxBytesPerSec, 0),
+		}
+	}
+	return latest
+}
+
+func getNetMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"net_rx_bytes_per_sec": {
+			ID:       "net_rx_bytes_per_sec",
+			Label:    "Network Rx (B/s)",
+			Datatype: "number",
+			Priority: priorityUtilization + 1.5,
+			From:     "latest",
+		},
+		"net_tx_bytes_per_sec": {
+			ID:       "net_tx_bytes_per_sec",
+			Label:    "Network Tx (B/s)",
+			Datatype: "number",
+			Priority: priorityUtilization + 1.5,
+			From:     "latest",
+		},
+		"net_total_errors_per_sec": {
+			ID:       "net_total_errors_per_sec",
+			Label:    "Network errors + drops (/s)",
+			Datatype: "number",
+			Priority: priorityUtilization + 1.51,
+			From:     "latest",
+		},
+	}
+}
+
+// getNetIfaceMetadataTemplate returns the error and drop rate templates of
+// the interfaces in stats.
+func getNetIfaceMetadataTemplate(stats NetStats) map[string]metadataTemplate {
+	templates := map[string]metadataTemplate{}
+	for name := range stats.Interfaces {
+		for _, k := range netIfaceKeys {
+			id := netIfaceKey(name, k.suffix)
+			templates[id] = metadataTemplate{
+				ID:       id,
+				Label:    name + " " + k.label,
+				Datatype: "number",
+				Priority: priorityUtilization + 1.52,
+				From:     "latest",
+			}
+		}
+	}
+	return templates
+}
+
+func getNetTableTemplate() map[string]tableTemplate {
+	return map[string]tableTemplate{
+		"cpuinfo-net-table": {
+			ID:     "cpuinfo-net-table",
+			Label:  "Network Interfaces",
+			Prefix: netTablePrefix,
+			Type:   multicolumnTableType,
+			Columns: []column{
+				{ID: "rx_bytes_per_sec", Label: "Rx (B/s)", DataType: "number"},
+				{ID: "tx_bytes_per_sec", Label: "Tx (B/s)", DataType: "number"},
+			},
+		},
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+func TestIfaceFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter ifaceFilter
+		iface  string
+		want   bool
+	}{
+		{name: "default keeps eth0", filter: ifaceFilter{deny: []string{"lo", "veth*"}}, iface: "eth0", want: true},
+		{name: "default skips lo", filter: ifaceFilter{deny: []string{"lo", "veth*"}}, iface: "lo", want: false},
+		{name: "default skips veth", filter: ifaceFilter{deny: []string{"lo", "veth*"}}, iface: "veth1a2b", want: false},
+		{name: "allow list", filter: ifaceFilter{allow: []string{"en*"}}, iface: "eth0", want: false},
+		{name: "deny wins over allow", filter: ifaceFilter{allow: []string{"eth*"}, deny: []string{"eth1"}}, iface: "eth1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.match(tt.iface); got != tt.want {
+				t.Errorf("match(%q) = %v, want %v", tt.iface, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetSamplerUpdate(t *testing.T) {
+	start := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	counters := func(name string, recv, sent uint64) psnet.IOCountersStat {
+		return psnet.IOCountersStat{Name: name, BytesRecv: recv, BytesSent: sent}
+	}
+	s := &netSampler{filter: ifaceFilter{deny: []string{"lo"}}}
+	snapshots := []struct {
+		name     string
+		counters []psnet.IOCountersStat
+		want     map[string]NetIfaceStats
+	}{
+		{
+			name:     "first snapshot has no rates",
+			counters: []psnet.IOCountersStat{counters("eth0", 1000, 500), counters("lo", 0, 0)},
+			want:     map[string]NetIfaceStats{},
+		},
+		{
+			name:     "wlan0 appears",
+			counters: []psnet.IOCountersStat{counters("eth0", 3000, 1500), counters("wlan0", 100, 100), counters("lo", 9000, 9000)},
+			want:     map[string]NetIfaceStats{"eth0": {RxBytesPerSec: 200, TxBytesPerSec: 100}},
+		},
+		{
+			name:     "both interfaces",
+			counters: []psnet.IOCountersStat{counters("eth0", 4000, 2500), counters("wlan0", 600, 200)},
+			want: map[string]NetIfaceStats{
+				"eth0":  {RxBytesPerSec: 100, TxBytesPerSec: 100},
+				"wlan0": {RxBytesPerSec: 50, TxBytesPerSec: 10},
+			},
+		},
+		{
+			name:     "eth0 disappears and wlan0 resets",
+			counters: []psnet.IOCountersStat{counters("wlan0", 10, 10)},
+			want:     map[string]NetIfaceStats{},
+		},
+	}
+	for i, snap := range snapshots {
+		got := s.update(snap.counters, start.Add(time.Duration(i)*10*time.Second))
+		if len(got.Interfaces) != len(snap.want) {
+			t.Errorf("%s: got interfaces %v, want %v", snap.name, got.Interfaces, snap.want)
+			continue
+		}
+		var rx float64
+		for name, want := range snap.want {
+			if got.Interfaces[name] != want {
+				t.Errorf("%s: %s = %+v, want %+v", snap.name, name, got.Interfaces[name], want)
+			}
+			rx += want.RxBytesPerSec
+		}
+		if got.RxBytesPerSec != rx {
+			t.Errorf("%s: total rx = %v, want %v", snap.name, got.RxBytesPerSec, rx)
+		}
+	}
+}
+
+func TestNetLatestTable(t *testing.T) {
+	stats := NetStats{Interfaces: map[string]NetIfaceStats{
+		"eth0":  {RxBytesPerSec: 100, TxBytesPerSec: 50},
+		"wlan0": {RxBytesPerSec: 10, TxBytesPerSec: 5},
+	}}
+	tests := []struct {
+		withTable bool
+		key       string
+		want      string
+	}{
+		{withTable: true, key: netTablePrefix + "eth0" + tableEntryKeySeparator + "rx_bytes_per_sec", want: "100"},
+		{withTable: true, key: netTablePrefix + "wlan0" + tableEntryKeySeparator + "tx_bytes_per_sec", want: "5"},
+		{withTable: false, key: netTablePrefix + "eth0" + tableEntryKeySeparator + "rx_bytes_per_sec", want: ""},
+	}
+	for _, tt := range tests {
+		if got := netLatest(stats, tt.withTable, time.Time{})[tt.key].Value; got != tt.want {
+			t.Errorf("netLatest(withTable=%v)[%s] = %q, want %q", tt.withTable, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestNetErrorRates(t *testing.T) {
+	start := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := &netSampler{}
+	s.update([]psnet.IOCountersStat{
+		{Name: "eth0", Errin: 10, Errout: 0, Dropin: 100, Dropout: 0},
+		{Name: "eth1"},
+	}, start)
+	stats := s.update([]psnet.IOCountersStat{
+		{Name: "eth0", Errin: 30, Errout: 10, Dropin: 140, Dropout: 0},
+		{Name: "eth1", Errin: 0, Errout: 0, Dropin: 0, Dropout: 20},
+	}, start.Add(10*time.Second))
+
+	if stats.ErrorsPerSec != 9 {
+		t.Errorf("ErrorsPerSec = %v, want 9", stats.ErrorsPerSec)
+	}
+	latest := netLatest(stats, false, start)
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "net_eth0_err_rx_per_sec", want: "2.00"},
+		{key: "net_eth0_err_tx_per_sec", want: "1.00"},
+		{key: "net_eth0_drop_rx_per_sec", want: "4.00"},
+		{key: "net_eth0_drop_tx_per_sec", want: "0.00"},
+		{key: "net_eth1_drop_tx_per_sec", want: "2.00"},
+		{key: "net_total_errors_per_sec", want: "9.00"},
+	}
+	for _, tt := range tests {
+		if got := latest[tt.key].Value; got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+
+	templates := getNetIfaceMetadataTemplate(stats)
+	for key := range latest {
+		if strings.HasPrefix(key, "net_eth") {
+			if _, ok := templates[key]; !ok {
+				t.Errorf("%s has no template", key)
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultNUMANodePath = "/sys/devices/system/node"
+
+// NUMAMemStats holds the memory of one NUMA node, in bytes.
+type NUMAMemStats struct {
+	Node       int
+	TotalBytes uint64
+	FreeBytes  uint64
+	UsedBytes  uint64
+}
+
+func (s NUMAMemStats) usedPercent() float64 {
+	if s.TotalBytes == 0 {
+		return 0
+	}
+	return float64(s.UsedBytes) / float64(s.TotalBytes) * 100
+}
+
+// getNUMAMemStats reads <root>/node<n>/meminfo for every NUMA node, where
+// root is normally /sys/devices/system/node, ordered by node number. Sizes
+// are reported in MB.
+func getNUMAMemStats(ctx context.Context, root string, reg *TemplateRegistry) ([]NUMAMemStats, error) {
+	dirs, err := filepath.Glob(filepath.Join(root, "node[0-9]*"))
+	if err != nil {
+		return nil, &MetricError{Subsystem: "numa", Err: err}
+	}
+	if len(dirs) == 0 {
+		return nil, &MetricError{Subsystem: "numa", Err: os.ErrNotExist}
+	}
+
+	var nodes []NUMAMemStats
+	for _, dir := range dirs {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		stats, err := readNUMAMeminfo(filepath.Join(dir, "meminfo"))
+		if err != nil {
+			return nil, &MetricError{Subsystem: "numa", Err: err}
+		}
+		stats.Node = id
+		nodes = append(nodes, stats)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Node < nodes[j].Node })
+	reg.RegisterMetadata(getNUMAMetadataTemplate(nodes))
+	return nodes, nil
+}
+
+// readNUMAMeminfo parses a node meminfo file, whose lines look like
+// "Node 0 MemTotal:  16384 kB".
+func readNUMAMeminfo(path string) (NUMAMemStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return NUMAMemStats{}, err
+	}
+	defer f.Close()
+
+	raw, err := parseMeminfo(f)
+	if err != nil {
+		return NUMAMemStats{}, err
+	}
+	values := make(map[string]uint64, len(raw))
+	for k, v := range raw {
+		fields := strings.Fields(k)
+		values[fields[len(fields)-1]] = v
+	}
+	total, ok := values["MemTotal"]
+	if !ok {
+		return NUMAMemStats{}, fmt.Errorf("no MemTotal in %s", path)
+	}
+	stats := NUMAMemStats{TotalBytes: total, FreeBytes: values["MemFree"]}
+	if used, ok := values["MemUsed"]; ok {
+		stats.UsedBytes = used
+	} else if stats.FreeBytes <= total {
+		stats.UsedBytes = total - stats.FreeBytes
+	}
+	return stats, nil
+}
+
+func numaKey(node int, suffix string) string {
+	return fmt.Sprintf("numa_node_%d_%s", node, suffix)
+}
+
+func numaLatest(nodes []NUMAMemStats, t time.Time) map[string]stringEntry {
+	latest := map[string]stringEntry{}
+	for _, n := range nodes {
+		latest[numaKey(n.Node, "mem_total_mb")] = stringEntry{Timestamp: t, Value: formatMB(n.TotalBytes)}
+		latest[numaKey(n.Node, "mem_free_mb")] = stringEntry{Timestamp: t, Value: formatMB(n.FreeBytes)}
+		latest[numaKey(n.Node, "mem_used_pct")] = stringEntry{Timestamp: t, Value: formatPercent(n.usedPercent())}
+	}
+	return latest
+}
+
+func getNUMAMetadataTemplate(nodes []NUMAMemStats) map[string]metadataTemplate {
+	templates := map[string]metadataTemplate{}
+	for _, n := range nodes {
+		for _, t := range []metadataTemplate{
+			{ID: numaKey(n.Node, "mem_total_mb"), Label: fmt.Sprintf("NUMA Node %d Memory (MB)", n.Node), Datatype: "number"},
+			{ID: numaKey(n.Node, "mem_free_mb"), Label: fmt.Sprintf("NUMA Node %d Free Memory (MB)", n.Node), Datatype: "number"},
+			{ID: numaKey(n.Node, "mem_used_pct"), Label: fmt.Sprintf("NUMA Node %d Memory Used (%%)", n.Node), Datatype: "number"},
+		} {
+			t.Priority = priorityUtilization + 1 + float64(n.Node)/100
+			t.From = "latest"
+			templates[t.ID] = t
+		}
+	}
+	return templates
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeNUMANodes creates a sysfs node directory with a meminfo file per
+// node, keyed by node directory name.
+func writeNUMANodes(t *testing.T, meminfo map[string]string) string {
+	root := t.TempDir()
+	for dir, content := range meminfo {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, dir, "meminfo"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestGetNUMAMemStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		meminfo map[string]string
+		want    map[string]string
+		missing bool
+		wantErr bool
+	}{
+		{
+			name: "two nodes",
+			meminfo: map[string]string{
+				"node0": "Node 0 MemTotal:       16777216 kB\nNode 0 MemFree:         4194304 kB\nNode 0 MemUsed:        12582912 kB\n",
+				"node1": "Node 1 MemTotal:       16777216 kB\nNode 1 MemFree:        15728640 kB\nNode 1 MemUsed:         1048576 kB\n",
+			},
+			want: map[string]string{
+				"numa_node_0_mem_total_mb": "16384",
+				"numa_node_0_mem_free_mb":  "4096",
+				"numa_node_0_mem_used_pct": "75.0",
+				"numa_node_1_mem_total_mb": "16384",
+				"numa_node_1_mem_free_mb":  "15360",
+				"numa_node_1_mem_used_pct": "6.2",
+			},
+		},
+		{
+			name: "used derived from free",
+			meminfo: map[string]string{
+				"node0": "Node 0 MemTotal:        2097152 kB\nNode 0 MemFree:         1048576 kB\n",
+			},
+			want: map[string]string{
+				"numa_node_0_mem_total_mb": "2048",
+				"numa_node_0_mem_free_mb":  "1024",
+				"numa_node_0_mem_used_pct": "50.0",
+			},
+		},
+		{
+			name:    "no nodes",
+			meminfo: map[string]string{"possible": ""},
+			missing: true,
+			wantErr: true,
+		},
+		{
+			name:    "no MemTotal",
+			meminfo: map[string]string{"node0": "Node 0 MemFree: 1024 kB\n"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeNUMANodes(t, tt.meminfo)
+			reg := NewTemplateRegistry()
+			nodes, err := getNUMAMemStats(context.Background(), root, reg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if sysfsMissing(err) != tt.missing {
+					t.Errorf("sysfsMissing(%v) = %v, want %v", err, !tt.missing, tt.missing)
+				}
+				return
+			}
+
+			got := map[string]string{}
+			for k, v := range numaLatest(nodes, time.Time{}) {
+				got[k] = v.Value
+				if _, ok := reg.MetadataTemplates()[k]; !ok {
+					t.Errorf("no template for %s", k)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("latest = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Capability bits, from linux/capability.h, that grant access to the
+// system-wide perf counters.
+const (
+	capSysAdmin = 21
+	capPerfmon  = 38
+)
+
+// privilegedCollectors are the collectors that need root, CAP_SYS_ADMIN or
+// CAP_PERFMON, mapped to what they need it for. All other collectors are
+// unprivileged.
+var privilegedCollectors = map[string]string{
+	"mem_bandwidth": "system-wide uncore perf counters",
+}
+
+// hasPrivileges reports whether the plugin runs as root or with
+// CAP_SYS_ADMIN or CAP_PERFMON in its effective capability set, read from
+// procRoot/self/status.
+func hasPrivileges(procRoot string) bool {
+	if os.Geteuid() == 0 {
+		return true
+	}
+	caps, err := readEffectiveCaps(filepath.Join(procRoot, "self", "status"))
+	if err != nil {
+		return false
+	}
+	return caps&(1<<capSysAdmin|1<<capPerfmon) != 0
+}
+
+// readEffectiveCaps returns the CapEff mask of a /proc/<pid>/status file.
+func readEffectiveCaps(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "CapEff:") {
+			return strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, os.ErrNotExist
+}
+
+// dropPrivilegedCollectors disables the enabled privileged collectors when
+// privileged is false, and returns their names.
+func (cfg *Config) dropPrivilegedCollectors(privileged bool) []string {
+	if privileged {
+		return nil
+	}
+	var dropped []string
+	if cfg.MemBandwidth {
+		cfg.MemBandwidth = false
+		dropped = append(dropped, "mem_bandwidth")
+	}
+	return dropped
+}
+
+func privilegesLatest(privileged bool, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"plugin_privileged_metrics_available": {Timestamp: t, Value: strconv.FormatBool(privileged)},
+	}
+}
+
+func getPrivilegesMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"plugin_privileged_metrics_available": {
+			ID:       "plugin_privileged_metrics_available",
+			Label:    "Plugin Privileged Metrics Available",
+			Priority: prioritySelf,
+			From:     "latest",
+		},
+	}
+}
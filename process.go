@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// ProcessStats describes one process.
+type ProcessStats struct {
+	PID        int32
+	PPID       int32
+	Name       string
+	CPUPercent float64
+	RSSBytes   uint64
+	Status     string
+}
+
+// processSource lists the host's processes. Sample returns every process
+// with its PID, CPU percent since the previous Sample and RSS, and the status
+// if the source was asked for it; Details fills in the rest, and is only
+// called for the processes that are reported.
+type processSource interface {
+	Sample(ctx context.Context) ([]ProcessStats, error)
+	Details(ctx context.Context, s *ProcessStats)
+}
+
+// ProcessCollector enumerates the processes at most every MinInterval. It
+// reports the process count and, if TopCPU and TopMem are set, the processes
+// using the most CPU and memory on the host node, and keeps the N processes
+// using the most CPU in Top for the Process topology. With States it also
+// counts the processes by state, which needs a source sampling the status.
+type ProcessCollector struct {
+	N           int
+	TopCPU      bool
+	TopMem      bool
+	States      bool
+	MinInterval time.Duration
+	Top         []ProcessStats
+
+	source  processSource
+	lastRun time.Time
+	latest  map[string]stringEntry
+}
+
+func (c *ProcessCollector) Name() string { return "processes" }
+
+func (c *ProcessCollector) Collect(ctx context.Context) (map[string]stringEntry, []metadataTemplate, error) {
+	now := time.Now()
+	if c.latest != nil && now.Sub(c.lastRun) < c.MinInterval {
+		return c.latest, c.templates(), nil
+	}
+	procs, err := c.source.Sample(ctx)
+	if err != nil {
+		return nil, nil, &MetricError{Subsystem: "processes", Err: err}
+	}
+	c.lastRun = now
+
+	sort.Slice(procs, func(i, j int) bool {
+		if procs[i].CPUPercent != procs[j].CPUPercent {
+			return procs[i].CPUPercent > procs[j].CPUPercent
+		}
+		return procs[i].PID < procs[j].PID
+	})
+	top := procs
+	if len(top) > c.N {
+		top = top[:c.N]
+	}
+	for i := range top {
+		c.source.Details(ctx, &top[i])
+	}
+	c.Top = top
+
+	latest := map[string]stringEntry{
+		"process_count": {Value: formatNumber(float64(len(procs)), 0)},
+	}
+	if c.TopCPU && len(procs) > 0 {
+		busiest := procs[0]
+		if c.N == 0 {
+			c.source.Details(ctx, &busiest)
+		}
+		latest["top_cpu_process"] = stringEntry{Value: fmt.Sprintf("%s (%s%%)", busiest.Name, formatPercent(busiest.CPUPercent))}
+	}
+	if c.TopMem && len(procs) > 0 {
+		largest := procs[0]
+		for _, proc := range procs[1:] {
+			if proc.RSSBytes > largest.RSSBytes {
+				largest = proc
+			}
+		}
+		c.source.Details(ctx, &largest)
+		latest["top_mem_process"] = stringEntry{Value: fmt.Sprintf("%s (%s MiB)", largest.Name, formatMB(largest.RSSBytes))}
+	}
+	if c.States {
+		for k, v := range processStateLatest(procs) {
+			latest[k] = v
+		}
+	}
+	c.latest = latest
+	return latest, c.templates(), nil
+}
+
+func (c *ProcessCollector) templates() []metadataTemplate {
+	templates := []metadataTemplate{{
+		ID:       "process_count",
+		Label:    "Processes",
+		Datatype: "integer",
+		Priority: priorityUtilization + 1.9,
+		From:     "latest",
+	}}
+	if c.TopCPU {
+		templates = append(templates, metadataTemplate{
+			ID:       "top_cpu_process",
+			Label:    "Top CPU Process",
+			Priority: priorityUtilization + 1.91,
+			From:     "latest",
+		})
+	}
+	if c.TopMem {
+		templates = append(templates, metadataTemplate{
+			ID:       "top_mem_process",
+			Label:    "Top Memory Process",
+			Priority: priorityUtilization + 1.92,
+			From:     "latest",
+		})
+	}
+	if c.States {
+		templates = append(templates, processStateTemplates()...)
+	}
+	return templates
+}
+
+// processStateLatest counts procs by their sampled status. gopsutil reports
+// uninterruptible sleep, the D state, as "blocked".
+func processStateLatest(procs []ProcessStats) map[string]stringEntry {
+	counts := map[string]int{}
+	for _, proc := range procs {
+		for _, status := range strings.Split(proc.Status, ",") {
+			counts[status]++
+		}
+	}
+	return map[string]stringEntry{
+		"procs_running": {Value: formatNumber(float64(counts[process.Running]), 0)},
+		"procs_blocked": {Value: formatNumber(float64(counts[process.Blocked]), 0)},
+		"procs_zombie":  {Value: formatNumber(float64(counts[process.Zombie]), 0)},
+	}
+}
+
+func processStateTemplates() []metadataTemplate {
+	return []metadataTemplate{
+		{
+			ID:       "procs_running",
+			Label:    "Running Processes",
+			Datatype: "integer",
+			Priority: priorityUtilization + 1.93,
+			From:     "latest",
+		},
+		{
+			ID:       "procs_blocked",
+			Label:    "Blocked Processes (D)",
+			Datatype: "integer",
+			Priority: priorityUtilization + 1.94,
+			From:     "latest",
+		},
+		{
+			ID:       "procs_zombie",
+			Label:    "Zombie Processes",
+			Datatype: "integer",
+			Priority: priorityUtilization + 1.95,
+			From:     "latest",
+		},
+	}
+}
+
+// gopsutilProcessSource reads processes with gopsutil, computing CPU percent
+// from the CPU time used between two samples. withStatus makes Sample read
+// the status of every process.
+type gopsutilProcessSource struct {
+	withStatus bool
+	// prevCPU holds each process's CPU seconds at prevTime, keyed by PID
+	// and start time so that reused PIDs start over.
+	prevCPU  map[processKey]float64
+	prevTime time.Time
+}
+
+type processKey struct {
+	pid     int32
+	created int64
+}
+
+// Sample falls back to the lifetime CPU average for processes without a
+// previous sample.
+func (s *gopsutilProcessSource) Sample(ctx context.Context) ([]ProcessStats, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	elapsed := now.Sub(s.prevTime).Seconds()
+
+	cpuSeconds := make(map[processKey]float64, len(procs))
+	stats := make([]ProcessStats, 0, len(procs))
+	for _, proc := range procs {
+		// Processes may exit while being listed, skip those.
+		times, err := proc.TimesWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		created, _ := proc.CreateTimeWithContext(ctx)
+		key := processKey{pid: proc.Pid, created: created}
+		cpuSeconds[key] = times.User + times.System
+
+		ps := ProcessStats{PID: proc.Pid}
+		if prev, ok := s.prevCPU[key]; ok && elapsed > 0 {
+			ps.CPUPercent = 100 * (cpuSeconds[key] - prev) / elapsed
+		} else if lifetime, err := proc.CPUPercentWithContext(ctx); err == nil {
+			ps.CPUPercent = lifetime
+		}
+		if mem, err := proc.MemoryInfoWithContext(ctx); err == nil {
+			ps.RSSBytes = mem.RSS
+		}
+		if s.withStatus {
+			if status, err := proc.StatusWithContext(ctx); err == nil {
+				ps.Status = strings.Join(status, ",")
+			}
+		}
+		stats = append(stats, ps)
+	}
+	s.prevCPU, s.prevTime = cpuSeconds, now
+	return stats, nil
+}
+
+func (s *gopsutilProcessSource) Details(ctx context.Context, ps *ProcessStats) {
+	proc := &process.Process{Pid: ps.PID}
+	ps.Name, _ = proc.NameWithContext(ctx)
+	ps.PPID, _ = proc.PpidWithContext(ctx)
+	if status, err := proc.StatusWithContext(ctx); err == nil {
+		ps.Status = strings.Join(status, ",")
+	}
+}
+
+func (p *Plugin) getTopologyProcess(pid int32) string {
+	return fmt.Sprintf("%s;%d", p.HostID, pid)
+}
+
+// processTopology builds the Process topology, with an edge from every
+// process node to the host node. Keys and template IDs carry the key prefix.
+func (p *Plugin) processTopology(procs []ProcessStats, hostNodeID string, t time.Time) *topology {
+	prefix := p.config().KeyPrefix
+	nodes := make(map[string]node, len(procs))
+	for _, proc := range procs {
+		latest := map[string]stringEntry{
+			"pid":        {Timestamp: t, Value: formatNumber(float64(proc.PID), 0)},
+			"ppid":       {Timestamp: t, Value: formatNumber(float64(proc.PPID), 0)},
+			"name":       {Timestamp: t, Value: proc.Name},
+			"cpu_pct":    {Timestamp: t, Value: formatPercent(proc.CPUPercent)},
+			"mem_rss_mb": {Timestamp: t, Value: formatMB(proc.RSSBytes)},
+		}
+		if proc.Status != "" {
+			latest["status"] = stringEntry{Timestamp: t, Value: proc.Status}
+		}
+		nodes[p.getTopologyProcess(proc.PID)] = node{
+			Latest:    prefixLatest(latest, prefix),
+			Adjacency: []string{hostNodeID},
+		}
+	}
+	reg := NewTemplateRegistry()
+	reg.RegisterMetadata(getProcessMetadataTemplate())
+	return &topology{
+		Nodes:             nodes,
+		MetadataTemplates: reg.prefixed(prefix).MetadataTemplates(),
+	}
+}
+
+func getProcessMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"pid": {
+			ID:       "pid",
+			Label:    "PID",
+			Datatype: "integer",
+			Priority: 1,
+			From:     "latest",
+		},
+		"ppid": {
+			ID:       "ppid",
+			Label:    "Parent PID",
+			Datatype: "integer",
+			Priority: 2,
+			From:     "latest",
+		},
+		"name": {
+			ID:       "name",
+			Label:    "Name",
+			Priority: 3,
+			From:     "latest",
+		},
+		"cpu_pct": {
+			ID:       "cpu_pct",
+			Label:    "CPU (%)",
+			Datatype: "number",
+			Priority: 4,
+			From:     "latest",
+		},
+		"mem_rss_mb": {
+			ID:       "mem_rss_mb",
+			Label:    "Memory RSS (MB)",
+			Datatype: "number",
+			Priority: 5,
+			From:     "latest",
+		},
+		"status": {
+			ID:       "status",
+			Label:    "Status",
+			Priority: 6,
+			From:     "latest",
+		},
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// stubProcessSource samples a fixed process list; Details names processes
+// after their PID.
+type stubProcessSource struct {
+	procs []ProcessStats
+}
+
+func (s stubProcessSource) Sample(ctx context.Context) ([]ProcessStats, error) {
+	return append([]ProcessStats(nil), s.procs...), nil
+}
+
+func (s stubProcessSource) Details(ctx context.Context, ps *ProcessStats) {
+	if ps.Name == "" {
+		ps.Name = formatNumber(float64(ps.PID), 0)
+	}
+}
+
+func TestProcessCollectorTopN(t *testing.T) {
+	procs := []ProcessStats{
+		{PID: 1, CPUPercent: 0.5, RSSBytes: 8 << 20},
+		{PID: 2, CPUPercent: 40, RSSBytes: 256 << 20},
+		{PID: 3, CPUPercent: 12, RSSBytes: 1 << 30},
+		{PID: 4, CPUPercent: 12, RSSBytes: 64 << 20},
+	}
+	tests := []struct {
+		name     string
+		n        int
+		wantPIDs []int32
+		latest   map[string]string
+	}{
+		{
+			name:     "top 2",
+			n:        2,
+			wantPIDs: []int32{2, 3},
+			latest: map[string]string{
+				"process_count":   "4",
+				"top_cpu_process": "2 (40.0%)",
+				"top_mem_process": "3 (1024 MiB)",
+			},
+		},
+		{
+			name:     "fewer processes than N",
+			n:        10,
+			wantPIDs: []int32{2, 3, 4, 1},
+		},
+		{
+			name: "topology disabled",
+			n:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ProcessCollector{N: tt.n, TopCPU: true, TopMem: true, source: stubProcessSource{procs: procs}}
+			latest, _, err := c.Collect(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pids []int32
+			for _, p := range c.Top {
+				pids = append(pids, p.PID)
+			}
+			if !reflect.DeepEqual(pids, tt.wantPIDs) {
+				t.Errorf("top PIDs = %v, want %v", pids, tt.wantPIDs)
+			}
+			for k, want := range tt.latest {
+				if got := latest[k].Value; got != want {
+					t.Errorf("%s = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+// countingProcessSource counts the Samples of a stubProcessSource.
+type countingProcessSource struct {
+	stubProcessSource
+	samples int
+}
+
+func (s *countingProcessSource) Sample(ctx context.Context) ([]ProcessStats, error) {
+	s.samples++
+	return s.stubProcessSource.Sample(ctx)
+}
+
+func TestTopCPUProcess(t *testing.T) {
+	tests := []struct {
+		name   string
+		procs  []ProcessStats
+		n      int
+		topCPU bool
+		want   string
+	}{
+		{
+			name:   "busiest",
+			procs:  []ProcessStats{{PID: 10, Name: "sshd", CPUPercent: 0.1}, {PID: 20, Name: "java", CPUPercent: 182.26}},
+			topCPU: true,
+			want:   "java (182.3%)",
+		},
+		{
+			name:   "tie goes to the lower PID",
+			procs:  []ProcessStats{{PID: 30, Name: "b", CPUPercent: 5}, {PID: 7, Name: "a", CPUPercent: 5}},
+			n:      5,
+			topCPU: true,
+			want:   "a (5.0%)",
+		},
+		{
+			name:   "named without the process topology",
+			procs:  []ProcessStats{{PID: 42, CPUPercent: 3}},
+			topCPU: true,
+			want:   "42 (3.0%)",
+		},
+		{
+			name:  "disabled",
+			procs: []ProcessStats{{PID: 20, Name: "java", CPUPercent: 182.26}},
+		},
+		{name: "no processes", topCPU: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ProcessCollector{N: tt.n, TopCPU: tt.topCPU, source: stubProcessSource{procs: tt.procs}}
+			latest, templates, err := c.Collect(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := latest["top_cpu_process"]
+			if ok != (tt.want != "") || got.Value != tt.want {
+				t.Errorf("top_cpu_process = %q (present %v), want %q", got.Value, ok, tt.want)
+			}
+			hasTemplate := false
+			for _, tmpl := range templates {
+				hasTemplate = hasTemplate || tmpl.ID == "top_cpu_process"
+			}
+			if hasTemplate != tt.topCPU {
+				t.Errorf("top_cpu_process template = %v, want %v", hasTemplate, tt.topCPU)
+			}
+		})
+	}
+}
+
+func TestProcessCollectorMinInterval(t *testing.T) {
+	src := &countingProcessSource{stubProcessSource: stubProcessSource{procs: []ProcessStats{{PID: 1, Name: "init", CPUPercent: 1}}}}
+	c := &ProcessCollector{TopCPU: true, MinInterval: time.Hour, source: src}
+	for i := 0; i < 3; i++ {
+		latest, _, err := c.Collect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := latest["top_cpu_process"].Value; got != "init (1.0%)" {
+			t.Errorf("collection %d: top_cpu_process = %q", i, got)
+		}
+	}
+	if src.samples != 1 {
+		t.Errorf("sampled %d times within the min interval, want 1", src.samples)
+	}
+
+	c.MinInterval = 0
+	if _, _, err := c.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if src.samples != 2 {
+		t.Errorf("sampled %d times without a min interval, want 2", src.samples)
+	}
+}
+
+func TestTopMemProcess(t *testing.T) {
+	procs := []ProcessStats{
+		{PID: 100, Name: "ffmpeg", CPUPercent: 390, RSSBytes: 300 << 20},
+		{PID: 200, Name: "postgres", CPUPercent: 2, RSSBytes: 6 << 30},
+		{PID: 300, Name: "redis", CPUPercent: 1, RSSBytes: 2 << 30},
+	}
+	tests := []struct {
+		name   string
+		procs  []ProcessStats
+		topMem bool
+		topCPU bool
+		want   string
+	}{
+		{name: "largest RSS, not busiest", procs: procs, topMem: true, want: "postgres (6144 MiB)"},
+		{name: "with top CPU", procs: procs, topMem: true, topCPU: true, want: "postgres (6144 MiB)"},
+		{name: "disabled", procs: procs, topCPU: true},
+		{name: "single process", procs: []ProcessStats{{PID: 5, RSSBytes: 3 << 20}}, topMem: true, want: "5 (3 MiB)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &countingProcessSource{stubProcessSource: stubProcessSource{procs: tt.procs}}
+			c := &ProcessCollector{TopCPU: tt.topCPU, TopMem: tt.topMem, source: src}
+			latest, _, err := c.Collect(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := latest["top_mem_process"]
+			if ok != (tt.want != "") || got.Value != tt.want {
+				t.Errorf("top_mem_process = %q (present %v), want %q", got.Value, ok, tt.want)
+			}
+			if src.samples != 1 {
+				t.Errorf("enumerated the processes %d times, want 1", src.samples)
+			}
+		})
+	}
+}
+
+func TestProcessStates(t *testing.T) {
+	procs := []ProcessStats{
+		{PID: 1, Status: process.Sleep},
+		{PID: 2, Status: process.Running},
+		{PID: 3, Status: process.Running},
+		{PID: 4, Status: process.Blocked},
+		{PID: 5, Status: process.Zombie},
+		{PID: 6, Status: process.Zombie},
+		{PID: 7, Status: process.Zombie},
+		{PID: 8, Status: process.Idle},
+		{PID: 9, Status: process.Stop + "," + process.Running},
+	}
+	tests := []struct {
+		name   string
+		procs  []ProcessStats
+		states bool
+		want   map[string]string
+	}{
+		{
+			name:   "mixed states",
+			procs:  procs,
+			states: true,
+			want:   map[string]string{"procs_running": "3", "procs_blocked": "1", "procs_zombie": "3"},
+		},
+		{
+			name:   "all asleep",
+			procs:  []ProcessStats{{PID: 1, Status: process.Sleep}},
+			states: true,
+			want:   map[string]string{"procs_running": "0", "procs_blocked": "0", "procs_zombie": "0"},
+		},
+		{name: "disabled", procs: procs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ProcessCollector{States: tt.states, source: stubProcessSource{procs: tt.procs}}
+			latest, templates, err := c.Collect(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, k := range []string{"procs_running", "procs_blocked", "procs_zombie"} {
+				got, ok := latest[k]
+				if want, wantOK := tt.want[k]; ok != wantOK || got.Value != want {
+					t.Errorf("%s = %q (present %v), want %q", k, got.Value, ok, want)
+				}
+			}
+			if want := 1 + len(tt.want); len(templates) != want {
+				t.Errorf("%d templates, want %d", len(templates), want)
+			}
+		})
+	}
+}
+
+func TestProcessTopology(t *testing.T) {
+	p := NewPlugin("host", loadConfig())
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	top := p.processTopology([]ProcessStats{
+		{PID: 42, PPID: 1, Name: "scope", CPUPercent: 3.25, RSSBytes: 96 << 20, Status: "sleep"},
+		{PID: 7, PPID: 1, Name: "kthreadd", RSSBytes: 512 << 10},
+	}, "host;<host>", now)
+
+	want := map[string]map[string]string{
+		"host;42": {"pid": "42", "ppid": "1", "name": "scope", "cpu_pct": "3.2", "mem_rss_mb": "96", "status": "sleep"},
+		"host;7":  {"pid": "7", "ppid": "1", "name": "kthreadd", "cpu_pct": "0.0", "mem_rss_mb": "0"},
+	}
+	got := map[string]map[string]string{}
+	for id, n := range top.Nodes {
+		got[id] = map[string]string{}
+		for k, v := range n.Latest {
+			got[id][k] = v.Value
+			if _, ok := top.MetadataTemplates[k]; !ok {
+				t.Errorf("no template for %s", k)
+			}
+		}
+		if !reflect.DeepEqual(n.Adjacency, []string{"host;<host>"}) {
+			t.Errorf("%s adjacency = %v", id, n.Adjacency)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nodes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const defaultPowerSupplyPath = "/sys/class/power_supply"
+
+// PSUStats lists the status of the mains power supplies, e.g. "Online".
+type PSUStats struct {
+	Status []string
+}
+
+func (s PSUStats) online() int {
+	count := 0
+	for _, status := range s.Status {
+		if status == "Online" {
+			count++
+		}
+	}
+	return count
+}
+
+// getPSUStats reads the power supplies of type "Mains" under root, normally
+// /sys/class/power_supply, in name order. Batteries and UPS are left out.
+func getPSUStats(ctx context.Context, root string, reg *TemplateRegistry) (PSUStats, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return PSUStats{}, &MetricError{Subsystem: "psu", Err: err}
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	var stats PSUStats
+	for _, name := range names {
+		dir := filepath.Join(root, name)
+		if kind, err := readSysfsString(filepath.Join(dir, "type")); err != nil || kind != "Mains" {
+			continue
+		}
+		stats.Status = append(stats.Status, readPSUStatus(dir))
+	}
+	reg.RegisterMetadata(getPSUMetadataTemplate(len(stats.Status)))
+	return stats, nil
+}
+
+// readPSUStatus returns the status attribute of the supply, or derives it
+// from the online attribute, which is all most mains supplies expose.
+func readPSUStatus(dir string) string {
+	if status, err := readSysfsString(filepath.Join(dir, "status")); err == nil && status != "" {
+		return status
+	}
+	online, err := readSysfsString(filepath.Join(dir, "online"))
+	switch {
+	case err != nil:
+		return "Unknown"
+	case online == "0":
+		return "Offline"
+	default:
+		return "Online"
+	}
+}
+
+func psuLatest(stats PSUStats, t time.Time) map[string]stringEntry {
+	latest := map[string]stringEntry{
+		"psu_count":        {Timestamp: t, Value: formatNumber(float64(len(stats.Status)), 0)},
+		"psu_online_count": {Timestamp: t, Value: formatNumber(float64(stats.online()), 0)},
+	}
+	for i, status := range stats.Status {
+		latest[fmt.Sprintf("psu_%d_status", i+1)] = stringEntry{Timestamp: t, Value: status}
+	}
+	return latest
+}
+
+func getPSUMetadataTemplate(count int) map[string]metadataTemplate {
+	templates := map[string]metadataTemplate{
+		"psu_count": {
+			ID:       "psu_count",
+			Label:    "Power Supplies",
+			Datatype: "integer",
+			Priority: priorityHardware + 1.7,
+			From:     "latest",
+		},
+		"psu_online_count": {
+			ID:       "psu_online_count",
+			Label:    "Power Supplies Online",
+			Datatype: "integer",
+			Priority: priorityHardware + 1.7,
+			From:     "latest",
+		},
+	}
+	for i := 1; i <= count; i++ {
+		id := fmt.Sprintf("psu_%d_status", i)
+		templates[id] = metadataTemplate{
+			ID:       id,
+			Label:    fmt.Sprintf("Power Supply %d", i),
+			Priority: priorityHardware + 1.71,
+			From:     "latest",
+		}
+	}
+	return templates
+}
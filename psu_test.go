@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetPSUStats(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        []string
+		wantLatest  map[string]string
+		wantMissing bool
+	}{
+		{
+			name: "one online, one offline",
+			files: map[string]string{
+				"PSU1/type":   "Mains\n",
+				"PSU1/online": "1\n",
+				"PSU2/type":   "Mains\n",
+				"PSU2/online": "0\n",
+			},
+			want: []string{"Online", "Offline"},
+			wantLatest: map[string]string{
+				"psu_count": "2", "psu_online_count": "1",
+				"psu_1_status": "Online", "psu_2_status": "Offline",
+			},
+		},
+		{
+			name: "status attribute and batteries",
+			files: map[string]string{
+				"AC/type":     "Mains\n",
+				"AC/status":   "Online\n",
+				"BAT0/type":   "Battery\n",
+				"BAT0/status": "Discharging\n",
+				"ups/type":    "UPS\n",
+			},
+			want:       []string{"Online"},
+			wantLatest: map[string]string{"psu_count": "1", "psu_online_count": "1"},
+		},
+		{
+			name:       "no online attribute",
+			files:      map[string]string{"PSU1/type": "Mains\n"},
+			want:       []string{"Unknown"},
+			wantLatest: map[string]string{"psu_online_count": "0", "psu_1_status": "Unknown"},
+		},
+		{
+			name:        "no power_supply class",
+			files:       nil,
+			wantMissing: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeTree(t, tt.files)
+			if tt.files == nil {
+				root = filepath.Join(root, "power_supply")
+			}
+			reg := NewTemplateRegistry()
+			got, err := getPSUStats(context.Background(), root, reg)
+			if sysfsMissing(err) != tt.wantMissing || err != nil && !tt.wantMissing {
+				t.Fatalf("getPSUStats error = %v, want missing %v", err, tt.wantMissing)
+			}
+			if !reflect.DeepEqual(got.Status, tt.want) {
+				t.Errorf("status = %v, want %v", got.Status, tt.want)
+			}
+			latest := psuLatest(got, time.Time{})
+			for key, want := range tt.wantLatest {
+				if latest[key].Value != want {
+					t.Errorf("%s = %q, want %q", key, latest[key].Value, want)
+				}
+				if _, ok := reg.MetadataTemplates()[key]; !ok {
+					t.Errorf("%s has no template", key)
+				}
+			}
+		})
+	}
+}
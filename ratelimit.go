@@ -0,0 +1,18 @@
+package main
+
+import (
+	"math"
+
+	"golang.org/x/time/rate"
+)
+
+// newReportLimiter returns a limiter allowing rps events per second, with
+// bursts of up to one second's worth. A nil limiter, returned for rps <= 0,
+// allows everything.
+func newReportLimiter(rps float64) *rate.Limiter {
+	if rps <= 0 {
+		return nil
+	}
+	burst := int(math.Max(1, math.Ceil(rps)))
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReportRateLimit(t *testing.T) {
+	const requests = 100
+	tests := []struct {
+		name  string
+		limit float64
+	}{
+		{"default", 10},
+		{"fractional", 0.5},
+		{"disabled", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.CollectMode = collectBackground
+			cfg.ReportRateLimit = tt.limit
+			p := NewPlugin("host", cfg)
+			if err := p.collect(context.Background()); err != nil {
+				t.Fatalf("collect: %v", err)
+			}
+
+			served := 0
+			start := time.Now()
+			for i := 0; i < requests; i++ {
+				rec := httptest.NewRecorder()
+				p.Report(rec, httptest.NewRequest(http.MethodGet, "/report", nil))
+				switch rec.Code {
+				case http.StatusOK:
+					served++
+				case http.StatusTooManyRequests:
+					if got := rec.Header().Get("Retry-After"); got != "1" {
+						t.Errorf("Retry-After = %q, want %q", got, "1")
+					}
+				default:
+					t.Fatalf("status = %d", rec.Code)
+				}
+			}
+			elapsed := time.Since(start).Seconds()
+
+			if tt.limit == 0 {
+				if served != requests {
+					t.Errorf("served %d of %d requests without a limit", served, requests)
+				}
+				return
+			}
+			if max := math.Ceil(requests / (tt.limit * elapsed)); float64(served) > max {
+				t.Errorf("served %d requests in %.3fs, want at most %v", served, elapsed, max)
+			}
+			// The bucket starts full, and refills at the limit.
+			burst := math.Max(1, math.Ceil(tt.limit))
+			if max := burst + math.Ceil(tt.limit*elapsed); float64(served) > max {
+				t.Errorf("served %d requests in %.3fs, want at most %v", served, elapsed, max)
+			}
+			if served < 1 {
+				t.Error("no request was served")
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// errCollectionRunning is returned by collect when the previous collection
+// hasn't returned yet.
+var errCollectionRunning = errors.New("previous collection still running")
+
+// fileConfig is the subset of Config that can be set from the -config file.
+// Durations use time.ParseDuration syntax, e.g. "15s".
+type fileConfig struct {
+	RefreshInterval string `json:"refresh_interval,omitempty"`
+	LogLevel        string `json:"log_level,omitempty"`
+	SocketPath      string `json:"socket_path,omitempty"`
+}
+
+// applyConfigFile overrides cfg with the values set in the JSON file at path.
+func applyConfigFile(cfg *Config, path string) error {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config %q: %v", path, err)
+	}
+	var fc fileConfig
+	if err := json.Unmarshal(raw, &fc); err != nil {
+		return fmt.Errorf("failed to parse config %q: %v", path, err)
+	}
+	if fc.RefreshInterval != "" {
+		d, err := time.ParseDuration(fc.RefreshInterval)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid refresh_interval %q in %q", fc.RefreshInterval, path)
+		}
+		cfg.RefreshInterval = d
+	}
+	if fc.LogLevel != "" {
+		if _, err := parseLogLevel(fc.LogLevel); err != nil {
+			return fmt.Errorf("invalid log_level in %q: %v", path, err)
+		}
+		cfg.LogLevel = fc.LogLevel
+	}
+	if fc.SocketPath != "" {
+		cfg.SocketPath = fc.SocketPath
+	}
+	return nil
+}
+
+// runRefresher collects the host metrics every refresh interval and caches
+// them for Report, until stop is closed.
+func (p *Plugin) runRefresher(stop <-chan struct{}) {
+	// Seed per process so that nodes started together don't jitter alike.
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for {
+		p.refresh()
+
+		p.lock.Lock()
+		interval := jitteredInterval(p.cfg.RefreshInterval, p.cfg.RefreshJitter, rnd.Float64)
+		p.lock.Unlock()
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-p.intervalChanged:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// jitteredInterval varies interval uniformly by up to ±jitter of its value,
+// so the average interval is preserved. random returns values in [0, 1).
+func jitteredInterval(interval time.Duration, jitter float64, random func() float64) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	return time.Duration(float64(interval) * (1 + jitter*(2*random()-1)))
+}
+
+func (p *Plugin) refresh() {
+	p.lock.Lock()
+	interval := p.cfg.RefreshInterval
+	p.lock.Unlock()
+	p.watchdog.run(interval, p.collect)
+}
+
+// collect refreshes the last collection. It doesn't hold p.lock while
+// collecting, so reports keep being served from the previous collection.
+// Results of a collection whose ctx was cancelled by the watchdog are
+// dropped. collect skips the refresh while another collection, such as one
+// the watchdog abandoned, still runs.
+func (p *Plugin) collect(ctx context.Context) error {
+	if len(p.collecting) == cap(p.collecting) {
+		warnf("previous collection still running, skipping this one")
+		return errCollectionRunning
+	}
+	c, err := p.metrics(ctx)
+	if c != nil {
+		p.refreshCounts.record(p.refreshedCollectors(), c.degraded, err != nil)
+	}
+	if err == nil && !p.publish(ctx, c) {
+		err = ctx.Err()
+	}
+	if err != nil {
+		errorf("%v", err)
+		return err
+	}
+	debugf("refreshed host metrics")
+
+	if stateFile := p.config().StateFile; stateFile != "" {
+		if err := p.saveState(stateFile); err != nil {
+			errorf("%v", err)
+		}
+	}
+	return nil
+}
+
+// setupReload reloads the config file whenever the plugin receives SIGHUP.
+func (p *Plugin) setupReload() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := p.reload(); err != nil {
+				errorf("reload: %v", err)
+			}
+		}
+	}()
+}
+
+// reload re-reads the config file and applies the settings that can change
+// at runtime: the refresh interval and the log level.
+func (p *Plugin) reload() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.cfg.ConfigFile == "" {
+		return fmt.Errorf("no config file set, nothing to reload")
+	}
+	next := p.cfg
+	if err := applyConfigFile(&next, p.cfg.ConfigFile); err != nil {
+		return err
+	}
+
+	if next.SocketPath != p.cfg.SocketPath {
+		warnf("socket_path changed to %q, restart the plugin to apply it", next.SocketPath)
+	}
+	if err := setLogLevel(next.LogLevel); err != nil {
+		return err
+	}
+	p.cfg.LogLevel = next.LogLevel
+	if next.RefreshInterval != p.cfg.RefreshInterval {
+		p.cfg.RefreshInterval = next.RefreshInterval
+		select {
+		case p.intervalChanged <- struct{}{}:
+		default:
+		}
+	}
+	log.Printf("Reloaded %s: refresh interval %s, log level %s", p.cfg.ConfigFile, p.cfg.RefreshInterval, p.cfg.LogLevel)
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// blockingCollector blocks in Collect until release is closed.
+type blockingCollector struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCollector) Name() string { return "blocking" }
+
+func (c *blockingCollector) Collect(ctx context.Context) (map[string]stringEntry, []metadataTemplate, error) {
+	close(c.started)
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	return map[string]stringEntry{"blocking": {Value: "done"}}, nil, nil
+}
+
+func TestCollectModesProduceValidReports(t *testing.T) {
+	for _, mode := range []string{collectBackground, collectSync} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.CollectMode = mode
+			p := NewPlugin("host", cfg)
+			if mode == collectBackground {
+				if err := p.collect(context.Background()); err != nil {
+					t.Fatalf("collect: %v", err)
+				}
+			}
+
+			rpt, err := p.makeReport(context.Background())
+			if err != nil {
+				t.Fatalf("makeReport: %v", err)
+			}
+			raw, err := json.Marshal(rpt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := validateReport(raw); err != nil {
+				t.Errorf("invalid report: %v", err)
+			}
+		})
+	}
+}
+
+func TestReportIsServedDuringBackgroundCollection(t *testing.T) {
+	cfg := loadConfig()
+	cfg.CollectMode = collectBackground
+	p := NewPlugin("host", cfg)
+	if err := p.collect(context.Background()); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+
+	slow := &blockingCollector{started: make(chan struct{}), release: make(chan struct{})}
+	p.RegisterCollector(slow)
+	collected := make(chan error, 1)
+	go func() { collected <- p.collect(context.Background()) }()
+	<-slow.started
+
+	served := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report", nil))
+		served <- rec.Code
+	}()
+	select {
+	case code := <-served:
+		if code != http.StatusOK {
+			t.Errorf("status = %d, want %d", code, http.StatusOK)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("/report blocked on the running collection")
+	}
+
+	close(slow.release)
+	if err := <-collected; err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	if got := p.last.node.Latest["blocking"].Value; got != "done" {
+		t.Errorf("blocking = %q after the collection, want %q", got, "done")
+	}
+}
+
+func TestCollectSkipsWhileCollectionRuns(t *testing.T) {
+	p := NewPlugin("host", loadConfig())
+	p.collecting <- struct{}{} // an abandoned collection
+	if err := p.collect(context.Background()); err != errCollectionRunning {
+		t.Errorf("collect = %v, want %v", err, errCollectionRunning)
+	}
+	<-p.collecting
+	if err := p.collect(context.Background()); err != nil {
+		t.Errorf("collect after the collection returned = %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       string
+		wantErr      bool
+		wantInterval time.Duration
+		wantChanged  bool
+	}{
+		{name: "new interval", config: `{"refresh_interval": "5s"}`, wantInterval: 5 * time.Second, wantChanged: true},
+		{name: "same interval", config: `{"refresh_interval": "15s", "log_level": "debug"}`, wantInterval: 15 * time.Second},
+		{name: "invalid interval", config: `{"refresh_interval": "soon"}`, wantErr: true, wantInterval: 15 * time.Second},
+		{name: "invalid json", config: `{`, wantErr: true, wantInterval: 15 * time.Second},
+	}
+	defer setLogLevel("info")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.RefreshInterval = 15 * time.Second
+			cfg.ConfigFile = filepath.Join(writeTree(t, map[string]string{"config.json": tt.config}), "config.json")
+			p := NewPlugin("host", cfg)
+
+			if err := p.reload(); (err != nil) != tt.wantErr {
+				t.Fatalf("reload error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := p.config().RefreshInterval; got != tt.wantInterval {
+				t.Errorf("interval = %s, want %s", got, tt.wantInterval)
+			}
+			if changed := len(p.intervalChanged) == 1; changed != tt.wantChanged {
+				t.Errorf("interval change signalled = %v, want %v", changed, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestSIGHUPReloadsInterval(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no SIGHUP on Windows")
+	}
+	cfg := loadConfig()
+	cfg.RefreshInterval = 15 * time.Second
+	cfg.ConfigFile = filepath.Join(writeTree(t, map[string]string{"config.json": `{"refresh_interval": "2s"}`}), "config.json")
+	p := NewPlugin("host", cfg)
+	p.setupReload()
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := self.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-p.intervalChanged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGHUP didn't reload the config")
+	}
+	if got := p.config().RefreshInterval; got != 2*time.Second {
+		t.Errorf("interval = %s, want 2s", got)
+	}
+}
+
+func TestJitteredInterval(t *testing.T) {
+	const interval = 10 * time.Second
+	tests := []struct {
+		name   string
+		jitter float64
+		random float64
+		want   time.Duration
+	}{
+		{name: "disabled", jitter: 0, random: 0.9, want: interval},
+		{name: "lowest", jitter: 0.2, random: 0, want: 8 * time.Second},
+		{name: "middle", jitter: 0.2, random: 0.5, want: interval},
+		{name: "near highest", jitter: 0.2, random: 0.75, want: 11 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := jitteredInterval(interval, tt.jitter, func() float64 { return tt.random })
+			if got != tt.want {
+				t.Errorf("jitteredInterval = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("successive intervals vary within the bound", func(t *testing.T) {
+		const jitter = 0.1
+		rnd := rand.New(rand.NewSource(1))
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 100; i++ {
+			got := jitteredInterval(interval, jitter, rnd.Float64)
+			if got < 9*time.Second || got > 11*time.Second {
+				t.Fatalf("interval %s outside 10s ±10%%", got)
+			}
+			seen[got] = true
+		}
+		if len(seen) < 50 {
+			t.Errorf("only %d distinct intervals out of 100", len(seen))
+		}
+	})
+}
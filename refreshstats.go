@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refreshCounts counts, per collector, the refresh cycles in which it
+// succeeded or failed. The counts only grow, like Prometheus counters.
+type refreshCounts struct {
+	mu      sync.Mutex
+	success map[string]uint64
+	failure map[string]uint64
+}
+
+// record counts a refresh cycle of the enabled collectors, of which failed
+// failed. When aborted, the cycle stopped at the failed collectors and the
+// others didn't run.
+func (c *refreshCounts) record(enabled, failed []string, aborted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.success == nil {
+		c.success, c.failure = map[string]uint64{}, map[string]uint64{}
+	}
+
+	isFailed := map[string]bool{}
+	for _, name := range failed {
+		isFailed[name] = true
+		c.failure[name]++
+	}
+	if aborted {
+		return
+	}
+	for _, name := range enabled {
+		if !isFailed[name] {
+			c.success[name]++
+		}
+	}
+}
+
+// refreshedCollectors lists the enabled collectors, including those added
+// with RegisterCollector.
+func (p *Plugin) refreshedCollectors() []string {
+	names := p.config().enabledCollectors()
+	known := map[string]bool{}
+	for _, name := range names {
+		known[name] = true
+	}
+	for _, c := range p.collectors {
+		if !known[c.Name()] {
+			names = append(names, c.Name())
+		}
+	}
+	return names
+}
+
+// failures lists the collectors that failed at least once as
+// "<collector>=<count>", sorted by collector.
+func (c *refreshCounts) failures() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var parts []string
+	for name, n := range c.failure {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// ServeHTTP writes the counts in the Prometheus text format.
+func (c *refreshCounts) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	writeCounter(w, "cpuinfo_refresh_success_total", "Refresh cycles in which the collector succeeded.", c.success)
+	writeCounter(w, "cpuinfo_refresh_failure_total", "Refresh cycles in which the collector failed.", c.failure)
+}
+
+func writeCounter(w http.ResponseWriter, name, help string, values map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	collectors := make([]string, 0, len(values))
+	for collector := range values {
+		collectors = append(collectors, collector)
+	}
+	sort.Strings(collectors)
+	for _, collector := range collectors {
+		fmt.Fprintf(w, "%s{collector=%q} %d\n", name, collector, values[collector])
+	}
+}
+
+func refreshFailuresLatest(counts *refreshCounts, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"refresh_failures": {Timestamp: t, Value: counts.failures()},
+	}
+}
+
+func getRefreshFailuresMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"refresh_failures": {
+			ID:       "refresh_failures",
+			Label:    "Refresh Failures",
+			Priority: prioritySelf + 5.1,
+			From:     "latest",
+		},
+	}
+}
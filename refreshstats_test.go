@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRefreshCountsRecord(t *testing.T) {
+	type cycle struct {
+		failed  []string
+		aborted bool
+	}
+	enabled := []string{"cpu", "mem", "gpu"}
+	tests := []struct {
+		name        string
+		cycles      []cycle
+		wantSuccess map[string]uint64
+		wantFailure map[string]uint64
+	}{
+		{
+			name:        "all succeed",
+			cycles:      []cycle{{}, {}},
+			wantSuccess: map[string]uint64{"cpu": 2, "mem": 2, "gpu": 2},
+			wantFailure: map[string]uint64{},
+		},
+		{
+			name:        "one collector fails",
+			cycles:      []cycle{{failed: []string{"gpu"}}, {}, {failed: []string{"gpu"}}},
+			wantSuccess: map[string]uint64{"cpu": 3, "mem": 3, "gpu": 1},
+			wantFailure: map[string]uint64{"gpu": 2},
+		},
+		{
+			name:        "aborted cycle counts no successes",
+			cycles:      []cycle{{failed: []string{"cpu"}, aborted: true}},
+			wantSuccess: map[string]uint64{},
+			wantFailure: map[string]uint64{"cpu": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c refreshCounts
+			for _, cy := range tt.cycles {
+				c.record(enabled, cy.failed, cy.aborted)
+			}
+			for name, want := range tt.wantSuccess {
+				if got := c.success[name]; got != want {
+					t.Errorf("success[%s] = %d, want %d", name, got, want)
+				}
+			}
+			for name, want := range tt.wantFailure {
+				if got := c.failure[name]; got != want {
+					t.Errorf("failure[%s] = %d, want %d", name, got, want)
+				}
+			}
+			if len(c.failure) != len(tt.wantFailure) {
+				t.Errorf("failures = %v, want %v", c.failure, tt.wantFailure)
+			}
+		})
+	}
+}
+
+func TestCollectorFailureIncrementsCounter(t *testing.T) {
+	cfg := loadConfig()
+	cfg.CollectMode = collectBackground
+	cfg.RefreshFailuresRow = true
+	p := NewPlugin("host", cfg)
+	p.RegisterCollector(failingCollector{name: "flaky", err: errors.New("device busy")})
+
+	for i := 0; i < 3; i++ {
+		if err := p.collect(context.Background()); err != nil {
+			t.Fatalf("collect: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`cpuinfo_refresh_failure_total{collector="flaky"} 3`,
+		`cpuinfo_refresh_success_total{collector="cpu"} 3`,
+		"# TYPE cpuinfo_refresh_failure_total counter",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics is missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `cpuinfo_refresh_success_total{collector="flaky"}`) {
+		t.Errorf("/metrics counts successes of the failing collector:\n%s", body)
+	}
+
+	// The row is collected before the cycle is recorded.
+	if got := p.last.node.Latest["refresh_failures"].Value; got != "flaky=2" {
+		t.Errorf("refresh_failures = %q, want %q", got, "flaky=2")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import "time"
+
+// Base priorities of the metadata templates, by kind of metric, so that the
+// host panel lists what the machine is, then how busy it is, then how it is
+// configured, and the plugin's own metrics last. Templates add small offsets
+// to order metrics within a group.
+const (
+	// priorityHardware, 10-12: CPU model, counts, caches, devices.
+	priorityHardware = 10
+	// priorityUtilization, 13-15: CPU, memory, network and disk usage.
+	priorityUtilization = 13
+	// prioritySoftware, 16-20: governor, sysctls, security, labels.
+	prioritySoftware = 16
+	// prioritySelf, 25 and up: the plugin's container, runtime and health.
+	prioritySelf = 25
+)
+
+// TemplateRegistry collects the metadata and table templates registered by
+// the collectors during a collection, so that a report only describes the
+// metrics that were actually collected. A nil *TemplateRegistry discards
+// everything registered with it.
+type TemplateRegistry struct {
+	metadata map[string]metadataTemplate
+	tables   map[string]tableTemplate
+}
+
+func NewTemplateRegistry() *TemplateRegistry {
+	return &TemplateRegistry{
+		metadata: map[string]metadataTemplate{},
+		tables:   map[string]tableTemplate{},
+	}
+}
+
+// RegisterMetadata adds templates, replacing any with the same ID.
+func (r *TemplateRegistry) RegisterMetadata(templates map[string]metadataTemplate) {
+	if r == nil {
+		return
+	}
+	for id, t := range templates {
+		r.metadata[id] = t
+	}
+}
+
+// RegisterSets adds set templates as metadata templates with From "sets",
+// replacing any with the same ID.
+func (r *TemplateRegistry) RegisterSets(templates map[string]setTemplate) {
+	if r == nil {
+		return
+	}
+	for id, t := range templates {
+		r.metadata[id] = t.metadata()
+	}
+}
+
+// RegisterTables adds templates, replacing any with the same ID.
+func (r *TemplateRegistry) RegisterTables(templates map[string]tableTemplate) {
+	if r == nil {
+		return
+	}
+	for id, t := range templates {
+		r.tables[id] = t
+	}
+}
+
+// MetadataTemplates returns a copy of the registered metadata templates.
+func (r *TemplateRegistry) MetadataTemplates() map[string]metadataTemplate {
+	templates := map[string]metadataTemplate{}
+	if r == nil {
+		return templates
+	}
+	for id, t := range r.metadata {
+		templates[id] = t
+	}
+	return templates
+}
+
+// TableTemplates returns a copy of the registered table templates.
+func (r *TemplateRegistry) TableTemplates() map[string]tableTemplate {
+	templates := map[string]tableTemplate{}
+	if r == nil {
+		return templates
+	}
+	for id, t := range r.tables {
+		templates[id] = t
+	}
+	return templates
+}
+
+// stampTables sets the LastUpdated time of every table in templates to t.
+func stampTables(templates map[string]tableTemplate, t time.Time) map[string]tableTemplate {
+	for id, table := range templates {
+		table.LastUpdated = t
+		templates[id] = table
+	}
+	return templates
+}
+
+// prefixed returns a registry with prefix prepended to every template ID, and
+// to the row key prefix of every table, to match prefixLatest.
+func (r *TemplateRegistry) prefixed(prefix string) *TemplateRegistry {
+	if r == nil || prefix == "" {
+		return r
+	}
+	p := NewTemplateRegistry()
+	for id, t := range r.metadata {
+		t.ID = prefix + t.ID
+		p.metadata[prefix+id] = t
+	}
+	for id, t := range r.tables {
+		t.ID = prefix + t.ID
+		t.Prefix = prefix + t.Prefix
+		p.tables[prefix+id] = t
+	}
+	return p
+}
+
+// prefixLatest returns latest with prefix prepended to every key.
+func prefixLatest(latest map[string]stringEntry, prefix string) map[string]stringEntry {
+	if prefix == "" {
+		return latest
+	}
+	prefixed := make(map[string]stringEntry, len(latest))
+	for k, v := range latest {
+		prefixed[prefix+k] = v
+	}
+	return prefixed
+}
+
+// prefixSets returns sets with prefix prepended to every key.
+func prefixSets(sets map[string][]string, prefix string) map[string][]string {
+	if prefix == "" {
+		return sets
+	}
+	prefixed := make(map[string][]string, len(sets))
+	for k, v := range sets {
+		prefixed[prefix+k] = v
+	}
+	return prefixed
+}
+
+// pickMetadata returns the templates with the given IDs.
+func pickMetadata(templates map[string]metadataTemplate, ids ...string) map[string]metadataTemplate {
+	picked := make(map[string]metadataTemplate, len(ids))
+	for _, id := range ids {
+		if t, ok := templates[id]; ok {
+			picked[id] = t
+		}
+	}
+	return picked
+}
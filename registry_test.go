@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(reg *TemplateRegistry)
+		wantIDs  []string
+	}{
+		{name: "empty", register: func(reg *TemplateRegistry) {}},
+		{
+			name: "collectors register their own templates",
+			register: func(reg *TemplateRegistry) {
+				reg.RegisterMetadata(getLoadMetadataTemplate())
+				reg.RegisterMetadata(getCPUUsageMetadataTemplate())
+			},
+			wantIDs: []string{"load_1", "load_5", "load_15", "load_1_per_core", "cpu_user_percent", "cpu_system_percent", "cpu_idle_percent"},
+		},
+		{
+			name: "same ID replaces",
+			register: func(reg *TemplateRegistry) {
+				reg.RegisterMetadata(map[string]metadataTemplate{"x": {ID: "x", Label: "old"}})
+				reg.RegisterMetadata(map[string]metadataTemplate{"x": {ID: "x", Label: "new"}})
+			},
+			wantIDs: []string{"x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewTemplateRegistry()
+			tt.register(reg)
+			got := reg.MetadataTemplates()
+			if len(got) != len(tt.wantIDs) {
+				t.Errorf("got %d templates, want %d", len(got), len(tt.wantIDs))
+			}
+			for _, id := range tt.wantIDs {
+				if got[id].ID != id {
+					t.Errorf("template %q missing", id)
+				}
+			}
+			if x, ok := got["x"]; ok && x.Label != "new" {
+				t.Errorf("x label = %q, want the later registration", x.Label)
+			}
+		})
+	}
+}
+
+func TestNilTemplateRegistry(t *testing.T) {
+	var reg *TemplateRegistry
+	reg.RegisterMetadata(getLoadMetadataTemplate())
+	reg.RegisterTables(getTableTemplate())
+	if len(reg.MetadataTemplates()) != 0 || len(reg.TableTemplates()) != 0 {
+		t.Error("nil registry returned templates")
+	}
+}
+
+func TestDisabledCollectorsRegisterNoTemplates(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		cfg := loadConfig()
+		cfg.NetTable = enabled
+		p := NewPlugin("host", cfg)
+		c, err := p.metrics(context.Background())
+		if err != nil {
+			t.Fatalf("metrics: %v", err)
+		}
+		if _, ok := c.templates.TableTemplates()["cpuinfo-net-table"]; ok != enabled {
+			t.Errorf("NetTable = %v: net table template registered = %v", enabled, ok)
+		}
+	}
+}
+
+func TestKeyPrefix(t *testing.T) {
+	for _, prefix := range []string{"", "cpuinfo_"} {
+		t.Run("prefix="+prefix, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.KeyPrefix = prefix
+			p := NewPlugin("host", cfg)
+			c, err := p.metrics(context.Background())
+			if err != nil {
+				t.Fatalf("metrics: %v", err)
+			}
+
+			metadata := c.templates.MetadataTemplates()
+			for id, tmpl := range metadata {
+				if !strings.HasPrefix(id, prefix) || tmpl.ID != id {
+					t.Errorf("template key %q, ID %q, want both prefixed with %q", id, tmpl.ID, prefix)
+				}
+			}
+			var tablePrefixes []string
+			for _, table := range c.templates.TableTemplates() {
+				if !strings.HasPrefix(table.Prefix, prefix) {
+					t.Errorf("table %s row prefix %q isn't prefixed with %q", table.ID, table.Prefix, prefix)
+				}
+				tablePrefixes = append(tablePrefixes, table.Prefix)
+			}
+		keys:
+			for key := range c.node.Latest {
+				if !strings.HasPrefix(key, prefix) {
+					t.Errorf("Latest key %q isn't prefixed with %q", key, prefix)
+				}
+				for _, rowPrefix := range tablePrefixes {
+					if strings.HasPrefix(key, rowPrefix) {
+						continue keys
+					}
+				}
+				if _, ok := metadata[key]; !ok {
+					t.Errorf("Latest key %q has no template", key)
+				}
+			}
+			for key := range c.node.Sets {
+				if metadata[key].From != "sets" {
+					t.Errorf("set %q has no set template", key)
+				}
+			}
+
+			cfg.CollectMode = collectBackground
+			p = NewPlugin("host", cfg)
+			p.last = goldenCollection(p, time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC))
+			p.last.disks = []DiskStats{{Name: "sda", Model: "Samsung SSD 860", SizeGB: 512, Type: "SSD", Health: "PASSED"}}
+			rpt, err := p.makeReport(context.Background())
+			if err != nil {
+				t.Fatalf("makeReport: %v", err)
+			}
+			for name, top := range map[string]*topology{"Disk": rpt.Disk, "Process": rpt.Process} {
+				if top == nil || len(top.Nodes) == 0 {
+					t.Fatalf("no %s topology", name)
+				}
+				for id, tmpl := range top.MetadataTemplates {
+					if !strings.HasPrefix(id, prefix) || tmpl.ID != id {
+						t.Errorf("%s template key %q, ID %q, want both prefixed with %q", name, id, tmpl.ID, prefix)
+					}
+				}
+				for nodeID, n := range top.Nodes {
+					for key := range n.Latest {
+						if !strings.HasPrefix(key, prefix) {
+							t.Errorf("%s node %s key %q isn't prefixed with %q", name, nodeID, key, prefix)
+						}
+						if _, ok := top.MetadataTemplates[key]; !ok {
+							t.Errorf("%s node %s key %q has no template", name, nodeID, key)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMetadataPriorityGroups(t *testing.T) {
+	merge := func(sets ...map[string]metadataTemplate) map[string]metadataTemplate {
+		all := map[string]metadataTemplate{}
+		for _, set := range sets {
+			for id, tmpl := range set {
+				all[id] = tmpl
+			}
+		}
+		return all
+	}
+	groups := []struct {
+		name      string
+		templates map[string]metadataTemplate
+		min, max  float64
+	}{
+		{
+			name: "hardware",
+			templates: merge(getMetadataTemplate(), getCPUFreqMetadataTemplate(), getCacheMetadataTemplate(),
+				getCoreTypeMetadataTemplate(), getSMTMetadataTemplate(), getCPUOnlineMetadataTemplate(),
+				getGPUMetadataTemplate(), getPSUMetadataTemplate(2), getECCMetadataTemplate()),
+			min: 10, max: 12,
+		},
+		{
+			name: "utilization",
+			templates: merge(getCPUUsageMetadataTemplate(), getLoadMetadataTemplate(), getCommitMetadataTemplate(),
+				getMemBandwidthMetadataTemplate(), getNetMetadataTemplate(), getTCPMetadataTemplate(),
+				getSwapMetadataTemplate(), getInodeMetadataTemplate()),
+			min: 13, max: 15,
+		},
+		{
+			name: "software",
+			templates: merge(getCPUGovernorMetadataTemplate(), getVMSysctlMetadataTemplate(), getSecurityMetadataTemplate(),
+				getVulnerabilityMetadataTemplate(), getFailedUnitsMetadataTemplate(), getDefaultRouteMetadataTemplate(),
+				getIPAddressesMetadataTemplate(), getExtraLabelsMetadataTemplate(map[string]string{"rack": "r7"})),
+			min: 16, max: 20,
+		},
+		{
+			name: "self",
+			templates: merge(getSelfMetadataTemplate(), getRuntimeMetadataTemplate(), getPrivilegesMetadataTemplate(),
+				getCgroupMetadataTemplate(), getHealthMetadataTemplate(), getRefreshFailuresMetadataTemplate()),
+			min: 25, max: math.MaxFloat64,
+		},
+	}
+	for _, g := range groups {
+		t.Run(g.name, func(t *testing.T) {
+			if len(g.templates) == 0 {
+				t.Fatal("no templates")
+			}
+			for id, tmpl := range g.templates {
+				if tmpl.Priority < g.min || tmpl.Priority > g.max {
+					t.Errorf("%s priority = %v, want %v-%v", id, tmpl.Priority, g.min, g.max)
+				}
+			}
+		})
+	}
+}
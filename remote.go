@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// remoteReportTimeout bounds each request to a remote instance, so a dead
+// host only delays the report by that much.
+const remoteReportTimeout = 2 * time.Second
+
+// fetchRemoteReports gets the reports of the cpuinfo instances at hosts, given
+// as host:port or as a bare host on defaultPort, in parallel. Hosts that fail
+// are logged and left out.
+func fetchRemoteReports(ctx context.Context, client *http.Client, hosts []string, defaultPort string) []report {
+	reports := make([]*report, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			rpt, err := fetchRemoteReport(ctx, client, remoteAddr(host, defaultPort))
+			if err != nil {
+				errorf("remote host %s: %v", host, err)
+				return
+			}
+			reports[i] = rpt
+		}(i, host)
+	}
+	wg.Wait()
+
+	var fetched []report
+	for _, rpt := range reports {
+		if rpt != nil {
+			fetched = append(fetched, *rpt)
+		}
+	}
+	return fetched
+}
+
+// remoteHasPort tells whether host, a remote host or URL, can be reached
+// without a default port.
+func remoteHasPort(host string) bool {
+	if strings.Contains(host, "://") {
+		return true
+	}
+	_, _, err := net.SplitHostPort(host)
+	return err == nil
+}
+
+// remoteAddr adds port to host when host has none.
+func remoteAddr(host, port string) string {
+	if port == "" || remoteHasPort(host) {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
+
+func fetchRemoteReport(ctx context.Context, client *http.Client, host string) (*report, error) {
+	u := host
+	if !strings.Contains(u, "://") {
+		u = "http://" + u
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(u, "/")+"/report", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var rpt report
+	if err := json.NewDecoder(resp.Body).Decode(&rpt); err != nil {
+		return nil, fmt.Errorf("invalid report: %v", err)
+	}
+	return &rpt, nil
+}
+
+// mergeRemoteHosts adds the host nodes and templates of the remote reports to
+// host. Local nodes and templates win over remote ones with the same ID.
+// Remote controls are dropped, since this plugin can't execute them.
+func mergeRemoteHosts(host *topology, remotes []report) {
+	for _, remote := range remotes {
+		for id, n := range remote.Host.Nodes {
+			if _, ok := host.Nodes[id]; ok {
+				continue
+			}
+			n.LatestControls = nil
+			host.Nodes[id] = n
+		}
+		for id, t := range remote.Host.MetadataTemplates {
+			if _, ok := host.MetadataTemplates[id]; !ok {
+				host.MetadataTemplates[id] = t
+			}
+		}
+		for id, t := range remote.Host.TableTemplates {
+			if _, ok := host.TableTemplates[id]; !ok {
+				host.TableTemplates[id] = t
+			}
+		}
+		for id, t := range remote.Host.MetricTemplates {
+			if host.MetricTemplates == nil {
+				host.MetricTemplates = map[string]metricTemplate{}
+			}
+			if _, ok := host.MetricTemplates[id]; !ok {
+				host.MetricTemplates[id] = t
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// remoteServer serves rpt on /report, counting the requests.
+func remoteServer(t *testing.T, rpt *report, requests *int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if rpt == nil {
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(rpt)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func remoteReport(nodeID, model string) *report {
+	return &report{Host: topology{
+		Nodes: map[string]node{nodeID: {
+			Latest:         map[string]stringEntry{"cpu_model": {Value: model}},
+			LatestControls: map[string]controlEntry{"cpuinfo-refresh": {}},
+		}},
+		MetadataTemplates: map[string]metadataTemplate{
+			"cpu_model":    {ID: "cpu_model", Label: "Remote CPU Model"},
+			"remote_extra": {ID: "remote_extra", Label: "Extra"},
+		},
+	}}
+}
+
+func TestRemoteHostsAreMergedFromTheCollection(t *testing.T) {
+	var requests int32
+	tests := []struct {
+		name    string
+		remotes []*report
+		want    map[string]string // node ID to cpu_model
+	}{
+		{
+			name:    "two hosts",
+			remotes: []*report{remoteReport("a;<host>", "Xeon"), remoteReport("b;<host>", "EPYC")},
+			want:    map[string]string{"a;<host>": "Xeon", "b;<host>": "EPYC"},
+		},
+		{
+			name:    "one host failing",
+			remotes: []*report{remoteReport("a;<host>", "Xeon"), nil},
+			want:    map[string]string{"a;<host>": "Xeon"},
+		},
+		{
+			name:    "remote node with the local ID",
+			remotes: []*report{remoteReport("local;<host>", "Impostor")},
+			want:    map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+			cfg := loadConfig()
+			cfg.CollectMode = collectBackground
+			for _, rpt := range tt.remotes {
+				srv := remoteServer(t, rpt, &requests)
+				cfg.RemoteHosts = append(cfg.RemoteHosts, strings.TrimPrefix(srv.URL, "http://"))
+			}
+			p := NewPlugin("local", cfg)
+			if err := p.collect(context.Background()); err != nil {
+				t.Fatalf("collect: %v", err)
+			}
+
+			for i := 0; i < 3; i++ {
+				rpt, err := p.makeReport(context.Background())
+				if err != nil {
+					t.Fatalf("makeReport: %v", err)
+				}
+				got := map[string]string{}
+				for id, n := range rpt.Host.Nodes {
+					if id == "local;<host>" {
+						if n.Latest["cpu_model"].Value == "Impostor" {
+							t.Error("a remote node replaced the local one")
+						}
+						continue
+					}
+					got[id] = n.Latest["cpu_model"].Value
+					if n.LatestControls != nil {
+						t.Errorf("%s kept its remote controls", id)
+					}
+				}
+				if len(got) != len(tt.want) {
+					t.Errorf("remote nodes = %v, want %v", got, tt.want)
+				}
+				for id, model := range tt.want {
+					if got[id] != model {
+						t.Errorf("%s cpu_model = %q, want %q", id, got[id], model)
+					}
+				}
+				if _, ok := rpt.Host.MetadataTemplates["remote_extra"]; !ok {
+					t.Error("remote templates weren't merged")
+				}
+				if got := rpt.Host.MetadataTemplates["cpu_model"].Label; got == "Remote CPU Model" {
+					t.Error("a remote template replaced the local one")
+				}
+			}
+			// Reports merge the copies fetched by the collection.
+			if got := atomic.LoadInt32(&requests); int(got) != len(tt.remotes) {
+				t.Errorf("remote requests = %d, want %d", got, len(tt.remotes))
+			}
+		})
+	}
+}
+
+func TestFetchRemoteReportsTimeout(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	var requests int32
+	fast := remoteServer(t, remoteReport("fast;<host>", "Xeon"), &requests)
+	client := &http.Client{Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	reports := fetchRemoteReports(context.Background(), client, []string{slow.URL, fast.URL}, "")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("fetch took %s", elapsed)
+	}
+	var ids []string
+	for _, rpt := range reports {
+		for id := range rpt.Host.Nodes {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if strings.Join(ids, ",") != "fast;<host>" {
+		t.Errorf("fetched nodes = %v, want only the fast host", ids)
+	}
+}
+
+func TestRemoteAddr(t *testing.T) {
+	tests := []struct {
+		host, port, want string
+	}{
+		{host: "node-1:4040", port: "8080", want: "node-1:4040"},
+		{host: "node-1", port: "8080", want: "node-1:8080"},
+		{host: "node-1", port: "", want: "node-1"},
+		{host: "10.0.0.7", port: "8080", want: "10.0.0.7:8080"},
+		{host: "::1", port: "8080", want: "[::1]:8080"},
+		{host: "[::1]", port: "8080", want: "[::1]:8080"},
+		{host: "[::1]:4040", port: "8080", want: "[::1]:4040"},
+		{host: "https://node-1", port: "8080", want: "https://node-1"},
+	}
+	for _, tt := range tests {
+		if got := remoteAddr(tt.host, tt.port); got != tt.want {
+			t.Errorf("remoteAddr(%q, %q) = %q, want %q", tt.host, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestFetchRemoteReportsDefaultPort(t *testing.T) {
+	var requests int32
+	srv := remoteServer(t, remoteReport("a;<host>", "Xeon"), &requests)
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := fetchRemoteReports(context.Background(), srv.Client(), []string{host}, port)
+	if len(reports) != 1 || reports[0].Host.Nodes["a;<host>"].Latest["cpu_model"].Value != "Xeon" {
+		t.Errorf("reports = %+v, want the report of a;<host>", reports)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
+
+func TestValidateRemoteHosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		hosts   []string
+		port    string
+		wantErr bool
+	}{
+		{name: "hosts with ports", hosts: []string{"a:8080", "http://b"}},
+		{name: "bare hosts with a default port", hosts: []string{"a", "b:4040"}, port: "8080"},
+		{name: "bare host without a default port", hosts: []string{"a:8080", "b"}, wantErr: true},
+		{name: "port out of range", hosts: []string{"a"}, port: "70000", wantErr: true},
+		{name: "port not a number", hosts: []string{"a"}, port: "http", wantErr: true},
+		{name: "port without hosts", port: "8080"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.RemoteHosts, cfg.TCPPort = tt.hosts, tt.port
+			if err := cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultIPv4RoutePath = "/proc/net/route"
+	defaultIPv6RoutePath = "/proc/net/ipv6_route"
+)
+
+// Route flags, from linux/route.h.
+const (
+	routeFlagUp     = 0x0001
+	routeFlagReject = 0x0200
+)
+
+// DefaultRouteStats tells whether the host has a default route per IP
+// version.
+type DefaultRouteStats struct {
+	IPv4 bool
+	IPv6 bool
+}
+
+// getDefaultRouteStats reads the IPv4 and IPv6 routing tables in the
+// /proc/net/route and /proc/net/ipv6_route formats. It returns a missing
+// error off Linux or without the IPv4 table; a missing IPv6 table, as with
+// IPv6 disabled, means no IPv6 default route.
+func getDefaultRouteStats(ctx context.Context, ipv4Path, ipv6Path string, reg *TemplateRegistry) (DefaultRouteStats, error) {
+	if runtime.GOOS != "linux" {
+		return DefaultRouteStats{}, &MetricError{Subsystem: "routes", Err: os.ErrNotExist}
+	}
+	var stats DefaultRouteStats
+	f, err := os.Open(ipv4Path)
+	if err != nil {
+		return DefaultRouteStats{}, &MetricError{Subsystem: "routes", Err: err}
+	}
+	defer f.Close()
+	if stats.IPv4, err = hasIPv4DefaultRoute(f); err != nil {
+		return DefaultRouteStats{}, &MetricError{Subsystem: "routes", Err: err}
+	}
+
+	f6, err := os.Open(ipv6Path)
+	if err != nil && !os.IsNotExist(err) {
+		return DefaultRouteStats{}, &MetricError{Subsystem: "routes", Err: err}
+	}
+	if err == nil {
+		defer f6.Close()
+		if stats.IPv6, err = hasIPv6DefaultRoute(f6); err != nil {
+			return DefaultRouteStats{}, &MetricError{Subsystem: "routes", Err: err}
+		}
+	}
+	reg.RegisterMetadata(getDefaultRouteMetadataTemplate())
+	return stats, nil
+}
+
+// hasIPv4DefaultRoute looks for an up route with a zero destination and
+// mask. Lines are "Iface Destination Gateway Flags RefCnt Use Metric Mask
+// ...", with hex fields, after a header line.
+func hasIPv4DefaultRoute(r io.Reader) (bool, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		if routeUsable(fields[3]) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// hasIPv6DefaultRoute looks for an up route to ::/0 that isn't a reject
+// route, like the one the kernel keeps on lo. Lines are "dest dest_len src
+// src_len next_hop metric refcnt use flags iface", with hex fields and no
+// header.
+func hasIPv6DefaultRoute(r io.Reader) (bool, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || strings.Trim(fields[0], "0") != "" || fields[1] != "00" {
+			continue
+		}
+		if routeUsable(fields[8]) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// routeUsable tells whether the hex route flags are up and not reject.
+func routeUsable(hexFlags string) bool {
+	flags, err := strconv.ParseUint(hexFlags, 16, 32)
+	return err == nil && flags&routeFlagUp != 0 && flags&routeFlagReject == 0
+}
+
+func defaultRouteLatest(stats DefaultRouteStats, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"has_ipv4_default": {Timestamp: t, Value: strconv.FormatBool(stats.IPv4)},
+		"has_ipv6_default": {Timestamp: t, Value: strconv.FormatBool(stats.IPv6)},
+	}
+}
+
+func getDefaultRouteMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"has_ipv4_default": {
+			ID:       "has_ipv4_default",
+			Label:    "IPv4 Default Route",
+			Priority: prioritySoftware + 1.1,
+			From:     "latest",
+		},
+		"has_ipv6_default": {
+			ID:       "has_ipv6_default",
+			Label:    "IPv6 Default Route",
+			Priority: prioritySoftware + 1.1,
+			From:     "latest",
+		},
+	}
+}
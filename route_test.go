@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const ipv4RouteHeader = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+
+func TestHasIPv4DefaultRoute(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		want  bool
+	}{
+		{
+			name: "default via gateway",
+			table: ipv4RouteHeader +
+				"eth0\t00000000\t0102A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+				"eth0\t0002A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n",
+			want: true,
+		},
+		{
+			name:  "subnet routes only",
+			table: ipv4RouteHeader + "eth0\t0002A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n",
+		},
+		{
+			name:  "default route down",
+			table: ipv4RouteHeader + "eth0\t00000000\t0102A8C0\t0002\t0\t0\t100\t00000000\t0\t0\t0\n",
+		},
+		{
+			name:  "unreachable default",
+			table: ipv4RouteHeader + "*\t00000000\t00000000\t0201\t0\t0\t0\t00000000\t0\t0\t0\n",
+		},
+		{
+			name:  "zero destination with a mask",
+			table: ipv4RouteHeader + "eth0\t00000000\t0102A8C0\t0003\t0\t0\t100\t000000FF\t0\t0\t0\n",
+		},
+		{name: "header only", table: ipv4RouteHeader},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hasIPv4DefaultRoute(strings.NewReader(tt.table))
+			if err != nil {
+				t.Fatalf("hasIPv4DefaultRoute: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("hasIPv4DefaultRoute = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasIPv6DefaultRoute(t *testing.T) {
+	const (
+		defaultViaRA = "00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00450003     eth0\n"
+		loReject     = "00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo\n"
+		linkLocal    = "fe800000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0\n"
+	)
+	tests := []struct {
+		name  string
+		table string
+		want  bool
+	}{
+		{name: "default via router advertisement", table: linkLocal + defaultViaRA + loReject, want: true},
+		{name: "reject route on lo only", table: linkLocal + loReject},
+		{name: "link-local only", table: linkLocal},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hasIPv6DefaultRoute(strings.NewReader(tt.table))
+			if err != nil {
+				t.Fatalf("hasIPv6DefaultRoute: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("hasIPv6DefaultRoute = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteUsable(t *testing.T) {
+	tests := []struct {
+		flags string
+		want  bool
+	}{
+		{flags: "0001", want: true},
+		{flags: "0003", want: true},
+		{flags: "00450003", want: true},
+		{flags: "0002"},
+		{flags: "0201"},
+		{flags: "00200200"},
+		{flags: "zz"},
+		{flags: ""},
+	}
+	for _, tt := range tests {
+		if got := routeUsable(tt.flags); got != tt.want {
+			t.Errorf("routeUsable(%q) = %v, want %v", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestGetDefaultRouteStats(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the routing tables are only read on Linux")
+	}
+	const (
+		ipv4Default = "eth0\t00000000\t0102A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n"
+		ipv6Default = "00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00450003     eth0\n"
+	)
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        DefaultRouteStats
+		wantMissing bool
+	}{
+		{
+			name:  "dual stack",
+			files: map[string]string{"route": ipv4RouteHeader + ipv4Default, "ipv6_route": ipv6Default},
+			want:  DefaultRouteStats{IPv4: true, IPv6: true},
+		},
+		{
+			name:  "IPv6 disabled",
+			files: map[string]string{"route": ipv4RouteHeader + ipv4Default},
+			want:  DefaultRouteStats{IPv4: true},
+		},
+		{
+			name:  "IPv6 only",
+			files: map[string]string{"route": ipv4RouteHeader, "ipv6_route": ipv6Default},
+			want:  DefaultRouteStats{IPv6: true},
+		},
+		{
+			name:        "no IPv4 table",
+			files:       map[string]string{"ipv6_route": ipv6Default},
+			wantMissing: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTree(t, tt.files)
+			reg := NewTemplateRegistry()
+			got, err := getDefaultRouteStats(context.Background(), filepath.Join(dir, "route"), filepath.Join(dir, "ipv6_route"), reg)
+			if sysfsMissing(err) != tt.wantMissing {
+				t.Fatalf("getDefaultRouteStats error = %v, want missing %v", err, tt.wantMissing)
+			}
+			if err != nil {
+				if !tt.wantMissing {
+					t.Fatalf("getDefaultRouteStats: %v", err)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("getDefaultRouteStats = %+v, want %+v", got, tt.want)
+			}
+			if len(reg.MetadataTemplates()) != 2 {
+				t.Errorf("templates = %v, want has_ipv4_default and has_ipv6_default", reg.MetadataTemplates())
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+// runtimeLatest describes the plugin's own Go runtime, so that GOMAXPROCS can
+// be compared with cgroup_cpu_quota_cores.
+func runtimeLatest(t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"plugin_gomaxprocs": {Timestamp: t, Value: formatNumber(float64(runtime.GOMAXPROCS(0)), 0)},
+		"plugin_go_version": {Timestamp: t, Value: runtime.Version()},
+	}
+}
+
+// checkGOMAXPROCS warns when GOMAXPROCS exceeds the cgroup CPU quota by more
+// than 2x, which causes needless scheduler overhead and throttling.
+func checkGOMAXPROCS(cgroupRoot string) {
+	stats, err := getCgroupStats(context.Background(), cgroupRoot, nil)
+	if err != nil || stats.CPUQuotaCores <= 0 {
+		return
+	}
+	if procs := runtime.GOMAXPROCS(0); float64(procs) > 2*stats.CPUQuotaCores {
+		warnf("GOMAXPROCS=%d exceeds the cgroup CPU quota of %.2f cores by more than 2x, consider setting GOMAXPROCS", procs, stats.CPUQuotaCores)
+	}
+}
+
+func getRuntimeMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"plugin_gomaxprocs": {
+			ID:       "plugin_gomaxprocs",
+			Label:    "Plugin GOMAXPROCS",
+			Datatype: "integer",
+			Priority: prioritySelf,
+			From:     "latest",
+		},
+		"plugin_go_version": {
+			ID:       "plugin_go_version",
+			Label:    "Plugin Go Version",
+			Priority: prioritySelf,
+			From:     "latest",
+		},
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const defaultSysPath = "/sys"
+
+// SecurityStats holds the status of the Linux security modules.
+type SecurityStats struct {
+	// SELinuxMode is "enforcing", "permissive" or "disabled".
+	SELinuxMode     string
+	AppArmorEnabled bool
+}
+
+// getSecurityStats reads the SELinux and AppArmor status under sysRoot,
+// normally /sys. The error wraps os.ErrNotExist when neither is available.
+func getSecurityStats(ctx context.Context, sysRoot string, reg *TemplateRegistry) (SecurityStats, error) {
+	enforce, selinuxErr := readSysfsString(filepath.Join(sysRoot, "fs", "selinux", "enforce"))
+	apparmor, apparmorErr := readSysfsString(filepath.Join(sysRoot, "module", "apparmor", "parameters", "enabled"))
+	if sysfsMissing(selinuxErr) && sysfsMissing(apparmorErr) {
+		return SecurityStats{}, &MetricError{Subsystem: "security", Err: os.ErrNotExist}
+	}
+	for _, err := range []error{selinuxErr, apparmorErr} {
+		if err != nil && !sysfsMissing(err) {
+			return SecurityStats{}, &MetricError{Subsystem: "security", Err: err}
+		}
+	}
+
+	stats := SecurityStats{SELinuxMode: "disabled", AppArmorEnabled: apparmor == "Y"}
+	switch enforce {
+	case "1":
+		stats.SELinuxMode = "enforcing"
+	case "0":
+		stats.SELinuxMode = "permissive"
+	}
+	reg.RegisterMetadata(getSecurityMetadataTemplate())
+	return stats, nil
+}
+
+func securityLatest(stats SecurityStats, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"selinux_mode":     {Timestamp: t, Value: stats.SELinuxMode},
+		"apparmor_enabled": {Timestamp: t, Value: strconv.FormatBool(stats.AppArmorEnabled)},
+	}
+}
+
+func getSecurityMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"selinux_mode": {
+			ID:       "selinux_mode",
+			Label:    "SELinux",
+			Priority: prioritySoftware + 2,
+			From:     "latest",
+		},
+		"apparmor_enabled": {
+			ID:       "apparmor_enabled",
+			Label:    "AppArmor Enabled",
+			Priority: prioritySoftware + 2,
+			From:     "latest",
+		},
+	}
+}
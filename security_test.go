@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetSecurityStats(t *testing.T) {
+	const (
+		selinux  = "fs/selinux/enforce"
+		apparmor = "module/apparmor/parameters/enabled"
+	)
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        SecurityStats
+		wantMissing bool
+	}{
+		{
+			name:  "selinux enforcing",
+			files: map[string]string{selinux: "1"},
+			want:  SecurityStats{SELinuxMode: "enforcing"},
+		},
+		{
+			name:  "selinux permissive",
+			files: map[string]string{selinux: "0"},
+			want:  SecurityStats{SELinuxMode: "permissive"},
+		},
+		{
+			name:  "apparmor enabled",
+			files: map[string]string{apparmor: "Y\n"},
+			want:  SecurityStats{SELinuxMode: "disabled", AppArmorEnabled: true},
+		},
+		{
+			name:  "apparmor disabled",
+			files: map[string]string{apparmor: "N\n"},
+			want:  SecurityStats{SELinuxMode: "disabled"},
+		},
+		{
+			name:        "neither",
+			files:       map[string]string{"kernel/.keep": ""},
+			wantMissing: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewTemplateRegistry()
+			got, err := getSecurityStats(context.Background(), writeTree(t, tt.files), reg)
+			if tt.wantMissing {
+				if !sysfsMissing(err) {
+					t.Fatalf("getSecurityStats error = %v, want a missing error", err)
+				}
+				if len(reg.MetadataTemplates()) != 0 {
+					t.Errorf("templates registered without security modules: %v", reg.MetadataTemplates())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("getSecurityStats = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SelfStats is the resource usage of the plugin's own cgroup, normally its
+// container, as opposed to the host stats.
+type SelfStats struct {
+	// CPUPercent is the CPU used since the previous sample, in percent of
+	// one core.
+	CPUPercent  float64
+	MemoryBytes uint64
+}
+
+// cgroupUsage is a raw sample of the plugin's cgroup counters.
+type cgroupUsage struct {
+	CPUUsec     uint64
+	MemoryBytes uint64
+}
+
+// selfSampler keeps the previous CPU usage so that a percentage can be
+// computed between samples.
+type selfSampler struct {
+	prev     cgroupUsage
+	prevTime time.Time
+	hasPrev  bool
+}
+
+// getSelfStats reads the usage of the plugin's cgroup, as listed in
+// <procRoot>/self/cgroup, from the cgroup filesystem at root. ok is false on
+// the first call, which only records the CPU usage.
+func (s *selfSampler) getSelfStats(ctx context.Context, procRoot, root string, reg *TemplateRegistry) (stats SelfStats, ok bool, err error) {
+	cgroups, err := readSelfCgroups(filepath.Join(procRoot, "self", "cgroup"))
+	if err != nil {
+		return SelfStats{}, false, &MetricError{Subsystem: "self", Err: err}
+	}
+	usage, err := readCgroupUsage(root, cgroups)
+	if err != nil {
+		return SelfStats{}, false, &MetricError{Subsystem: "self", Err: err}
+	}
+	now := time.Now()
+	prev, prevTime, hasPrev := s.prev, s.prevTime, s.hasPrev
+	s.prev, s.prevTime, s.hasPrev = usage, now, true
+
+	elapsed := now.Sub(prevTime).Seconds()
+	if !hasPrev || elapsed <= 0 || usage.CPUUsec < prev.CPUUsec {
+		return SelfStats{}, false, nil
+	}
+	reg.RegisterMetadata(getSelfMetadataTemplate())
+	return SelfStats{
+		CPUPercent:  float64(usage.CPUUsec-prev.CPUUsec) / 1e6 / elapsed * 100,
+		MemoryBytes: usage.MemoryBytes,
+	}, true, nil
+}
+
+// readSelfCgroups parses a /proc/<pid>/cgroup file, whose lines look like
+// "4:cpu,cpuacct:/docker/abc" for cgroup v1 and "0::/system.slice/x" for
+// cgroup v2. It maps each v1 controller, and "" for v2, to the cgroup path.
+func readSelfCgroups(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cgroups := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			cgroups[controller] = fields[2]
+		}
+	}
+	return cgroups, scanner.Err()
+}
+
+// readCgroupUsage reads cgroup v2 (cpu.stat and memory.current) or, failing
+// that, cgroup v1 (cpuacct/cpuacct.usage and memory/memory.usage_in_bytes)
+// of the cgroups listed by readSelfCgroups.
+func readCgroupUsage(root string, cgroups map[string]string) (cgroupUsage, error) {
+	unified := filepath.Join(root, cgroups[""])
+	if usec, err := readCPUStatUsage(filepath.Join(unified, "cpu.stat")); err == nil {
+		mem, err := readSysfsInt(filepath.Join(unified, "memory.current"))
+		if err != nil {
+			return cgroupUsage{}, err
+		}
+		return cgroupUsage{CPUUsec: usec, MemoryBytes: uint64(mem)}, nil
+	}
+
+	ns, err := readSysfsInt(filepath.Join(root, "cpuacct", cgroups["cpuacct"], "cpuacct.usage"))
+	if err != nil {
+		return cgroupUsage{}, err
+	}
+	mem, err := readSysfsInt(filepath.Join(root, "memory", cgroups["memory"], "memory.usage_in_bytes"))
+	if err != nil {
+		return cgroupUsage{}, err
+	}
+	return cgroupUsage{CPUUsec: uint64(ns) / 1000, MemoryBytes: uint64(mem)}, nil
+}
+
+// readCPUStatUsage returns usage_usec from a cgroup v2 cpu.stat file.
+func readCPUStatUsage(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no usage_usec in %s", path)
+}
+
+func selfLatest(stats SelfStats, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"self_cpu_percent":  {Timestamp: t, Value: formatPercent(stats.CPUPercent)},
+		"self_memory_bytes": {Timestamp: t, Value: formatNumber(float64(stats.MemoryBytes), 0)},
+	}
+}
+
+func getSelfMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"self_cpu_percent": {
+			ID:       "self_cpu_percent",
+			Label:    "Plugin CPU (% of a core)",
+			Datatype: "number",
+			Priority: prioritySelf,
+			From:     "latest",
+		},
+		"self_memory_bytes": {
+			ID:       "self_memory_bytes",
+			Label:    "Plugin Memory",
+			Datatype: "filesize",
+			Priority: prioritySelf,
+			From:     "latest",
+		},
+	}
+}
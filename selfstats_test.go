@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTree creates files, keyed by slash-separated path, under a temp dir.
+func writeTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestSelfCgroupUsage(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    cgroupUsage
+		wantErr bool
+	}{
+		{
+			name: "cgroup v2",
+			files: map[string]string{
+				"proc/self/cgroup":                            "0::/kubepods/pod1/cpuinfo\n",
+				"cgroup/kubepods/pod1/cpuinfo/cpu.stat":       "usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\n",
+				"cgroup/kubepods/pod1/cpuinfo/memory.current": "33554432\n",
+				// The root's counters cover the whole host.
+				"cgroup/cpu.stat":       "usage_usec 999999999\n",
+				"cgroup/memory.current": "999999999\n",
+			},
+			want: cgroupUsage{CPUUsec: 2500000, MemoryBytes: 32 << 20},
+		},
+		{
+			name: "cgroup v2 namespace",
+			files: map[string]string{
+				"proc/self/cgroup":      "0::/\n",
+				"cgroup/cpu.stat":       "usage_usec 1000\n",
+				"cgroup/memory.current": "4096\n",
+			},
+			want: cgroupUsage{CPUUsec: 1000, MemoryBytes: 4096},
+		},
+		{
+			name: "cgroup v1",
+			files: map[string]string{
+				"proc/self/cgroup":                               "12:memory:/docker/abc\n4:cpu,cpuacct:/docker/abc\n1:name=systemd:/docker/abc\n",
+				"cgroup/cpuacct/docker/abc/cpuacct.usage":        "3000000000\n",
+				"cgroup/memory/docker/abc/memory.usage_in_bytes": "16777216\n",
+				"cgroup/cpuacct/cpuacct.usage":                   "999999999999\n",
+			},
+			want: cgroupUsage{CPUUsec: 3000000, MemoryBytes: 16 << 20},
+		},
+		{
+			name: "cgroup not mounted",
+			files: map[string]string{
+				"proc/self/cgroup": "0::/system.slice/cpuinfo.service\n",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no proc",
+			files:   map[string]string{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeTree(t, tt.files)
+			procRoot, cgroupRoot := filepath.Join(root, "proc"), filepath.Join(root, "cgroup")
+
+			cgroups, err := readSelfCgroups(filepath.Join(procRoot, "self", "cgroup"))
+			var usage cgroupUsage
+			if err == nil {
+				usage, err = readCgroupUsage(cgroupRoot, cgroups)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if usage != tt.want {
+				t.Errorf("usage = %+v, want %+v", usage, tt.want)
+			}
+
+			// The first sample only records the usage.
+			var s selfSampler
+			_, ok, err := s.getSelfStats(context.Background(), procRoot, cgroupRoot, NewTemplateRegistry())
+			if (err != nil) != tt.wantErr || ok {
+				t.Errorf("getSelfStats = %v, %v on the first call", ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// selfTestResult is the outcome of one collector in /selftest: "ok",
+// "unavailable" when the host lacks the feature, or "error: ...".
+type selfTestResult struct {
+	Status  string `json:"status"`
+	Elapsed string `json:"elapsed"`
+}
+
+// selfTestCollectors returns the collectors run by /selftest. They use fresh
+// samplers and no registry, so that a self-test doesn't disturb the rates
+// and templates of the regular collections.
+func selfTestCollectors(cfg Config) map[string]func(ctx context.Context) error {
+	return map[string]func(ctx context.Context) error{
+		"cpu": func(ctx context.Context) error { _, err := getCPUStats(ctx, nil); return err },
+		"mem": func(ctx context.Context) error { _, err := getMemStats(ctx, nil); return err },
+		"cpu_usage": func(ctx context.Context) error {
+			_, _, err := (&cpuTimesSampler{}).getCPUUsageStats(ctx, nil)
+			return err
+		},
+		"swap": func(ctx context.Context) error {
+			_, _, err := (&swapSampler{}).getSwapRateStats(ctx, nil)
+			return err
+		},
+		"cache":         func(ctx context.Context) error { _, err := getCacheTopology(cfg.CPUSysfsPath, nil); return err },
+		"cpu_online":    func(ctx context.Context) error { _, err := getCPUOnlineStats(ctx, cfg.CPUSysfsPath, nil); return err },
+		"cpu_base_freq": func(ctx context.Context) error { _, err := getCPUBaseMhz(cfg.CPUSysfsPath, nil); return err },
+		"cpu_governor":  func(ctx context.Context) error { _, err := getCPUGovernor(cfg.CPUSysfsPath, nil); return err },
+		"cpu_vulnerabilities": func(ctx context.Context) error {
+			_, err := getVulnerabilityStats(ctx, cfg.CPUSysfsPath, nil)
+			return err
+		},
+		"smt":        func(ctx context.Context) error { _, err := getSMTEnabled(ctx, cfg.CPUSysfsPath, nil); return err },
+		"mem_commit": func(ctx context.Context) error { _, err := getCommitStats(ctx, cfg.ProcPath, 1, nil); return err },
+		"ecc":        func(ctx context.Context) error { _, err := getECCStats(ctx, cfg.EDACPath, nil); return err },
+		"numa":       func(ctx context.Context) error { _, err := getNUMAMemStats(ctx, cfg.NUMANodePath, nil); return err },
+		"vm_sysctl": func(ctx context.Context) error {
+			_, err := getVMSysctlStats(ctx, filepath.Join(cfg.ProcPath, "sys"), nil)
+			return err
+		},
+		"core_types": func(ctx context.Context) error {
+			_, err := getCoreTypeStats(ctx, cfg.CPUSysfsPath, cfg.CoreTypeSource, nil)
+			return err
+		},
+		"cgroup":   func(ctx context.Context) error { _, err := getCgroupStats(ctx, cfg.CgroupPath, nil); return err },
+		"security": func(ctx context.Context) error { _, err := getSecurityStats(ctx, defaultSysPath, nil); return err },
+		"systemd": func(ctx context.Context) error {
+			_, ok, err := getFailedUnits(ctx, defaultSystemdRunDir, systemctlFailedCommand, nil)
+			return unavailableUnless(ok, err)
+		},
+		"load": func(ctx context.Context) error { _, err := getLoadStats(ctx, nil); return err },
+		"net":  func(ctx context.Context) error { _, err := (&netSampler{}).getNetStats(ctx, nil); return err },
+		"tcp": func(ctx context.Context) error {
+			_, _, err := (&tcpSampler{}).getTCPRetransmitRate(ctx, cfg.ProcPath, nil)
+			return err
+		},
+		"ip_addresses": func(ctx context.Context) error { _, err := getInterfaceStats(ctx, ifaceFilter{}, nil); return err },
+		"routes": func(ctx context.Context) error {
+			_, err := getDefaultRouteStats(ctx, cfg.IPv4RoutePath, cfg.IPv6RoutePath, nil)
+			return err
+		},
+		"diskio": func(ctx context.Context) error { _, err := (&diskIOSampler{}).getDiskIOStats(ctx, nil); return err },
+		"inodes": func(ctx context.Context) error {
+			_, ok, err := getRootInodeStats(ctx, nil)
+			return unavailableUnless(ok, err)
+		},
+		"mounts": func(ctx context.Context) error { _, err := getMountStats(ctx, cfg.DiskIncludeVirtual, nil); return err },
+		"k8s_limits": func(ctx context.Context) error {
+			if len(cfg.K8sLimitFiles) == 0 {
+				return os.ErrNotExist
+			}
+			_, err := getK8sLimitStats(ctx, cfg.K8sLimitFiles, nil)
+			return err
+		},
+		"processes": func(ctx context.Context) error {
+			_, _, err := (&ProcessCollector{N: cfg.TopNProcesses, TopCPU: cfg.TopCPUProcess, TopMem: cfg.TopMemProcess, States: cfg.ProcessStates, source: &gopsutilProcessSource{withStatus: cfg.ProcessStates}}).Collect(ctx)
+			return err
+		},
+		"psu":    func(ctx context.Context) error { _, err := getPSUStats(ctx, defaultPowerSupplyPath, nil); return err },
+		"cstate": func(ctx context.Context) error { _, err := getCStateStats(ctx, cfg.CPUSysfsPath, nil); return err },
+		"self": func(ctx context.Context) error {
+			_, _, err := (&selfSampler{}).getSelfStats(ctx, cfg.ProcPath, cfg.CgroupPath, nil)
+			return err
+		},
+		"gpu": func(ctx context.Context) error {
+			_, ok, err := getGPUStats(ctx, nvidiaSMICommand, cfg.GPUTimeout, nil)
+			return unavailableUnless(ok, err)
+		},
+		"mem_bandwidth": func(ctx context.Context) error {
+			_, ok, err := getMemBandwidthStats(ctx, defaultMemBandwidthReader(), nil)
+			return unavailableUnless(ok, err)
+		},
+	}
+}
+
+// unavailableUnless maps the "not available on this host" result of the
+// collectors returning ok to os.ErrNotExist.
+func unavailableUnless(ok bool, err error) error {
+	if err == nil && !ok {
+		return os.ErrNotExist
+	}
+	return err
+}
+
+// runSelfTest runs every collector once and reports how each fared.
+func runSelfTest(ctx context.Context, cfg Config) map[string]selfTestResult {
+	results := map[string]selfTestResult{}
+	for name, collect := range selfTestCollectors(cfg) {
+		start := time.Now()
+		err := collect(ctx)
+		result := selfTestResult{Status: "ok", Elapsed: time.Since(start).String()}
+		switch {
+		case err != nil && sysfsMissing(err):
+			result.Status = "unavailable"
+		case err != nil:
+			result.Status = "error: " + err.Error()
+		}
+		results[name] = result
+	}
+	return results
+}
+
+// SelfTest serves /selftest, which runs every collector and reports which
+// work on this host. It answers 403 unless debug endpoints are enabled.
+func (p *Plugin) SelfTest(w http.ResponseWriter, r *http.Request) {
+	p.lock.Lock()
+	cfg := p.cfg
+	p.lock.Unlock()
+
+	if !cfg.DebugEndpoints {
+		http.Error(w, "debug endpoints are disabled", http.StatusForbidden)
+		return
+	}
+	raw, err := marshalResponse(runSelfTest(r.Context(), cfg), cfg.Pretty)
+	if err != nil {
+		errorf("%v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(raw)
+}
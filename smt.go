@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// getSMTEnabled reports whether simultaneous multithreading (hyperthreading)
+// is active. It reads <cpuRoot>/smt/active, and falls back to comparing the
+// physical and logical core counts on kernels without it.
+func getSMTEnabled(ctx context.Context, cpuRoot string, reg *TemplateRegistry) (bool, error) {
+	enabled, err := readSMTActive(cpuRoot)
+	if err != nil {
+		if !sysfsMissing(err) {
+			return false, &MetricError{Subsystem: "smt", Err: err}
+		}
+		physical, err := cpu.CountsWithContext(ctx, false)
+		if err != nil {
+			return false, &MetricError{Subsystem: "smt", Err: err}
+		}
+		logical, err := cpu.CountsWithContext(ctx, true)
+		if err != nil {
+			return false, &MetricError{Subsystem: "smt", Err: err}
+		}
+		enabled = smtFromCounts(physical, logical)
+	}
+	reg.RegisterMetadata(getSMTMetadataTemplate())
+	return enabled, nil
+}
+
+func readSMTActive(cpuRoot string) (bool, error) {
+	s, err := readSysfsString(filepath.Join(cpuRoot, "smt", "active"))
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(s)
+}
+
+func smtFromCounts(physical, logical int) bool {
+	return physical > 0 && logical > physical
+}
+
+func smtLatest(enabled bool, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"smt_enabled": {Timestamp: t, Value: strconv.FormatBool(enabled)},
+	}
+}
+
+func getSMTMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"smt_enabled": {
+			ID:       "smt_enabled",
+			Label:    "SMT Enabled",
+			Priority: priorityHardware + 0.5,
+			From:     "latest",
+		},
+	}
+}
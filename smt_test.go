@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetSMTEnabledFromSysfs(t *testing.T) {
+	tests := []struct {
+		name    string
+		active  string
+		want    bool
+		wantErr bool
+	}{
+		{name: "active", active: "1\n", want: true},
+		{name: "inactive", active: "0\n", want: false},
+		{name: "garbage", active: "maybe\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeTree(t, map[string]string{"smt/active": tt.active})
+			got, err := getSMTEnabled(context.Background(), root, nil)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("getSMTEnabled = %v, %v, want %v, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetSMTEnabledFallback(t *testing.T) {
+	// Without smt/active the counts decide; whatever they say, it's no error.
+	if _, err := getSMTEnabled(context.Background(), t.TempDir(), nil); err != nil {
+		t.Errorf("getSMTEnabled without sysfs: %v", err)
+	}
+
+	tests := []struct {
+		physical, logical int
+		want              bool
+	}{
+		{physical: 4, logical: 8, want: true},
+		{physical: 4, logical: 4, want: false},
+		{physical: 0, logical: 8, want: false},
+	}
+	for _, tt := range tests {
+		if got := smtFromCounts(tt.physical, tt.logical); got != tt.want {
+			t.Errorf("smtFromCounts(%d, %d) = %v, want %v", tt.physical, tt.logical, got, tt.want)
+		}
+	}
+}
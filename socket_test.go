@@ -0,0 +1,154 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSetupSocketWithRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		// unblockAfter removes the file standing in the way of the socket
+		// directory's parent after this long, never when zero.
+		unblockAfter time.Duration
+		wantErr      bool
+	}{
+		{name: "creatable later", timeout: 5 * time.Second, unblockAfter: 100 * time.Millisecond},
+		{name: "timeout", timeout: 300 * time.Millisecond, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := filepath.Join(t.TempDir(), "run")
+			if err := ioutil.WriteFile(run, nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if tt.unblockAfter > 0 {
+				time.AfterFunc(tt.unblockAfter, func() { os.Remove(run) })
+			}
+
+			socketPath := filepath.Join(run, "scope", "plugin.sock")
+			listener, err := setupSocketWithRetry(socketPath, "", tt.timeout)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setupSocketWithRetry error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer listener.Close()
+			if _, err := os.Stat(socketPath); err != nil {
+				t.Errorf("socket not created: %v", err)
+			}
+		})
+	}
+}
+
+func TestSymlinkedSocketDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no Unix sockets in symlinked directories on Windows")
+	}
+	tests := []struct {
+		name    string
+		symlink bool
+		// wantDir tells whether the socket directory survives cleanup.
+		wantDir bool
+	}{
+		{name: "symlink", symlink: true, wantDir: true},
+		{name: "plain", symlink: false, wantDir: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			target := filepath.Join(root, "target")
+			sentinel := filepath.Join(target, "keep")
+			dir := filepath.Join(root, "sock")
+			if tt.symlink {
+				if err := os.Mkdir(target, 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(sentinel, []byte("operator data"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Symlink(target, dir); err != nil {
+					t.Fatal(err)
+				}
+			}
+			socketPath := filepath.Join(dir, "p.sock")
+
+			listener, err := setupSocket(socketPath, "")
+			if err != nil {
+				t.Fatalf("setupSocket: %v", err)
+			}
+			listener.Close()
+			cleanupSocket(socketPath)
+
+			if _, err := os.Lstat(dir); (err == nil) != tt.wantDir {
+				t.Errorf("socket directory after cleanup: %v, want kept %v", err, tt.wantDir)
+			}
+			if !tt.symlink {
+				return
+			}
+			if fi, err := os.Lstat(dir); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+				t.Errorf("symlink replaced or removed: %v", err)
+			}
+			if raw, err := ioutil.ReadFile(sentinel); err != nil || string(raw) != "operator data" {
+				t.Errorf("symlink target wiped: %q, %v", raw, err)
+			}
+		})
+	}
+}
+
+func TestSocketPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no Unix permissions on Windows")
+	}
+	// The current user's primary group can always be given the socket.
+	var ownGroup string
+	if u, err := user.Current(); err == nil {
+		if g, err := user.LookupGroupId(u.Gid); err == nil {
+			ownGroup = g.Name
+		}
+	}
+	tests := []struct {
+		name     string
+		group    string
+		wantSock os.FileMode
+		wantDir  os.FileMode
+		wantErr  bool
+	}{
+		{name: "owner", wantSock: 0600, wantDir: 0700},
+		{name: "group", group: ownGroup, wantSock: 0660, wantDir: 0750},
+		{name: "nogroup", group: "cpuinfo-no-such-group", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.name == "group" && ownGroup == "" {
+				t.Skip("can't resolve the current user's group")
+			}
+			socketPath := filepath.Join(t.TempDir(), "s", "p.sock")
+			listener, err := setupSocket(socketPath, tt.group)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setupSocket error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer listener.Close()
+
+			for path, want := range map[string]os.FileMode{socketPath: tt.wantSock, filepath.Dir(socketPath): tt.wantDir} {
+				fi, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := fi.Mode().Perm(); got != want {
+					t.Errorf("%s mode = %v, want %v", filepath.Base(path), got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+// pluginState holds the previous samples of the rate-based collectors, so
+// that rates can be computed right away after a restart.
+type pluginState struct {
+	Time     time.Time                       `json:"time"`
+	Net      map[string]psnet.IOCountersStat `json:"net,omitempty"`
+	DiskIO   map[string]disk.IOCountersStat  `json:"disk_io,omitempty"`
+	CPUTimes *cpu.TimesStat                  `json:"cpu_times,omitempty"`
+	TCP      *TCPSNMPStats                   `json:"tcp,omitempty"`
+	Swap     *SwapCounters                   `json:"swap,omitempty"`
+}
+
+// loadState restores the samplers from the state file at path. A state
+// older than twice the refresh interval is ignored, since rates spanning the
+// downtime would be misleading. A missing file is not an error.
+func (p *Plugin) loadState(path string) error {
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state %q: %v", path, err)
+	}
+	var state pluginState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return fmt.Errorf("failed to parse state %q: %v", path, err)
+	}
+	if age := time.Since(state.Time); age > 2*p.config().RefreshInterval {
+		debugf("ignoring state %q from %s ago", path, age)
+		return nil
+	}
+
+	if state.Net != nil {
+		p.net.prev, p.net.prevTime = state.Net, state.Time
+	}
+	if state.DiskIO != nil {
+		p.diskIO.prev, p.diskIO.prevTime = state.DiskIO, state.Time
+	}
+	if state.CPUTimes != nil {
+		p.cpuTimes.prev, p.cpuTimes.hasPrev = *state.CPUTimes, true
+	}
+	if state.TCP != nil {
+		p.tcp.prev, p.tcp.prevTime, p.tcp.hasPrev = *state.TCP, state.Time, true
+	}
+	if state.Swap != nil {
+		p.swap.prev, p.swap.prevTime, p.swap.hasPrev = *state.Swap, state.Time, true
+	}
+	return nil
+}
+
+// saveState atomically writes the samplers' last samples to path.
+func (p *Plugin) saveState(path string) error {
+	state := pluginState{
+		Time:   time.Now(),
+		Net:    p.net.prev,
+		DiskIO: p.diskIO.prev,
+	}
+	if p.cpuTimes.hasPrev {
+		state.CPUTimes = &p.cpuTimes.prev
+	}
+	if p.tcp.hasPrev {
+		state.TCP = &p.tcp.prev
+	}
+	if p.swap.hasPrev {
+		state.Swap = &p.swap.prev
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write state %q: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write state %q: %v", path, err)
+	}
+	return nil
+}
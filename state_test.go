@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	saved := NewPlugin("host", loadConfig())
+	saved.net.update([]psnet.IOCountersStat{{Name: "eth0", BytesRecv: 1000}}, time.Now())
+	saved.cpuTimes.update(cpu.TimesStat{User: 10, Idle: 90})
+	if err := saved.saveState(path); err != nil {
+		t.Fatalf("saveState: %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	restored := NewPlugin("host", loadConfig())
+	if err := restored.loadState(path); err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	if got := restored.net.prev["eth0"].BytesRecv; got != 1000 {
+		t.Errorf("restored eth0 bytes = %d, want 1000", got)
+	}
+	// The restored samplers compute rates on the first sample.
+	if _, ok := restored.cpuTimes.update(cpu.TimesStat{User: 20, Idle: 180}); !ok {
+		t.Error("no CPU usage from the first sample after a restart")
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	fresh := time.Now().UTC().Format(time.RFC3339Nano)
+	stale := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano)
+	tests := []struct {
+		name        string
+		state       string // empty for no file
+		wantErr     bool
+		wantRestore bool
+	}{
+		{name: "no file"},
+		{name: "fresh", state: `{"time": "` + fresh + `", "cpu_times": {"user": 1}}`, wantRestore: true},
+		{name: "stale", state: `{"time": "` + stale + `", "cpu_times": {"user": 1}}`},
+		{name: "corrupt", state: `{"time":`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			if tt.state != "" {
+				if err := ioutil.WriteFile(path, []byte(tt.state), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg := loadConfig()
+			cfg.RefreshInterval = 15 * time.Second
+			p := NewPlugin("host", cfg)
+			if err := p.loadState(path); (err != nil) != tt.wantErr {
+				t.Fatalf("loadState error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if p.cpuTimes.hasPrev != tt.wantRestore {
+				t.Errorf("CPU times restored = %v, want %v", p.cpuTimes.hasPrev, tt.wantRestore)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// deltaIndicatorKey marks a host node holding only the Latest entries that
+// changed since the previous line.
+const deltaIndicatorKey = "report_delta"
+
+// deltaRemovedKey lists, comma-separated, the Latest keys of a delta node
+// that were dropped since the previous line. A node that disappeared is
+// printed with all its keys there.
+const deltaRemovedKey = "report_delta_removed"
+
+// deltaEncoder strips host nodes down to the Latest entries whose value
+// changed since the last nodes it saw, per node ID. A node seen for the
+// first time is kept whole, as its baseline.
+type deltaEncoder struct {
+	last map[string]map[string]string
+}
+
+func (e *deltaEncoder) encode(nodes map[string]node, t time.Time) map[string]node {
+	if e.last == nil {
+		e.last = map[string]map[string]string{}
+	}
+	encoded := make(map[string]node, len(nodes))
+	for id, n := range nodes {
+		current := make(map[string]string, len(n.Latest))
+		for k, v := range n.Latest {
+			current[k] = v.Value
+		}
+		last, seen := e.last[id]
+		e.last[id] = current
+		if !seen {
+			encoded[id] = n
+			continue
+		}
+
+		changed := map[string]stringEntry{}
+		for k, v := range n.Latest {
+			if prev, ok := last[k]; !ok || prev != v.Value {
+				changed[k] = v
+			}
+		}
+		var removed []string
+		for k := range last {
+			if _, ok := current[k]; !ok {
+				removed = append(removed, k)
+			}
+		}
+		n.Latest = markDelta(changed, removed, t)
+		encoded[id] = n
+	}
+	for id, last := range e.last {
+		if _, ok := nodes[id]; ok {
+			continue
+		}
+		removed := make([]string, 0, len(last))
+		for k := range last {
+			removed = append(removed, k)
+		}
+		encoded[id] = node{Latest: markDelta(map[string]stringEntry{}, removed, t)}
+		delete(e.last, id)
+	}
+	return encoded
+}
+
+// markDelta adds the delta indicator, and the removed keys if any, to the
+// changed entries.
+func markDelta(changed map[string]stringEntry, removed []string, t time.Time) map[string]stringEntry {
+	changed[deltaIndicatorKey] = stringEntry{Timestamp: t, Value: "true"}
+	if len(removed) > 0 {
+		sort.Strings(removed)
+		changed[deltaRemovedKey] = stringEntry{Timestamp: t, Value: strings.Join(removed, ",")}
+	}
+	return changed
+}
+
+// runStdoutReporter writes the report to w as one JSON line every interval,
+// for deployments that collect logs rather than query the socket.
+// With deltas, lines after the first only carry the changed Latest entries.
+func (p *Plugin) runStdoutReporter(w io.Writer, interval time.Duration, deltas bool, stop <-chan struct{}) {
+	var enc *deltaEncoder
+	if deltas {
+		enc = &deltaEncoder{}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := p.writeReportLine(w, enc); err != nil {
+			errorf("%v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Plugin) writeReportLine(w io.Writer, enc *deltaEncoder) error {
+	rpt, err := p.makeReport(context.Background())
+	if err != nil {
+		return err
+	}
+	if enc != nil {
+		rpt.Host.Nodes = enc.encode(rpt.Host.Nodes, p.now())
+	}
+	raw, err := json.Marshal(*rpt)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(raw, '\n'))
+	return err
+}
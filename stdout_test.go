@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// latestNode builds a node from Latest key-value pairs.
+func latestNode(kv map[string]string) node {
+	n := node{Latest: map[string]stringEntry{}}
+	for k, v := range kv {
+		n.Latest[k] = stringEntry{Value: v}
+	}
+	return n
+}
+
+func TestDeltaEncoder(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		lines []map[string]map[string]string // node ID to Latest, per line
+		want  map[string]map[string]string   // encoded last line
+	}{
+		{
+			name: "baseline is whole",
+			lines: []map[string]map[string]string{
+				{"a": {"cpu_model": "Xeon", "cpu_user_percent": "10.0"}},
+			},
+			want: map[string]map[string]string{
+				"a": {"cpu_model": "Xeon", "cpu_user_percent": "10.0"},
+			},
+		},
+		{
+			name: "only changed keys",
+			lines: []map[string]map[string]string{
+				{"a": {"cpu_model": "Xeon", "cpu_user_percent": "10.0", "load_1": "0.50"}},
+				{"a": {"cpu_model": "Xeon", "cpu_user_percent": "12.5", "load_1": "0.50"}},
+			},
+			want: map[string]map[string]string{
+				"a": {"cpu_user_percent": "12.5", "report_delta": "true"},
+			},
+		},
+		{
+			name: "per node",
+			lines: []map[string]map[string]string{
+				{"a": {"cpu_model": "Xeon"}, "b": {"cpu_model": "EPYC"}},
+				{"a": {"cpu_model": "Xeon"}, "b": {"cpu_model": "EPYC"}},
+			},
+			want: map[string]map[string]string{
+				"a": {"report_delta": "true"},
+				"b": {"report_delta": "true"},
+			},
+		},
+		{
+			name: "new node is a baseline",
+			lines: []map[string]map[string]string{
+				{"a": {"cpu_model": "Xeon"}},
+				{"a": {"cpu_model": "Xeon"}, "b": {"cpu_model": "EPYC"}},
+			},
+			want: map[string]map[string]string{
+				"a": {"report_delta": "true"},
+				"b": {"cpu_model": "EPYC"},
+			},
+		},
+		{
+			name: "removed keys",
+			lines: []map[string]map[string]string{
+				{"a": {"cpu_model": "Xeon", "gpu_count": "2", "cpu_mhz": "2400"}},
+				{"a": {"cpu_model": "Xeon"}},
+			},
+			want: map[string]map[string]string{
+				"a": {"report_delta": "true", "report_delta_removed": "cpu_mhz,gpu_count"},
+			},
+		},
+		{
+			name: "removed node",
+			lines: []map[string]map[string]string{
+				{"a": {"cpu_model": "Xeon"}, "b": {"cpu_model": "EPYC", "load_1": "1.00"}},
+				{"a": {"cpu_model": "Xeon"}},
+			},
+			want: map[string]map[string]string{
+				"a": {"report_delta": "true"},
+				"b": {"report_delta": "true", "report_delta_removed": "cpu_model,load_1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var enc deltaEncoder
+			var encoded map[string]node
+			for _, line := range tt.lines {
+				nodes := map[string]node{}
+				for id, kv := range line {
+					nodes[id] = latestNode(kv)
+				}
+				encoded = enc.encode(nodes, now)
+			}
+			got := map[string]map[string]string{}
+			for id, n := range encoded {
+				got[id] = map[string]string{}
+				for k, v := range n.Latest {
+					got[id][k] = v.Value
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encoded = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStdoutReporterWritesJSONLines(t *testing.T) {
+	for _, deltas := range []bool{false, true} {
+		cfg := loadConfig()
+		cfg.CollectMode = collectBackground
+		p := NewPlugin("host", cfg)
+		now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+		p.now = func() time.Time { return now }
+		p.last = goldenCollection(p, now)
+
+		var out bytes.Buffer
+		stop := make(chan struct{})
+		close(stop)
+		p.runStdoutReporter(&out, time.Hour, deltas, stop)
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(lines) != 1 {
+			t.Fatalf("deltas=%v: got %d lines, want 1:\n%s", deltas, len(lines), out.String())
+		}
+		if err := validateReport([]byte(lines[0])); err != nil {
+			t.Errorf("deltas=%v: invalid report line: %v", deltas, err)
+		}
+	}
+}
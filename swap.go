@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// SwapCounters holds the cumulative bytes swapped in and out.
+type SwapCounters struct {
+	InBytes  uint64 `json:"in_bytes"`
+	OutBytes uint64 `json:"out_bytes"`
+}
+
+// SwapRateStats holds the swap rates between two samples. Sustained swap-in
+// points at memory thrashing.
+type SwapRateStats struct {
+	InBytesPerSec  float64
+	OutBytesPerSec float64
+}
+
+// swapSampler keeps the previous swap counters so that rates can be computed
+// between samples.
+type swapSampler struct {
+	prev     SwapCounters
+	prevTime time.Time
+	hasPrev  bool
+}
+
+// getSwapRateStats returns the bytes swapped in and out per second since the
+// previous call. ok is false on the first call and after a counter went
+// backwards.
+func (s *swapSampler) getSwapRateStats(ctx context.Context, reg *TemplateRegistry) (SwapRateStats, bool, error) {
+	swap, err := mem.SwapMemoryWithContext(ctx)
+	if err != nil {
+		return SwapRateStats{}, false, &MetricError{Subsystem: "swap", Err: err}
+	}
+	stats, ok := s.update(SwapCounters{InBytes: swap.Sin, OutBytes: swap.Sout}, time.Now())
+	if ok {
+		reg.RegisterMetadata(getSwapMetadataTemplate())
+	}
+	return stats, ok, nil
+}
+
+func (s *swapSampler) update(c SwapCounters, now time.Time) (SwapRateStats, bool) {
+	prev, prevTime, hasPrev := s.prev, s.prevTime, s.hasPrev
+	s.prev, s.prevTime, s.hasPrev = c, now, true
+
+	elapsed := now.Sub(prevTime).Seconds()
+	if !hasPrev || elapsed <= 0 || c.InBytes < prev.InBytes || c.OutBytes < prev.OutBytes {
+		return SwapRateStats{}, false
+	}
+	return SwapRateStats{
+		InBytesPerSec:  float64(c.InBytes-prev.InBytes) / elapsed,
+		OutBytesPerSec: float64(c.OutBytes-prev.OutBytes) / elapsed,
+	}, true
+}
+
+func swapLatest(stats SwapRateStats, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"swap_in_bytes_sec":  {Timestamp: t, Value: formatNumber(stats.InBytesPerSec, 0)},
+		"swap_out_bytes_sec": {Timestamp: t, Value: formatNumber(stats.OutBytesPerSec, 0)},
+	}
+}
+
+func getSwapMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"swap_in_bytes_sec": {
+			ID:       "swap_in_bytes_sec",
+			Label:    "Swap In (B/s)",
+			Datatype: "number",
+			Priority: priorityUtilization + 0.3,
+			From:     "latest",
+		},
+		"swap_out_bytes_sec": {
+			ID:       "swap_out_bytes_sec",
+			Label:    "Swap Out (B/s)",
+			Datatype: "number",
+			Priority: priorityUtilization + 0.3,
+			From:     "latest",
+		},
+	}
+}
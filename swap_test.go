@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSwapSamplerUpdate(t *testing.T) {
+	t0 := time.Unix(1646136000, 0)
+	type snapshot struct {
+		at       time.Time
+		counters SwapCounters
+	}
+	tests := []struct {
+		name      string
+		snapshots []snapshot
+		want      SwapRateStats
+		wantOK    bool
+	}{
+		{
+			name:      "first snapshot",
+			snapshots: []snapshot{{t0, SwapCounters{InBytes: 4096, OutBytes: 8192}}},
+		},
+		{
+			name: "thrashing",
+			snapshots: []snapshot{
+				{t0, SwapCounters{InBytes: 1 << 20, OutBytes: 2 << 20}},
+				{t0.Add(15 * time.Second), SwapCounters{InBytes: 1<<20 + 15*4096, OutBytes: 2<<20 + 15*1024}},
+			},
+			want:   SwapRateStats{InBytesPerSec: 4096, OutBytesPerSec: 1024},
+			wantOK: true,
+		},
+		{
+			name: "idle",
+			snapshots: []snapshot{
+				{t0, SwapCounters{InBytes: 4096, OutBytes: 8192}},
+				{t0.Add(15 * time.Second), SwapCounters{InBytes: 4096, OutBytes: 8192}},
+			},
+			wantOK: true,
+		},
+		{
+			name: "swapoff resets the counters",
+			snapshots: []snapshot{
+				{t0, SwapCounters{InBytes: 1 << 20, OutBytes: 2 << 20}},
+				{t0.Add(15 * time.Second), SwapCounters{InBytes: 0, OutBytes: 2 << 20}},
+			},
+		},
+		{
+			name: "clock went backwards",
+			snapshots: []snapshot{
+				{t0, SwapCounters{InBytes: 4096}},
+				{t0.Add(-time.Second), SwapCounters{InBytes: 8192}},
+			},
+		},
+		{
+			name: "rate after a reset",
+			snapshots: []snapshot{
+				{t0, SwapCounters{InBytes: 1 << 20}},
+				{t0.Add(10 * time.Second), SwapCounters{InBytes: 0}},
+				{t0.Add(20 * time.Second), SwapCounters{InBytes: 10 * 512}},
+			},
+			want:   SwapRateStats{InBytesPerSec: 512},
+			wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s swapSampler
+			var got SwapRateStats
+			var ok bool
+			for _, snap := range tt.snapshots {
+				got, ok = s.update(snap.counters, snap.at)
+			}
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("update = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSwapLatest(t *testing.T) {
+	latest := swapLatest(SwapRateStats{InBytesPerSec: 4096.4, OutBytesPerSec: 1023.6}, time.Time{})
+	want := map[string]string{"swap_in_bytes_sec": "4096", "swap_out_bytes_sec": "1024"}
+	for k, v := range want {
+		if got := latest[k].Value; got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
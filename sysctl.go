@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// VMSysctlStats holds the kernel memory tuning parameters.
+type VMSysctlStats struct {
+	Swappiness int
+	// Overcommit is vm.overcommit_memory: 0 heuristic, 1 always, 2 never.
+	Overcommit int
+}
+
+// getVMSysctlStats reads vm.swappiness and vm.overcommit_memory under
+// sysctlRoot, normally /proc/sys.
+func getVMSysctlStats(ctx context.Context, sysctlRoot string, reg *TemplateRegistry) (VMSysctlStats, error) {
+	var stats VMSysctlStats
+	var err error
+	if stats.Swappiness, err = readSysctlInt(sysctlRoot, "vm/swappiness"); err != nil {
+		return VMSysctlStats{}, &MetricError{Subsystem: "vm_sysctl", Err: err}
+	}
+	if stats.Overcommit, err = readSysctlInt(sysctlRoot, "vm/overcommit_memory"); err != nil {
+		return VMSysctlStats{}, &MetricError{Subsystem: "vm_sysctl", Err: err}
+	}
+	reg.RegisterMetadata(getVMSysctlMetadataTemplate())
+	return stats, nil
+}
+
+func readSysctlInt(sysctlRoot, name string) (int, error) {
+	s, err := readSysfsString(filepath.Join(sysctlRoot, filepath.FromSlash(name)))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(s)
+}
+
+func vmSysctlLatest(stats VMSysctlStats, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"vm_swappiness": {Timestamp: t, Value: formatNumber(float64(stats.Swappiness), 0)},
+		"vm_overcommit": {Timestamp: t, Value: formatNumber(float64(stats.Overcommit), 0)},
+	}
+}
+
+func getVMSysctlMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"vm_swappiness": {
+			ID:       "vm_swappiness",
+			Label:    "VM Swappiness",
+			Datatype: "integer",
+			Priority: prioritySoftware + 0.5,
+			From:     "latest",
+		},
+		"vm_overcommit": {
+			ID:       "vm_overcommit",
+			Label:    "VM Overcommit Mode",
+			Datatype: "integer",
+			Priority: prioritySoftware + 0.5,
+			From:     "latest",
+		},
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetVMSysctlStats(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        VMSysctlStats
+		wantErr     bool
+		wantMissing bool
+	}{
+		{
+			name:  "defaults",
+			files: map[string]string{"vm/swappiness": "60\n", "vm/overcommit_memory": "0\n"},
+			want:  VMSysctlStats{Swappiness: 60, Overcommit: 0},
+		},
+		{
+			name:  "tuned database host",
+			files: map[string]string{"vm/swappiness": "1\n", "vm/overcommit_memory": "2\n"},
+			want:  VMSysctlStats{Swappiness: 1, Overcommit: 2},
+		},
+		{
+			name:        "no overcommit file",
+			files:       map[string]string{"vm/swappiness": "60\n"},
+			wantErr:     true,
+			wantMissing: true,
+		},
+		{
+			name:    "garbage",
+			files:   map[string]string{"vm/swappiness": "lots\n", "vm/overcommit_memory": "0\n"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getVMSysctlStats(context.Background(), writeTree(t, tt.files), nil)
+			if (err != nil) != tt.wantErr || sysfsMissing(err) != tt.wantMissing {
+				t.Fatalf("getVMSysctlStats error = %v, wantErr %v, want missing %v", err, tt.wantErr, tt.wantMissing)
+			}
+			if got != tt.want {
+				t.Errorf("getVMSysctlStats = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
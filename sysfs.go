@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const defaultCPUSysfsPath = "/sys/devices/system/cpu"
+
+// readSysfsString reads a single-value sysfs or procfs file, trimming the
+// trailing newline.
+func readSysfsString(path string) (string, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// sysfsMissing reports whether err means the sysfs entry doesn't exist, as on
+// non-Linux hosts or kernels without the feature. Collectors skip silently in
+// that case instead of reporting themselves as degraded.
+func sysfsMissing(err error) bool {
+	return errors.Is(err, os.ErrNotExist)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	failedUnitsTablePrefix = "cpuinfo-failed-units-"
+	systemdTimeout         = 2 * time.Second
+	// defaultSystemdRunDir exists on hosts booted with systemd, as tested
+	// by sd_booted(3).
+	defaultSystemdRunDir = "/run/systemd/system"
+)
+
+// systemctlFailedCommand lists the failed units, one per line as
+// "<unit> <load> <active> <sub> <description>".
+var systemctlFailedCommand = []string{"systemctl", "--failed", "--no-legend", "--plain", "--no-pager"}
+
+// FailedUnit is a systemd unit in the failed state.
+type FailedUnit struct {
+	Name        string
+	Description string
+}
+
+// getFailedUnits runs command to list the failed systemd units. ok is false
+// when the host doesn't run systemd, i.e. runDir doesn't exist or command
+// isn't installed.
+func getFailedUnits(ctx context.Context, runDir string, command []string, reg *TemplateRegistry) (units []FailedUnit, ok bool, err error) {
+	if _, err := os.Stat(runDir); err != nil {
+		return nil, false, nil
+	}
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return nil, false, nil
+	}
+	out, err := runCommand(ctx, systemdTimeout, path, command[1:]...)
+	if err != nil {
+		return nil, false, &MetricError{Subsystem: "systemd", Err: err}
+	}
+	reg.RegisterMetadata(getFailedUnitsMetadataTemplate())
+	reg.RegisterTables(getFailedUnitsTableTemplate())
+	return parseFailedUnits(string(out)), true, nil
+}
+
+func parseFailedUnits(out string) []FailedUnit {
+	var units []FailedUnit
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		unit := FailedUnit{Name: fields[0]}
+		if len(fields) > 4 {
+			unit.Description = strings.Join(fields[4:], " ")
+		}
+		units = append(units, unit)
+	}
+	return units
+}
+
+func failedUnitsLatest(units []FailedUnit, t time.Time) map[string]stringEntry {
+	latest := map[string]stringEntry{
+		"failed_units": {Timestamp: t, Value: formatNumber(float64(len(units)), 0)},
+	}
+	for _, unit := range units {
+		latest[failedUnitsTablePrefix+unit.Name] = stringEntry{Timestamp: t, Value: unit.Description}
+	}
+	return latest
+}
+
+func getFailedUnitsMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"failed_units": {
+			ID:       "failed_units",
+			Label:    "Failed Units",
+			Datatype: "integer",
+			Priority: prioritySoftware + 2,
+			From:     "latest",
+		},
+	}
+}
+
+func getFailedUnitsTableTemplate() map[string]tableTemplate {
+	return map[string]tableTemplate{
+		"cpuinfo-failed-units": {
+			ID:     "cpuinfo-failed-units",
+			Label:  "Failed Units",
+			Prefix: failedUnitsTablePrefix,
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+const sampleFailedUnits = `nginx.service      loaded failed failed A high performance web server
+backup.timer       loaded failed failed Nightly backup
+`
+
+func TestGetFailedUnits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	dir := writeTree(t, map[string]string{
+		"run/systemd/system/.keep": "",
+		"failed.txt":               sampleFailedUnits,
+		"none.txt":                 "",
+	})
+	runDir := filepath.Join(dir, "run/systemd/system")
+
+	tests := []struct {
+		name    string
+		runDir  string
+		command []string
+		want    []FailedUnit
+		wantOK  bool
+		wantErr bool
+	}{
+		{
+			name:    "failed units",
+			runDir:  runDir,
+			command: []string{"cat", filepath.Join(dir, "failed.txt")},
+			want: []FailedUnit{
+				{Name: "nginx.service", Description: "A high performance web server"},
+				{Name: "backup.timer", Description: "Nightly backup"},
+			},
+			wantOK: true,
+		},
+		{
+			name:    "no failed units",
+			runDir:  runDir,
+			command: []string{"cat", filepath.Join(dir, "none.txt")},
+			wantOK:  true,
+		},
+		{
+			name:    "not booted with systemd",
+			runDir:  filepath.Join(dir, "missing"),
+			command: []string{"cat", filepath.Join(dir, "failed.txt")},
+		},
+		{
+			name:    "systemctl not installed",
+			runDir:  runDir,
+			command: []string{"cpuinfo-no-such-systemctl"},
+		},
+		{
+			name:    "systemctl fails",
+			runDir:  runDir,
+			command: []string{"sh", "-c", "exit 1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewTemplateRegistry()
+			units, ok, err := getFailedUnits(context.Background(), tt.runDir, tt.command, reg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getFailedUnits error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK {
+				t.Errorf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !reflect.DeepEqual(units, tt.want) {
+				t.Errorf("units = %+v, want %+v", units, tt.want)
+			}
+			if _, registered := reg.MetadataTemplates()["failed_units"]; registered != tt.wantOK {
+				t.Errorf("failed_units template registered = %v, want %v", registered, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFailedUnitsLatest(t *testing.T) {
+	at := time.Unix(1646136000, 0)
+	units := parseFailedUnits(sampleFailedUnits)
+	latest := failedUnitsLatest(units, at)
+
+	want := map[string]string{
+		"failed_units":                           "2",
+		failedUnitsTablePrefix + "nginx.service": "A high performance web server",
+		failedUnitsTablePrefix + "backup.timer":  "Nightly backup",
+	}
+	if len(latest) != len(want) {
+		t.Errorf("latest has %d rows, want %d: %v", len(latest), len(want), latest)
+	}
+	for k, v := range want {
+		if got := latest[k]; got.Value != v || !got.Timestamp.Equal(at) {
+			t.Errorf("%s = %+v, want %q at %s", k, got, v, at)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TCPSNMPStats holds the TCP counters of /proc/net/snmp used for rates.
+type TCPSNMPStats struct {
+	RetransSegs uint64
+}
+
+// getTCPSNMPStats reads the Tcp counters from <procRoot>/net/snmp.
+func getTCPSNMPStats(ctx context.Context, procRoot string) (TCPSNMPStats, error) {
+	f, err := os.Open(filepath.Join(procRoot, "net", "snmp"))
+	if err != nil {
+		return TCPSNMPStats{}, &MetricError{Subsystem: "tcp", Err: err}
+	}
+	defer f.Close()
+
+	stats, err := parseTCPSNMP(f)
+	if err != nil {
+		return TCPSNMPStats{}, &MetricError{Subsystem: "tcp", Err: err}
+	}
+	return stats, nil
+}
+
+// parseTCPSNMP parses the snmp format, where each protocol has a line of
+// field names followed by a line of values, both prefixed with "Tcp:".
+func parseTCPSNMP(r io.Reader) (TCPSNMPStats, error) {
+	var header []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "Tcp:" {
+			continue
+		}
+		if header == nil {
+			header = fields
+			continue
+		}
+		for i, name := range header {
+			if name != "RetransSegs" || i >= len(fields) {
+				continue
+			}
+			v, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return TCPSNMPStats{}, fmt.Errorf("invalid RetransSegs %q: %v", fields[i], err)
+			}
+			return TCPSNMPStats{RetransSegs: v}, nil
+		}
+		break
+	}
+	if err := scanner.Err(); err != nil {
+		return TCPSNMPStats{}, err
+	}
+	return TCPSNMPStats{}, fmt.Errorf("no Tcp RetransSegs in snmp")
+}
+
+// tcpSampler keeps the previous TCP counters so that rates can be computed
+// between samples.
+type tcpSampler struct {
+	prev     TCPSNMPStats
+	prevTime time.Time
+	hasPrev  bool
+}
+
+// getTCPRetransmitRate returns the TCP segments retransmitted per second
+// since the previous call. ok is false on the first call and after the
+// counter went backwards.
+func (s *tcpSampler) getTCPRetransmitRate(ctx context.Context, procRoot string, reg *TemplateRegistry) (rate float64, ok bool, err error) {
+	stats, err := getTCPSNMPStats(ctx, procRoot)
+	if err != nil {
+		return 0, false, err
+	}
+	reg.RegisterMetadata(getTCPMetadataTemplate())
+	rate, ok = s.update(stats, time.Now())
+	return rate, ok, nil
+}
+
+func (s *tcpSampler) update(stats TCPSNMPStats, now time.Time) (float64, bool) {
+	prev, prevTime, hasPrev := s.prev, s.prevTime, s.hasPrev
+	s.prev, s.prevTime, s.hasPrev = stats, now, true
+
+	elapsed := now.Sub(prevTime).Seconds()
+	if !hasPrev || elapsed <= 0 || stats.RetransSegs < prev.RetransSegs {
+		return 0, false
+	}
+	return float64(stats.RetransSegs-prev.RetransSegs) / elapsed, true
+}
+
+func tcpLatest(retransPerSec float64, t time.Time) map[string]stringEntry {
+	return map[string]stringEntry{
+		"net_tcp_retransmits_per_sec": {Timestamp: t, Value: formatNumber(retransPerSec, 2)},
+	}
+}
+
+func getTCPMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"net_tcp_retransmits_per_sec": {
+			ID:       "net_tcp_retransmits_per_sec",
+			Label:    "TCP Retransmits (/s)",
+			Datatype: "number",
+			Priority: priorityUtilization + 1.51,
+			From:     "latest",
+		},
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleSNMP = `Ip: Forwarding DefaultTTL InReceives InHdrErrors
+Ip: 1 64 2451237 0
+Icmp: InMsgs InErrors
+Icmp: 45 0
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts InCsumErrors
+Tcp: 1 200 120000 -1 10234 812 41 97 12 2210934 2398211 1573 0 3012 0
+Udp: InDatagrams NoPorts InErrors OutDatagrams
+Udp: 52001 12 0 52344
+`
+
+func TestParseTCPSNMP(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    uint64
+		wantErr bool
+	}{
+		{name: "proc/net/snmp", input: sampleSNMP, want: 1573},
+		{name: "no Tcp section", input: "Ip: Forwarding\nIp: 1\n", wantErr: true},
+		{name: "invalid counter", input: "Tcp: RetransSegs\nTcp: lots\n", wantErr: true},
+		{name: "no RetransSegs", input: "Tcp: InSegs OutSegs\nTcp: 10 20\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTCPSNMP(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTCPSNMP error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.RetransSegs != tt.want {
+				t.Errorf("RetransSegs = %d, want %d", got.RetransSegs, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTCPSNMPStats(t *testing.T) {
+	root := writeTree(t, map[string]string{"net/snmp": sampleSNMP})
+	stats, err := getTCPSNMPStats(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.RetransSegs != 1573 {
+		t.Errorf("RetransSegs = %d, want 1573", stats.RetransSegs)
+	}
+	if _, err := getTCPSNMPStats(context.Background(), t.TempDir()); !sysfsMissing(err) {
+		t.Errorf("getTCPSNMPStats without snmp = %v, want a missing error", err)
+	}
+}
+
+func TestTCPSamplerUpdate(t *testing.T) {
+	start := time.Unix(1646136000, 0)
+	tests := []struct {
+		name     string
+		samples  []uint64
+		wantRate float64
+		wantOK   bool
+	}{
+		{name: "first sample", samples: []uint64{1573}},
+		{name: "rate", samples: []uint64{1573, 1623}, wantRate: 5, wantOK: true},
+		{name: "counter reset", samples: []uint64{1573, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s tcpSampler
+			var rate float64
+			var ok bool
+			for i, retrans := range tt.samples {
+				rate, ok = s.update(TCPSNMPStats{RetransSegs: retrans}, start.Add(time.Duration(i)*10*time.Second))
+			}
+			if rate != tt.wantRate || ok != tt.wantOK {
+				t.Errorf("update = %v, %v, want %v, %v", rate, ok, tt.wantRate, tt.wantOK)
+			}
+		})
+	}
+}
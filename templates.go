@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// templateOverrides holds the -templates file: metadata and table templates
+// merged by ID over the built-in ones, so labels and priorities can be
+// changed without recompiling. Only the fields set in the file are applied.
+type templateOverrides struct {
+	MetadataTemplates []metadataTemplate `json:"metadata_templates"`
+	TableTemplates    []tableTemplate    `json:"table_templates"`
+}
+
+// loadTemplateOverrides reads the templates file at path. Unknown fields and
+// templates without an ID are errors.
+func loadTemplateOverrides(path string) (templateOverrides, error) {
+	var overrides templateOverrides
+	if path == "" {
+		return overrides, nil
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return overrides, fmt.Errorf("failed to read templates %q: %v", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&overrides); err != nil {
+		return overrides, fmt.Errorf("failed to parse templates %q: %v", path, err)
+	}
+	for i, t := range overrides.MetadataTemplates {
+		if t.ID == "" {
+			return overrides, fmt.Errorf("metadata template %d in %q has no id", i, path)
+		}
+	}
+	for i, t := range overrides.TableTemplates {
+		if t.ID == "" {
+			return overrides, fmt.Errorf("table template %d in %q has no id", i, path)
+		}
+	}
+	return overrides, nil
+}
+
+// apply merges the overrides into the templates registered in reg. Overrides
+// of templates that weren't registered are ignored, so a report never
+// describes metrics that weren't collected.
+func (o templateOverrides) apply(reg *TemplateRegistry) {
+	if reg == nil {
+		return
+	}
+	for _, override := range o.MetadataTemplates {
+		t, ok := reg.metadata[override.ID]
+		if !ok {
+			continue
+		}
+		if override.Label != "" {
+			t.Label = override.Label
+		}
+		if override.Truncate != 0 {
+			t.Truncate = override.Truncate
+		}
+		if override.Datatype != "" {
+			t.Datatype = override.Datatype
+		}
+		if override.Priority != 0 {
+			t.Priority = override.Priority
+		}
+		if override.From != "" {
+			t.From = override.From
+		}
+		reg.metadata[t.ID] = t
+	}
+	for _, override := range o.TableTemplates {
+		t, ok := reg.tables[override.ID]
+		if !ok {
+			continue
+		}
+		if override.Label != "" {
+			t.Label = override.Label
+		}
+		if override.Type != "" {
+			t.Type = override.Type
+		}
+		if len(override.Columns) > 0 {
+			t.Columns = override.Columns
+		}
+		reg.tables[t.ID] = t
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTemplateOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "metadata and tables",
+			content: `{"metadata_templates": [{"id": "cpu_model", "label": "Processor"}], "table_templates": [{"id": "cpuinfo-table", "label": "CPU"}]}`,
+		},
+		{name: "empty", content: `{}`},
+		{
+			name:    "unknown field",
+			content: `{"metadata_templates": [{"id": "cpu_model", "lable": "Processor"}]}`,
+			wantErr: `unknown field "lable"`,
+		},
+		{
+			name:    "metadata without id",
+			content: `{"metadata_templates": [{"id": "cpu_model"}, {"label": "Processor"}]}`,
+			wantErr: "metadata template 1",
+		},
+		{
+			name:    "table without id",
+			content: `{"table_templates": [{"label": "CPU"}]}`,
+			wantErr: "table template 0",
+		},
+		{name: "invalid JSON", content: `{"metadata_templates": [`, wantErr: "failed to parse templates"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(writeTree(t, map[string]string{"templates.json": tt.content}), "templates.json")
+			_, err := loadTemplateOverrides(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("loadTemplateOverrides = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadTemplateOverrides = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := loadTemplateOverrides(filepath.Join(t.TempDir(), "nope.json")); err == nil {
+			t.Error("loadTemplateOverrides of a missing file succeeded")
+		}
+	})
+}
+
+func TestTemplateOverridesInReport(t *testing.T) {
+	const overrides = `{
+  "metadata_templates": [
+    {"id": "cpu_model", "label": "Processor", "priority": 10.9},
+    {"id": "not_collected", "label": "Ghost"}
+  ],
+  "table_templates": [{"id": "cpuinfo-table", "label": "Rack 7 CPUs"}]
+}`
+	cfg := loadConfig()
+	cfg.TemplatesFile = filepath.Join(writeTree(t, map[string]string{"templates.json": overrides}), "templates.json")
+	p := NewPlugin("host", cfg)
+	rpt, err := p.makeReport(context.Background())
+	if err != nil {
+		t.Fatalf("makeReport: %v", err)
+	}
+
+	model := rpt.Host.MetadataTemplates["cpu_model"]
+	if model.Label != "Processor" || model.Priority != 10.9 {
+		t.Errorf("cpu_model = %+v, want label Processor and priority 10.9", model)
+	}
+	if model.Truncate != 40 {
+		t.Errorf("cpu_model truncate = %d, want the built-in 40", model.Truncate)
+	}
+	if _, ok := rpt.Host.MetadataTemplates["not_collected"]; ok {
+		t.Error("override of an uncollected metric added a template")
+	}
+	if table := rpt.Host.TableTemplates["cpuinfo-table"]; table.Label != "Rack 7 CPUs" || table.Prefix != "cpuinfo-table-" {
+		t.Errorf("cpuinfo-table = %+v, want label Rack 7 CPUs with the built-in prefix", table)
+	}
+}
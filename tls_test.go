@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA signs certificates for the mTLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for name, usable for usage.
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestMTLSListener(t *testing.T) {
+	ca := newTestCA(t, "cpuinfo test CA")
+	rogue := newTestCA(t, "rogue CA")
+	serverCert, serverKey := ca.issue(t, "cpuinfo", x509.ExtKeyUsageServerAuth)
+	dir := t.TempDir()
+	for name, content := range map[string][]byte{"ca.pem": ca.pem, "server.pem": serverCert, "server-key.pem": serverKey} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := loadConfig()
+	cfg.CollectMode = collectBackground
+	cfg.ListenTCP = "127.0.0.1:0"
+	cfg.TLSCert = filepath.Join(dir, "server.pem")
+	cfg.TLSKey = filepath.Join(dir, "server-key.pem")
+	cfg.TLSCA = filepath.Join(dir, "ca.pem")
+	listener, cleanup, err := setupListener(cfg)
+	if err != nil {
+		t.Fatalf("setupListener: %v", err)
+	}
+	defer cleanup()
+	defer listener.Close()
+	go http.Serve(listener, NewPlugin("host", cfg))
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	clientCert := func(ca *testCA) *tls.Certificate {
+		certPEM, keyPEM := ca.issue(t, "scope", x509.ExtKeyUsageClientAuth)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &cert
+	}
+	tests := []struct {
+		name     string
+		cert     *tls.Certificate
+		accepted bool
+	}{
+		{name: "client signed by the CA", cert: clientCert(ca), accepted: true},
+		{name: "no client certificate", cert: &tls.Certificate{}},
+		{name: "client signed by another CA", cert: clientCert(rogue)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{
+				Timeout: 5 * time.Second,
+				Transport: &http.Transport{TLSClientConfig: &tls.Config{
+					RootCAs: roots,
+					// Present the certificate even when its issuer isn't
+					// one the server asks for.
+					GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+						return tt.cert, nil
+					},
+				}},
+			}
+			resp, err := client.Get("https://" + listener.Addr().String() + "/healthz")
+			if !tt.accepted {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("request succeeded with status %d, want a TLS failure", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestMTLSConfigErrors(t *testing.T) {
+	ca := newTestCA(t, "cpuinfo test CA")
+	cert, key := ca.issue(t, "cpuinfo", x509.ExtKeyUsageServerAuth)
+	dir := writeTree(t, map[string]string{
+		"ca.pem":         string(ca.pem),
+		"server.pem":     string(cert),
+		"server-key.pem": string(key),
+		"empty.pem":      "",
+	})
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name              string
+		cert, key, caFile string
+	}{
+		{name: "missing key", cert: path("server.pem"), key: path("nope.pem"), caFile: path("ca.pem")},
+		{name: "missing CA", cert: path("server.pem"), key: path("server-key.pem"), caFile: path("nope.pem")},
+		{name: "CA without certificates", cert: path("server.pem"), key: path("server-key.pem"), caFile: path("empty.pem")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := mtlsConfig(tt.cert, tt.key, tt.caFile); err == nil {
+				t.Error("mtlsConfig succeeded")
+			}
+		})
+	}
+}
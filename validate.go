@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// reportSchema is the JSON schema of the Scope plugin report, covering the
+// parts of the format this plugin writes.
+//
+//go:embed report.schema.json
+var reportSchema []byte
+
+// jsonSchema is the subset of JSON schema used by report.schema.json.
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []string               `json:"enum"`
+	MinLength            int                    `json:"minLength"`
+	Definitions          map[string]*jsonSchema `json:"definitions"`
+}
+
+// validateReport checks a marshaled report against reportSchema. The error
+// names the path of the first mismatch, e.g. Host.nodes["h;<host>"].latest.
+func validateReport(raw []byte) error {
+	var schema jsonSchema
+	if err := json.Unmarshal(reportSchema, &schema); err != nil {
+		return fmt.Errorf("invalid embedded report schema: %v", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("report is not valid JSON: %v", err)
+	}
+	v := schemaValidator{definitions: schema.Definitions}
+	return v.validate(&schema, doc, "report")
+}
+
+type schemaValidator struct {
+	definitions map[string]*jsonSchema
+}
+
+func (v schemaValidator) validate(s *jsonSchema, doc interface{}, path string) error {
+	if s.Ref != "" {
+		def, ok := v.definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema reference %q", path, s.Ref)
+		}
+		s = def
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %s", path, jsonTypeName(doc))
+		}
+		return v.validateObject(s, obj, path)
+	case "array":
+		arr, ok := doc.([]interface{})
+		if !ok {
+			// encoding/json writes nil slices as null.
+			if doc == nil {
+				return nil
+			}
+			return fmt.Errorf("%s: expected an array, got %s", path, jsonTypeName(doc))
+		}
+		if s.Items == nil {
+			return nil
+		}
+		for i, item := range arr {
+			if err := v.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := doc.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string, got %s", path, jsonTypeName(doc))
+		}
+		if len(str) < s.MinLength {
+			return fmt.Errorf("%s: must not be empty", path)
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, str) {
+			return fmt.Errorf("%s: %q is not one of %s", path, str, strings.Join(s.Enum, ", "))
+		}
+	case "number", "integer":
+		num, ok := doc.(float64)
+		if !ok {
+			return fmt.Errorf("%s: expected a number, got %s", path, jsonTypeName(doc))
+		}
+		if s.Type == "integer" && num != math.Trunc(num) {
+			return fmt.Errorf("%s: expected an integer, got %v", path, num)
+		}
+	case "boolean":
+		if _, ok := doc.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean, got %s", path, jsonTypeName(doc))
+		}
+	}
+	return nil
+}
+
+func (v schemaValidator) validateObject(s *jsonSchema, obj map[string]interface{}, path string) error {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%s: missing required field %q", path, name)
+		}
+	}
+
+	// Additional properties are either forbidden (false) or all follow one
+	// schema, as for maps keyed by node or template ID.
+	var additional *jsonSchema
+	allowAdditional := len(s.AdditionalProperties) == 0
+	if !allowAdditional && string(s.AdditionalProperties) != "false" {
+		if err := json.Unmarshal(s.AdditionalProperties, &additional); err != nil {
+			return fmt.Errorf("%s: invalid additionalProperties in schema: %v", path, err)
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		child := path + "." + k
+		prop, ok := s.Properties[k]
+		switch {
+		case ok:
+		case additional != nil:
+			prop, child = additional, path+"["+strconv.Quote(k)+"]"
+		case allowAdditional:
+			continue
+		default:
+			return fmt.Errorf("%s: unknown field %q", path, k)
+		}
+		if err := v.validate(prop, obj[k], child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonTypeName(doc interface{}) string {
+	switch doc.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return fmt.Sprintf("%T", doc)
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReport collects a report once and validates it against reportSchema.
+func checkReport(p *Plugin) error {
+	rpt, err := p.makeReport(context.Background())
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(*rpt)
+	if err != nil {
+		return err
+	}
+	return validateReport(raw)
+}
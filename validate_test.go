@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateReport(t *testing.T) {
+	golden, err := ioutil.ReadFile(filepath.Join("testdata", "report.golden.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostNode := func(rpt map[string]interface{}) map[string]interface{} {
+		nodes := rpt["Host"].(map[string]interface{})["nodes"].(map[string]interface{})
+		return nodes["host;<host>"].(map[string]interface{})
+	}
+	plugin := func(rpt map[string]interface{}) map[string]interface{} {
+		return rpt["Plugins"].([]interface{})[0].(map[string]interface{})
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(rpt map[string]interface{})
+		wantErr string
+	}{
+		{name: "golden report", mutate: func(map[string]interface{}) {}},
+		{
+			name:    "no plugins",
+			mutate:  func(rpt map[string]interface{}) { delete(rpt, "Plugins") },
+			wantErr: `report: missing required field "Plugins"`,
+		},
+		{
+			name:    "misspelled topology",
+			mutate:  func(rpt map[string]interface{}) { rpt["Hosts"] = rpt["Host"] },
+			wantErr: `report: unknown field "Hosts"`,
+		},
+		{
+			name: "numeric latest value",
+			mutate: func(rpt map[string]interface{}) {
+				latest := hostNode(rpt)["latest"].(map[string]interface{})
+				latest["cpu_user_percent"].(map[string]interface{})["value"] = 12.5
+			},
+			wantErr: `report.Host.nodes["host;<host>"].latest["cpu_user_percent"].value: expected a string, got a number`,
+		},
+		{
+			name:    "unknown interface",
+			mutate:  func(rpt map[string]interface{}) { plugin(rpt)["interfaces"] = []interface{}{"exporter"} },
+			wantErr: `report.Plugins[0].interfaces[0]: "exporter" is not one of reporter, controller`,
+		},
+		{
+			name:    "empty plugin ID",
+			mutate:  func(rpt map[string]interface{}) { plugin(rpt)["id"] = "" },
+			wantErr: `report.Plugins[0].id: must not be empty`,
+		},
+		{
+			name:    "sets as a string",
+			mutate:  func(rpt map[string]interface{}) { hostNode(rpt)["sets"] = "fpu sse2" },
+			wantErr: `report.Host.nodes["host;<host>"].sets: expected an object, got a string`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rpt map[string]interface{}
+			if err := json.Unmarshal(golden, &rpt); err != nil {
+				t.Fatal(err)
+			}
+			tt.mutate(rpt)
+			raw, err := json.Marshal(rpt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = validateReport(raw)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateReport = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("validateReport = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("not JSON", func(t *testing.T) {
+		if err := validateReport([]byte(`{"Host":`)); err == nil || !strings.HasPrefix(err.Error(), "report is not valid JSON") {
+			t.Errorf("validateReport = %v, want a JSON error", err)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const vulnerabilitiesTablePrefix = "cpuinfo-vulnerabilities-"
+
+// Vulnerability is a CPU vulnerability known to the kernel and its status,
+// e.g. "Not affected", "Vulnerable" or "Mitigation: PTI".
+type Vulnerability struct {
+	Name   string
+	Status string
+}
+
+// VulnerabilityStats lists the CPU vulnerabilities, ordered by name.
+type VulnerabilityStats struct {
+	Vulnerabilities []Vulnerability
+}
+
+func (s VulnerabilityStats) count(prefix string) int {
+	count := 0
+	for _, v := range s.Vulnerabilities {
+		if strings.HasPrefix(v.Status, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// getVulnerabilityStats reads <cpuRoot>/vulnerabilities/*, where cpuRoot is
+// normally /sys/devices/system/cpu.
+func getVulnerabilityStats(ctx context.Context, cpuRoot string, reg *TemplateRegistry) (VulnerabilityStats, error) {
+	dir := filepath.Join(cpuRoot, "vulnerabilities")
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return VulnerabilityStats{}, &MetricError{Subsystem: "cpu_vulnerabilities", Err: err}
+	}
+	var stats VulnerabilityStats
+	for _, e := range entries {
+		status, err := readSysfsString(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return VulnerabilityStats{}, &MetricError{Subsystem: "cpu_vulnerabilities", Err: err}
+		}
+		stats.Vulnerabilities = append(stats.Vulnerabilities, Vulnerability{Name: e.Name(), Status: status})
+	}
+	sort.Slice(stats.Vulnerabilities, func(i, j int) bool {
+		return stats.Vulnerabilities[i].Name < stats.Vulnerabilities[j].Name
+	})
+	reg.RegisterMetadata(getVulnerabilityMetadataTemplate())
+	reg.RegisterTables(getVulnerabilityTableTemplate())
+	return stats, nil
+}
+
+func vulnerabilityLatest(stats VulnerabilityStats, t time.Time) map[string]stringEntry {
+	summary := fmt.Sprintf("%d vulnerable, %d mitigated", stats.count("Vulnerable"), stats.count("Mitigation"))
+	latest := map[string]stringEntry{
+		"cpu_vulnerabilities": {Timestamp: t, Value: summary},
+	}
+	for _, v := range stats.Vulnerabilities {
+		latest[vulnerabilitiesTablePrefix+v.Name] = stringEntry{Timestamp: t, Value: v.Status}
+	}
+	return latest
+}
+
+func getVulnerabilityMetadataTemplate() map[string]metadataTemplate {
+	return map[string]metadataTemplate{
+		"cpu_vulnerabilities": {
+			ID:       "cpu_vulnerabilities",
+			Label:    "CPU Vulnerabilities",
+			Priority: prioritySoftware + 2,
+			From:     "latest",
+		},
+	}
+}
+
+func getVulnerabilityTableTemplate() map[string]tableTemplate {
+	return map[string]tableTemplate{
+		"cpuinfo-vulnerabilities": {
+			ID:     "cpuinfo-vulnerabilities",
+			Label:  "CPU Vulnerabilities",
+			Prefix: vulnerabilitiesTablePrefix,
+		},
+	}
+}
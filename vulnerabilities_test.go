@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetVulnerabilityStats(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        []Vulnerability
+		wantSummary string
+		wantMissing bool
+	}{
+		{
+			name: "mixed statuses",
+			files: map[string]string{
+				"vulnerabilities/spectre_v2": "Mitigation: Retpolines; IBPB: conditional\n",
+				"vulnerabilities/meltdown":   "Mitigation: PTI\n",
+				"vulnerabilities/mds":        "Vulnerable: Clear CPU buffers attempted, no microcode\n",
+				"vulnerabilities/l1tf":       "Not affected\n",
+			},
+			want: []Vulnerability{
+				{Name: "l1tf", Status: "Not affected"},
+				{Name: "mds", Status: "Vulnerable: Clear CPU buffers attempted, no microcode"},
+				{Name: "meltdown", Status: "Mitigation: PTI"},
+				{Name: "spectre_v2", Status: "Mitigation: Retpolines; IBPB: conditional"},
+			},
+			wantSummary: "1 vulnerable, 2 mitigated",
+		},
+		{
+			name:        "no vulnerabilities directory",
+			files:       map[string]string{"online": "0-3\n"},
+			wantMissing: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := time.Unix(1646136000, 0)
+			stats, err := getVulnerabilityStats(context.Background(), writeTree(t, tt.files), NewTemplateRegistry())
+			if tt.wantMissing {
+				if !sysfsMissing(err) {
+					t.Fatalf("getVulnerabilityStats error = %v, want a missing error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(stats.Vulnerabilities, tt.want) {
+				t.Errorf("vulnerabilities = %+v, want %+v", stats.Vulnerabilities, tt.want)
+			}
+
+			latest := vulnerabilityLatest(stats, at)
+			if got := latest["cpu_vulnerabilities"].Value; got != tt.wantSummary {
+				t.Errorf("cpu_vulnerabilities = %q, want %q", got, tt.wantSummary)
+			}
+			for _, v := range tt.want {
+				if got := latest[vulnerabilitiesTablePrefix+v.Name].Value; got != v.Status {
+					t.Errorf("%s row = %q, want %q", v.Name, got, v.Status)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// hangFactor is how many refresh intervals a collection may take before the
+// watchdog considers it hung.
+const hangFactor = 5
+
+// watchdog tracks background collections. Some gopsutil calls have been seen
+// to block forever on a few kernels; the watchdog makes that visible and
+// abandons the hung collection so that the refresher keeps running.
+type watchdog struct {
+	hangs    uint64 // atomic
+	inFlight int32  // atomic, 1 while run waits for a collection
+	hung     int32  // atomic, abandoned collections that haven't returned
+
+	mu          sync.Mutex
+	lastSuccess time.Time
+}
+
+// Outcomes of a collection run by the watchdog.
+const (
+	collectionRunning int32 = iota
+	collectionAbandoned
+	collectionReturned
+)
+
+// run calls collect in its own goroutine and waits for it, for at most
+// hangFactor × interval. On timeout it logs a warning, counts the hang,
+// cancels the collection's context and abandons it: run returns without
+// waiting, and the collection's result is dropped whenever it returns.
+func (w *watchdog) run(interval time.Duration, collect func(ctx context.Context) error) {
+	if !atomic.CompareAndSwapInt32(&w.inFlight, 0, 1) {
+		warnf("previous collection still running, skipping this one")
+		return
+	}
+	defer atomic.StoreInt32(&w.inFlight, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	limit := hangFactor * interval
+	alarm := time.NewTimer(limit)
+	defer alarm.Stop()
+
+	var outcome int32 // collectionRunning
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := collect(ctx)
+		if !atomic.CompareAndSwapInt32(&outcome, collectionRunning, collectionReturned) {
+			atomic.AddInt32(&w.hung, -1)
+			return
+		}
+		if err == nil {
+			w.mu.Lock()
+			w.lastSuccess = time.Now()
+			w.mu.Unlock()
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-alarm.C:
+		if !atomic.CompareAndSwapInt32(&outcome, collectionRunning, collectionAbandoned) {
+			<-done // returned just in time
+			return
+		}
+		cancel()
+		atomic.AddUint64(&w.hangs, 1)
+		atomic.AddInt32(&w.hung, 1)
+		warnf("metric collection did not complete within %s, abandoning it", limit)
+	}
+}
+
+// healthz reports the watchdog state as JSON. It answers 503 while an
+// abandoned collection hasn't returned.
+func (w *watchdog) healthz(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	last := w.lastSuccess
+	w.mu.Unlock()
+
+	status := struct {
+		Status      string    `json:"status"`
+		LastSuccess time.Time `json:"last_success,omitempty"`
+		HangCount   uint64    `json:"plugin_collection_hangcount_total"`
+	}{
+		Status:      "ok",
+		LastSuccess: last,
+		HangCount:   atomic.LoadUint64(&w.hangs),
+	}
+	code := http.StatusOK
+	if atomic.LoadInt32(&w.hung) > 0 {
+		status.Status = "hung"
+		code = http.StatusServiceUnavailable
+	}
+
+	raw, err := json.Marshal(status)
+	if err != nil {
+		errorf("%v", err)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	rw.Write(raw)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func healthzCode(w *watchdog) int {
+	rec := httptest.NewRecorder()
+	w.healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	return rec.Code
+}
+
+func TestWatchdogAbandonsHungCollection(t *testing.T) {
+	var w watchdog
+	release := make(chan struct{})
+	returned := make(chan struct{})
+	var calls int32
+
+	start := time.Now()
+	w.run(time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		<-release // ignores ctx, like a hung syscall
+		close(returned)
+		return nil
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("run waited %s for the hung collection", elapsed)
+	}
+	if got := atomic.LoadUint64(&w.hangs); got != 1 {
+		t.Errorf("hangs = %d, want 1", got)
+	}
+	if code := healthzCode(&w); code != http.StatusServiceUnavailable {
+		t.Errorf("healthz while hung = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	// The abandoned collection doesn't keep run from starting the next one.
+	w.run(time.Second, func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("collections started = %d, want 2", got)
+	}
+
+	close(release)
+	<-returned
+	deadline := time.Now().Add(5 * time.Second)
+	for healthzCode(&w) != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("healthz still unhealthy after the abandoned collection returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchdogRecordsSuccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		success bool
+	}{
+		{"success", nil, true},
+		{"failure", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w watchdog
+			w.run(time.Second, func(ctx context.Context) error { return tt.err })
+			if got := !w.lastSuccess.IsZero(); got != tt.success {
+				t.Errorf("lastSuccess set = %v, want %v", got, tt.success)
+			}
+			if w.hangs != 0 {
+				t.Errorf("hangs = %d, want 0", w.hangs)
+			}
+		})
+	}
+}